@@ -1,6 +1,7 @@
 package starlarkgen
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"strings"
@@ -16,16 +17,41 @@ const (
 
 type outputOpts struct {
 	// options
-	depth         int
-	indent        string
-	spaceEqBinary bool
-	dictOption    DictOption
-	listOption    ListOption
-	callOption    CallOption
-	tupleOption   TupleOption
+	depth          int
+	indent         string
+	spaceEqBinary  bool
+	dictOption     DictOption
+	listOption     ListOption
+	callOption     CallOption
+	tupleOption    TupleOption
+	style          Style
+	sortScope      SortScope
+	sortKwargs     map[string]bool
+	sourceMap      *SourceMap
+	posFile        *string
+	source         string
+	keepGoing      bool
+	validate       bool
+	sanitizeIdents bool
+	autoPass       bool
+	minify         bool
+	dropDocstrings bool
+	compactSuites  bool
+	compactWidth   int
+
+	blankLinePolicy    *BlankLinePolicy
+	preserveBlankLines bool
+	maxBlankLines      int
 
 	// runtime helpers
+	level        int
+	sortNext     bool
+	semicolon    bool
+	inline       bool
+	block        string
 	stringBuffer []byte
+	comments     *commentMap
+	rewrites     *styleRewrites
 }
 
 // copy the options, will panic on nil argument
@@ -68,6 +94,10 @@ const (
 	lastCommaTwoAndMore
 )
 
+func makeRenderOption(m multiLineType, c lastCommaType) renderOption {
+	return renderOption(uint8(m)*3 + uint8(c))
+}
+
 func (ro renderOption) commaType() lastCommaType {
 	return lastCommaType(uint8(ro) % 3)
 }
@@ -173,7 +203,8 @@ const (
 )
 
 // TupleOption controls how the tuple literals are rendered. See examples for
-// details on each specific option.
+// details on each specific option. The single element tuples always have the
+// comma after the item, otherwise they are parenthesized expressions.
 type TupleOption renderOption
 
 const (
@@ -224,6 +255,20 @@ func WithSpaceEqBinary(value bool) Option {
 	}
 }
 
+// WithAutoPass sets the behavior of how the empty def, if, else, for and
+// while blocks are rendered. When set to true, the pass statement is written
+// for them, e.g.
+//   def foo():
+//       pass
+// when set to false, rendering the empty block fails. The default value is false.
+func WithAutoPass(value bool) Option {
+	return func(o *outputOpts) (*outputOpts, error) {
+		c := o.copy()
+		c.autoPass = value
+		return c, nil
+	}
+}
+
 // WithDepth sets the initial indentation depth.
 func WithDepth(depth int) Option {
 	return func(o *outputOpts) (*outputOpts, error) {
@@ -304,52 +349,131 @@ func getOutputOpts(options ...Option) (*outputOpts, error) {
 			return nil, err
 		}
 	}
+	if opts.minify {
+		opts.indent = minifyIndent
+		opts.spaceEqBinary = false
+	}
 
 	return opts, nil
 }
 
+// prepareStyle places the comments and computes the sorting rewrites of
+// the input rendered with the buildifier styles.
+func (o *outputOpts) prepareStyle(input syntax.Node) {
+	if o.style == StyleDefault || o.minify {
+		return
+	}
+	// syntax.Walk panics on the nil nodes of the trees built by hand, those
+	// are left to the renderer to report
+	defer func() {
+		if recover() != nil {
+			o.comments, o.rewrites = nil, nil
+		}
+	}()
+	o.comments = newCommentMap(input)
+	o.rewrites = newStyleRewrites(input, o.style, o.comments)
+}
+
 // StarlarkStmt produces Starlark source code for a single statement
 // using the options supplied.
-// In case of an error the string output is always empty.
+// In case of an error the string output is always empty, unless the errors
+// are recovered with WithKeepGoing.
 func StarlarkStmt(input syntax.Stmt, options ...Option) (string, error) {
 	var sb strings.Builder
-	if err := WriteStmt(&sb, input, options...); err != nil {
-		return "", err
-	}
-	return sb.String(), nil
+	err := WriteStmt(&sb, input, options...)
+	return keptOutput(&sb, err)
 }
 
 // WriteStmt writes the Starlark statement to the provided writer
 // using the options supplied.
 // In case of an error incomplete results might be written to the output,
-// use StarlarkStmt to avoid handling partial input.
+// use StarlarkStmt to avoid handling partial input, or WithValidation to check
+// the input before rendering.
 func WriteStmt(output io.StringWriter, input syntax.Stmt, options ...Option) error {
 	opts, err := getOutputOpts(options...)
 	if err != nil {
 		return err
 	}
-	return stmt(output, input, opts)
+	if opts.validate {
+		if errs := validate(input, opts); len(errs) > 0 {
+			return errs
+		}
+	}
+	opts.prepareStyle(input)
+	err = opts.track(output, func(out io.StringWriter) error {
+		if opts.comments != nil {
+			// the comments before and after the statement
+			return commentStmtList(out, []syntax.Stmt{input}, opts)
+		}
+		return stmt(out, input, opts)
+	})
+	return renderError(input, err, opts.source)
+}
+
+// StarlarkFile produces Starlark source code for the file using the options
+// supplied, see WriteFile.
+// In case of an error the string output is always empty, unless the errors
+// are recovered with WithKeepGoing.
+func StarlarkFile(input *syntax.File, options ...Option) (string, error) {
+	var sb strings.Builder
+	err := WriteFile(&sb, input, options...)
+	return keptOutput(&sb, err)
+}
+
+// WriteFile writes the statements of the Starlark file to the provided writer
+// using the options supplied. The top-level statements are separated with
+// a blank line, unless the style set with WithStyle defines otherwise.
+// In case of an error incomplete results might be written to the output,
+// use StarlarkFile to avoid handling partial input, or WithValidation to check
+// the input before rendering.
+func WriteFile(output io.StringWriter, input *syntax.File, options ...Option) error {
+	opts, err := getOutputOpts(options...)
+	if err != nil {
+		return err
+	}
+	if input == nil {
+		return errors.New("rendering file: nil input")
+	}
+	if opts.validate {
+		if errs := validate(input, opts); len(errs) > 0 {
+			return errs
+		}
+	}
+	opts.prepareStyle(input)
+	err = opts.track(output, func(out io.StringWriter) error {
+		return stmtList(out, opts.dropDocstring(input.Stmts), opts)
+	})
+	return renderError(input, err, opts.source)
 }
 
 // StarlarkExpr produces Starlark source code for a single expression
 // using the options supplied.
-// In case of an error the string output is always empty.
+// In case of an error the string output is always empty, unless the errors
+// are recovered with WithKeepGoing.
 func StarlarkExpr(input syntax.Expr, options ...Option) (string, error) {
 	var sb strings.Builder
-	if err := WriteExpr(&sb, input, options...); err != nil {
-		return "", err
-	}
-	return sb.String(), nil
+	err := WriteExpr(&sb, input, options...)
+	return keptOutput(&sb, err)
 }
 
 // WriteExpr writes the Starlark expression to the provided writer
 // using the options supplied.
 // In case of an error incomplete results might be written to the output,
-// use StarlarkStmt to avoid handling partial input.
+// use StarlarkExpr to avoid handling partial input, or WithValidation to check
+// the input before rendering.
 func WriteExpr(output io.StringWriter, input syntax.Expr, options ...Option) error {
 	opts, err := getOutputOpts(options...)
 	if err != nil {
 		return err
 	}
-	return expr(output, input, opts)
+	if opts.validate {
+		if errs := validate(input, opts); len(errs) > 0 {
+			return errs
+		}
+	}
+	opts.prepareStyle(input)
+	err = opts.track(output, func(out io.StringWriter) error {
+		return expr(out, input, opts)
+	})
+	return renderError(input, err, opts.source)
 }
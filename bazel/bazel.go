@@ -0,0 +1,305 @@
+/*
+
+Package bazel provides a model of Bazel BUILD files, rendered with
+starlarkgen.
+
+	pkg := &bazel.Package{
+		DefaultVisibility: []string{"//visibility:public"},
+		Rules: []*bazel.Rule{{
+			Kind: "go_library",
+			Load: "@io_bazel_rules_go//go:def.bzl",
+			Name: "foo",
+			Attrs: map[string]interface{}{
+				"srcs": []string{"foo.go"},
+				"deps": []string{"//bar", ":baz"},
+			},
+		}},
+	}
+	out, err := pkg.Build()
+
+The attribute values are converted using starlarkgen.FromGo, the syntax.Expr
+values, e.g. select() calls, are used as is.
+
+*/
+package bazel
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cyberpossum/starlarkgen"
+	"github.com/cyberpossum/starlarkgen/build"
+	"go.starlark.net/syntax"
+)
+
+// Labels is a list of labels, which is always sorted and deduplicated when
+// rendered, see LabelLess for the order.
+type Labels []string
+
+// labelAttrs are the common label list attributes, the []string values of
+// these attributes are handled as Labels.
+var labelAttrs = map[string]bool{
+	"compatible_with":        true,
+	"data":                   true,
+	"deps":                   true,
+	"embed":                  true,
+	"exec_compatible_with":   true,
+	"exports":                true,
+	"hdrs":                   true,
+	"implementation_deps":    true,
+	"plugins":                true,
+	"runtime_deps":           true,
+	"srcs":                   true,
+	"target_compatible_with": true,
+	"textual_hdrs":           true,
+	"tools":                  true,
+	"visibility":             true,
+}
+
+// attrPriority lists the attributes rendered before the rest, the remaining
+// ones are rendered in the alphabetical order, followed by visibility.
+var attrPriority = []string{"name", "srcs", "hdrs", "deps"}
+
+// Rule is a single target declaration, e.g.
+//   go_library(
+//       name = "foo",
+//       srcs = ["foo.go"],
+//   )
+type Rule struct {
+	// Kind is the rule function name, e.g. "go_library" or "native.genrule".
+	Kind string
+	// Load is the module Kind is loaded from, e.g. "@rules_cc//cc:defs.bzl",
+	// empty for the built-in rules.
+	Load string
+	// Name is the target name.
+	Name string
+	// Attrs are the rule attributes besides the name.
+	Attrs map[string]interface{}
+}
+
+// Package is the model of a BUILD file.
+type Package struct {
+	// Loads are the symbols to load in addition to the ones required by the
+	// rule kinds, keyed by the module.
+	Loads map[string][]string
+	// DefaultVisibility is rendered as package(default_visibility = [...]).
+	DefaultVisibility []string
+	// PackageAttrs are the other package() attributes.
+	PackageAttrs map[string]interface{}
+	// Licenses are rendered as licenses([...]).
+	Licenses []string
+	// Rules are the targets, rendered in the order provided.
+	Rules []*Rule
+}
+
+// LabelLess reports whether the label a sorts before the label b, see
+// starlarkgen.LabelLess.
+func LabelLess(a, b string) bool {
+	return starlarkgen.LabelLess(a, b)
+}
+
+// sortLabels returns the sorted copy of the labels without the duplicates.
+func sortLabels(labels []string) []string {
+	res := make([]string, 0, len(labels))
+	seen := make(map[string]bool, len(labels))
+	for _, l := range labels {
+		if !seen[l] {
+			seen[l] = true
+			res = append(res, l)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return LabelLess(res[i], res[j]) })
+	return res
+}
+
+// attrLess reports whether the attribute a is rendered before the attribute b.
+func attrLess(a, b string) bool {
+	if (a == "visibility") != (b == "visibility") {
+		return b == "visibility"
+	}
+	pa, pb := attrRank(a), attrRank(b)
+	if pa != pb {
+		return pa < pb
+	}
+	return a < b
+}
+
+func attrRank(name string) int {
+	for i, p := range attrPriority {
+		if p == name {
+			return i
+		}
+	}
+	return len(attrPriority)
+}
+
+// attrValue converts the attribute value to the expression.
+func attrValue(name string, v interface{}) (syntax.Expr, error) {
+	switch t := v.(type) {
+	case Labels:
+		v = sortLabels(t)
+	case []string:
+		if labelAttrs[name] {
+			v = sortLabels(t)
+		}
+	}
+	return starlarkgen.FromGo(v)
+}
+
+// kwargs converts the attributes to the keyword arguments in the canonical
+// order.
+func kwargs(attrs map[string]interface{}) ([]syntax.Expr, error) {
+	names := make([]string, 0, len(attrs))
+	for n := range attrs {
+		names = append(names, n)
+	}
+	sort.Slice(names, func(i, j int) bool { return attrLess(names[i], names[j]) })
+
+	res := make([]syntax.Expr, 0, len(names))
+	for _, n := range names {
+		x, err := attrValue(n, attrs[n])
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %w", n, err)
+		}
+		res = append(res, build.Kw(n, x))
+	}
+	return res, nil
+}
+
+// Call returns the rule call expression, with the name and the attributes as
+// keyword arguments in the canonical order: name, srcs, hdrs, deps, then the
+// rest in the alphabetical order, and visibility last.
+func (r *Rule) Call() (*syntax.CallExpr, error) {
+	if r.Kind == "" {
+		return nil, fmt.Errorf("rule %q: empty kind", r.Name)
+	}
+	if _, ok := r.Attrs["name"]; ok {
+		return nil, fmt.Errorf("rule %q: name must be set with the Name field", r.Name)
+	}
+
+	attrs := make(map[string]interface{}, len(r.Attrs)+1)
+	for k, v := range r.Attrs {
+		attrs[k] = v
+	}
+	attrs["name"] = r.Name
+	args, err := kwargs(attrs)
+	if err != nil {
+		return nil, fmt.Errorf("rule %q: %w", r.Name, err)
+	}
+	return &syntax.CallExpr{Fn: build.Name(r.Kind), Args: args}, nil
+}
+
+// loadStmts returns the load statements sorted by the module, with
+// the symbols sorted and deduplicated.
+func (p *Package) loadStmts() []syntax.Stmt {
+	symbols := make(map[string][]string)
+	for m, s := range p.Loads {
+		symbols[m] = append(symbols[m], s...)
+	}
+	for _, r := range p.Rules {
+		if r.Load != "" {
+			// the loaded symbol is the first part of the dotted kind
+			symbols[r.Load] = append(symbols[r.Load], strings.SplitN(r.Kind, ".", 2)[0])
+		}
+	}
+
+	modules := make([]string, 0, len(symbols))
+	for m := range symbols {
+		modules = append(modules, m)
+	}
+	sort.Slice(modules, func(i, j int) bool { return LabelLess(modules[i], modules[j]) })
+
+	res := make([]syntax.Stmt, len(modules))
+	for i, m := range modules {
+		s := symbols[m]
+		sort.Strings(s)
+		uniq := s[:0]
+		for j, sym := range s {
+			if j == 0 || sym != s[j-1] {
+				uniq = append(uniq, sym)
+			}
+		}
+		res[i] = build.Load(m, uniq...)
+	}
+	return res
+}
+
+// File returns the syntax tree of the BUILD file: the loads, then
+// the package() and licenses() calls, if any, then the rules.
+func (p *Package) File() (*syntax.File, error) {
+	stmts := p.loadStmts()
+
+	attrs := make(map[string]interface{}, len(p.PackageAttrs)+1)
+	for k, v := range p.PackageAttrs {
+		attrs[k] = v
+	}
+	if len(p.DefaultVisibility) > 0 {
+		if _, ok := attrs["default_visibility"]; ok {
+			return nil, fmt.Errorf("package: default_visibility must be set with the DefaultVisibility field")
+		}
+		attrs["default_visibility"] = Labels(p.DefaultVisibility)
+	}
+	if len(attrs) > 0 {
+		args, err := kwargs(attrs)
+		if err != nil {
+			return nil, fmt.Errorf("package: %w", err)
+		}
+		stmts = append(stmts, build.Expr(&syntax.CallExpr{Fn: build.Ident("package"), Args: args}))
+	}
+	if len(p.Licenses) > 0 {
+		stmts = append(stmts, build.Expr(build.Call("licenses", build.Value(p.Licenses))))
+	}
+
+	for _, r := range p.Rules {
+		call, err := r.Call()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, build.Expr(call))
+	}
+	return build.File(stmts...), nil
+}
+
+// defaultOptions render the calls and the lists the way BUILD files are
+// usually formatted, the statements are separated with blank lines, except
+// for the consecutive loads.
+var defaultOptions = []starlarkgen.Option{
+	starlarkgen.WithSpaceEqBinary(true),
+	starlarkgen.WithCallOption(starlarkgen.CallOptionMultilineMultipleCommaTwoAndMore),
+	starlarkgen.WithListOption(starlarkgen.ListOptionMultilineMultipleCommaTwoAndMore),
+	starlarkgen.WithDictOption(starlarkgen.DictOptionMultilineMultipleCommaTwoAndMore),
+	starlarkgen.WithBlankLines(starlarkgen.BlankLinePolicy{
+		AfterModuleDocstring: 1,
+		AfterLoads:           1,
+		Defs:                 1,
+		Assignments:          1,
+		TopLevel:             1,
+	}),
+}
+
+// Write writes the BUILD file to the output with starlarkgen.WriteFile,
+// the options supplied are applied after the default ones. The statements
+// are separated with blank lines, except for the consecutive loads, use
+// starlarkgen.WithBlankLines to change it.
+// In case of an error incomplete results might be written to the output,
+// use Build to avoid handling partial input.
+func (p *Package) Write(output io.StringWriter, options ...starlarkgen.Option) error {
+	f, err := p.File()
+	if err != nil {
+		return err
+	}
+	opts := append(append([]starlarkgen.Option(nil), defaultOptions...), options...)
+	return starlarkgen.WriteFile(output, f, opts...)
+}
+
+// Build produces the BUILD file source, see Write.
+// In case of an error the string output is always empty.
+func (p *Package) Build(options ...starlarkgen.Option) (string, error) {
+	var sb strings.Builder
+	if err := p.Write(&sb, options...); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,168 @@
+package bazel
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cyberpossum/starlarkgen"
+	"github.com/cyberpossum/starlarkgen/build"
+)
+
+func TestLabelLess(t *testing.T) {
+	got := sortLabels([]string{"@repo//x", "//pkg:b", "foo.go", ":local", "//pkg:a", ":local", "bar.go"})
+	want := []string{":local", "bar.go", "foo.go", "//pkg:a", "//pkg:b", "@repo//x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestPackage_Build(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   *Package
+		options []starlarkgen.Option
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "empty",
+			input: &Package{},
+			want:  "",
+		},
+		{
+			name: "full",
+			input: &Package{
+				Loads:             map[string][]string{"//tools:defs.bzl": {"helper"}, "@rules_cc//cc:defs.bzl": {"cc_binary"}},
+				DefaultVisibility: []string{"//visibility:public"},
+				Licenses:          []string{"notice"},
+				Rules: []*Rule{
+					{
+						Kind: "cc_library",
+						Load: "@rules_cc//cc:defs.bzl",
+						Name: "foo",
+						Attrs: map[string]interface{}{
+							"visibility": []string{"//visibility:private"},
+							"linkstatic": true,
+							"copts":      []string{"-Wall", "-O2"},
+							"deps":       []string{"@zlib//:z", "//base", ":bar", "//base"},
+							"hdrs":       []string{"foo.h"},
+							"srcs":       []string{"foo.cc", "foo_impl.cc"},
+						},
+					},
+					{
+						Kind:  "cc_library",
+						Load:  "@rules_cc//cc:defs.bzl",
+						Name:  "bar",
+						Attrs: map[string]interface{}{"srcs": build.Call("glob", []string{"*.cc"})},
+					},
+					{
+						Kind:  "native.filegroup",
+						Name:  "all",
+						Attrs: map[string]interface{}{"data": Labels{":foo", ":bar"}},
+					},
+				},
+			},
+			want: `load("//tools:defs.bzl", "helper")
+load("@rules_cc//cc:defs.bzl", "cc_binary", "cc_library")
+
+package(default_visibility = ["//visibility:public"])
+
+licenses(["notice"])
+
+cc_library(
+    name = "foo",
+    srcs = [
+        "foo.cc",
+        "foo_impl.cc",
+    ],
+    hdrs = ["foo.h"],
+    deps = [
+        ":bar",
+        "//base",
+        "@zlib//:z",
+    ],
+    copts = [
+        "-Wall",
+        "-O2",
+    ],
+    linkstatic = True,
+    visibility = ["//visibility:private"],
+)
+
+cc_library(
+    name = "bar",
+    srcs = glob(["*.cc"]),
+)
+
+native.filegroup(
+    name = "all",
+    data = [
+        ":bar",
+        ":foo",
+    ],
+)
+`,
+		},
+		{
+			name:    "options",
+			input:   &Package{Rules: []*Rule{{Kind: "filegroup", Name: "foo", Attrs: map[string]interface{}{"srcs": []string{"b", "a"}}}}},
+			options: []starlarkgen.Option{starlarkgen.WithSpaceEqBinary(false), starlarkgen.WithListOption(starlarkgen.ListOptionSingleLine)},
+			want:    "filegroup(\n    name=\"foo\",\n    srcs=[\"a\", \"b\"],\n)\n",
+		},
+		{
+			name: "blank lines",
+			input: &Package{
+				Loads: map[string][]string{"//a.bzl": {"a"}},
+				Rules: []*Rule{{Kind: "a", Name: "foo"}, {Kind: "a", Name: "bar"}},
+			},
+			options: []starlarkgen.Option{starlarkgen.WithBlankLines(starlarkgen.BlankLinePolicy{AfterLoads: 2})},
+			want:    "load(\"//a.bzl\", \"a\")\n\n\na(name = \"foo\")\na(name = \"bar\")\n",
+		},
+		{
+			name:    "style",
+			input:   &Package{Rules: []*Rule{{Kind: "filegroup", Name: "foo", Attrs: map[string]interface{}{"size": build.Tuple(1, 2)}}}},
+			options: []starlarkgen.Option{starlarkgen.WithStyle(starlarkgen.StyleBuildifier)},
+			want:    "filegroup(\n    name = \"foo\",\n    size = (\n        1,\n        2,\n    ),\n)\n",
+		},
+		{
+			name:    "name attribute",
+			input:   &Package{Rules: []*Rule{{Kind: "filegroup", Name: "foo", Attrs: map[string]interface{}{"name": "bar"}}}},
+			wantErr: `rule "foo": name must be set with the Name field`,
+		},
+		{
+			name:    "empty kind",
+			input:   &Package{Rules: []*Rule{{Name: "foo"}}},
+			wantErr: `rule "foo": empty kind`,
+		},
+		{
+			name:    "unsupported value",
+			input:   &Package{Rules: []*Rule{{Kind: "filegroup", Name: "foo", Attrs: map[string]interface{}{"data": func() {}}}}},
+			wantErr: `rule "foo": attribute data: unsupported value kind func of type func()`,
+		},
+		{
+			name:    "default visibility in attributes",
+			input:   &Package{DefaultVisibility: []string{"//visibility:public"}, PackageAttrs: map[string]interface{}{"default_visibility": []string{}}},
+			wantErr: "package: default_visibility must be set with the DefaultVisibility field",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.input.Build(tt.options...)
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				if gotErr := err.Error(); gotErr != tt.wantErr {
+					t.Fatalf("expected error %q, got %q", tt.wantErr, gotErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("want:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}
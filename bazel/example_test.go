@@ -0,0 +1,43 @@
+package bazel_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/cyberpossum/starlarkgen/bazel"
+)
+
+func Example() {
+	pkg := &bazel.Package{
+		DefaultVisibility: []string{"//visibility:public"},
+		Rules: []*bazel.Rule{{
+			Kind: "go_library",
+			Load: "@io_bazel_rules_go//go:def.bzl",
+			Name: "foo",
+			Attrs: map[string]interface{}{
+				"importpath": "example.com/foo",
+				"srcs":       []string{"foo.go"},
+				"deps":       []string{"//bar", ":baz", "//bar"},
+			},
+		}},
+	}
+
+	out, err := pkg.Build()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(out)
+	// Output: load("@io_bazel_rules_go//go:def.bzl", "go_library")
+	//
+	// package(default_visibility = ["//visibility:public"])
+	//
+	// go_library(
+	//     name = "foo",
+	//     srcs = ["foo.go"],
+	//     deps = [
+	//         ":baz",
+	//         "//bar",
+	//     ],
+	//     importpath = "example.com/foo",
+	// )
+}
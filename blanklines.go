@@ -0,0 +1,141 @@
+package starlarkgen
+
+import (
+	"fmt"
+
+	"go.starlark.net/syntax"
+)
+
+// BlankLinePolicy sets the number of the blank lines between the statements,
+// see WithBlankLines. When several fields apply, the first one listed wins.
+type BlankLinePolicy struct {
+	// AfterModuleDocstring is the number of the blank lines after the docstring
+	// of the file.
+	AfterModuleDocstring int
+	// AfterLoads is the number of the blank lines after the block of the load
+	// statements. The consecutive load statements are not separated.
+	AfterLoads int
+	// Defs is the number of the blank lines around the top-level def
+	// statements.
+	Defs int
+	// Assignments is the number of the blank lines between the consecutive
+	// top-level assignments, e.g. zero keeps the runs of them grouped.
+	Assignments int
+	// TopLevel is the number of the blank lines between the other top-level
+	// statements.
+	TopLevel int
+	// AfterDocstring is the number of the blank lines after the docstring of
+	// the def statement.
+	AfterDocstring int
+	// Nested is the number of the blank lines between the other statements of
+	// the def, if, for and while bodies.
+	Nested int
+}
+
+// WithBlankLines sets the policy of the blank lines between the statements,
+// instead of the one of the style, see WithStyle. E.g. the default style is
+// the same as
+//   BlankLinePolicy{AfterModuleDocstring: 1, AfterLoads: 1, Defs: 1, Assignments: 1, TopLevel: 1}
+// except the consecutive load statements are separated. The minified output
+// has no blank lines regardless, see WithMinify.
+func WithBlankLines(policy BlankLinePolicy) Option {
+	return func(o *outputOpts) (*outputOpts, error) {
+		for _, n := range []int{
+			policy.AfterModuleDocstring, policy.AfterLoads, policy.Defs, policy.Assignments,
+			policy.TopLevel, policy.AfterDocstring, policy.Nested,
+		} {
+			if n < 0 {
+				return nil, fmt.Errorf("invalid blank lines value %d, value must be >= 0", n)
+			}
+		}
+		c := o.copy()
+		c.blankLinePolicy = &policy
+		return c, nil
+	}
+}
+
+// lines returns the number of the blank lines the policy puts before
+// the statement of the list at the index i > 0, in the block of the level.
+func (p *BlankLinePolicy) lines(input []syntax.Stmt, i int, level int, block string) int {
+	prev, next := input[i-1], input[i]
+	if level > 0 {
+		if block == "def" && i == 1 && docstringStmt(prev) {
+			return p.AfterDocstring
+		}
+		return p.Nested
+	}
+
+	_, prevLoad := prev.(*syntax.LoadStmt)
+	_, nextLoad := next.(*syntax.LoadStmt)
+	_, prevDef := prev.(*syntax.DefStmt)
+	_, nextDef := next.(*syntax.DefStmt)
+	_, prevAssign := prev.(*syntax.AssignStmt)
+	_, nextAssign := next.(*syntax.AssignStmt)
+	switch {
+	case i == 1 && docstringStmt(prev):
+		return p.AfterModuleDocstring
+	case prevLoad && nextLoad:
+		return 0
+	case prevLoad:
+		return p.AfterLoads
+	case prevDef || nextDef:
+		return p.Defs
+	case prevAssign && nextAssign:
+		return p.Assignments
+	}
+	return p.TopLevel
+}
+
+// docstringStmt reports whether the statement is the string literal, i.e.
+// the docstring, if it is the first one of the file or the def statement.
+func docstringStmt(st syntax.Stmt) bool {
+	if es, ok := st.(*syntax.ExprStmt); ok {
+		if lt, ok := es.X.(*syntax.Literal); ok {
+			_, ok := lt.Value.(string)
+			return ok
+		}
+	}
+	return false
+}
+
+// WithPreservedBlankLines keeps the blank lines separating the statements
+// in the source, e.g. the ones grouping the statements of the def bodies or
+// the assignments, if the statements carry the positions, see syntax.Parse.
+// At most max blank lines are kept, the statements without the positions
+// are separated as set by the style or the policy, see WithStyle and
+// WithBlankLines. The zero max disables the preserving, which is the
+// default. The minified output has no blank lines regardless, see WithMinify.
+func WithPreservedBlankLines(max int) Option {
+	return func(o *outputOpts) (*outputOpts, error) {
+		if max < 0 {
+			return nil, fmt.Errorf("invalid blank lines value %d, value must be >= 0", max)
+		}
+		c := o.copy()
+		c.preserveBlankLines = max > 0
+		c.maxBlankLines = max
+		return c, nil
+	}
+}
+
+// originalBlankLines returns the number of the blank lines between the
+// statements in the source, the lines of the comments retained are not
+// counted. The result is false if the statements have no positions or are
+// out of order.
+func originalBlankLines(prev, next syntax.Stmt) (int, bool) {
+	_, end := nodeSpan(prev)
+	start, _ := nodeSpan(next)
+	if !end.IsValid() || !start.IsValid() || start.Line <= end.Line {
+		return 0, false
+	}
+	n := int(start.Line-end.Line) - 1
+	if c := prev.Comments(); c != nil {
+		n -= len(c.After)
+	}
+	if c := next.Comments(); c != nil {
+		n -= len(c.Before)
+	}
+	if n < 0 {
+		n = 0
+	}
+	return n, true
+}
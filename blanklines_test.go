@@ -0,0 +1,291 @@
+package starlarkgen
+
+import (
+	"testing"
+
+	"go.starlark.net/syntax"
+)
+
+func TestWithBlankLines(t *testing.T) {
+	const src = `"""Module docstring."""
+load(":a.bzl", "a")
+load(":b.bzl", "b")
+X = 1
+Y = 2
+def f(x):
+    """Docstring."""
+    if x:
+        y = x
+        return y
+    return X
+Z = f(Y)
+print(Z)
+`
+	tests := []struct {
+		name    string
+		options []Option
+		want    string
+	}{
+		{
+			name: "policy",
+			options: []Option{WithBlankLines(BlankLinePolicy{
+				AfterModuleDocstring: 1,
+				AfterLoads:           1,
+				Defs:                 2,
+				TopLevel:             1,
+				AfterDocstring:       1,
+				Nested:               0,
+			})},
+			want: `"""Module docstring."""
+
+load(":a.bzl", "a")
+load(":b.bzl", "b")
+
+X = 1
+Y = 2
+
+
+def f(x):
+    """Docstring."""
+
+    if x:
+        y = x
+        return y
+    return X
+
+
+Z = f(Y)
+
+print(Z)
+`,
+		},
+		{
+			name: "nested and assignments",
+			options: []Option{WithBlankLines(BlankLinePolicy{
+				Assignments: 1,
+				Nested:      1,
+			})},
+			want: `"""Module docstring."""
+load(":a.bzl", "a")
+load(":b.bzl", "b")
+X = 1
+
+Y = 2
+def f(x):
+    """Docstring."""
+    if x:
+        y = x
+
+        return y
+
+    return X
+Z = f(Y)
+print(Z)
+`,
+		},
+		{
+			name:    "zero policy",
+			options: []Option{WithBlankLines(BlankLinePolicy{})},
+			want:    src,
+		},
+		{
+			name:    "minify overrides the policy",
+			options: []Option{WithBlankLines(BlankLinePolicy{TopLevel: 1, Nested: 1}), WithMinify(true)},
+			want: `"Module docstring.";load(":a.bzl","a");load(":b.bzl","b");X=1;Y=2
+def f(x):
+ "Docstring."
+ if x:y=x;return y
+ return X
+Z=f(Y);print(Z)
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := syntax.Parse("test.star", src, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := StarlarkFile(f, tt.options...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("want\n%s\ngot\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWithBlankLines_invalid(t *testing.T) {
+	_, err := StarlarkStmt(&syntax.BranchStmt{Token: syntax.PASS}, WithBlankLines(BlankLinePolicy{Nested: -1}))
+	const want = "invalid blank lines value -1, value must be >= 0"
+	if err == nil || err.Error() != want {
+		t.Errorf("expected error %q, got %v", want, err)
+	}
+}
+
+func TestWithPreservedBlankLines(t *testing.T) {
+	const src = `load(":a.bzl", "a")
+X = 1
+Y = 2
+
+
+# comment
+
+Z = 3
+def f(x):
+    y = x
+
+    if y:
+        y += 1
+
+
+
+        return y
+    return X
+`
+	tests := []struct {
+		name    string
+		options []Option
+		want    string
+	}{
+		{
+			name:    "preserved",
+			options: []Option{WithPreservedBlankLines(2), WithBlankLines(BlankLinePolicy{})},
+			want: `load(":a.bzl", "a")
+X = 1
+Y = 2
+
+
+Z = 3
+def f(x):
+    y = x
+
+    if y:
+        y += 1
+
+
+        return y
+    return X
+`,
+		},
+		{
+			name:    "capped",
+			options: []Option{WithPreservedBlankLines(1), WithBlankLines(BlankLinePolicy{})},
+			want: `load(":a.bzl", "a")
+X = 1
+Y = 2
+
+Z = 3
+def f(x):
+    y = x
+
+    if y:
+        y += 1
+
+        return y
+    return X
+`,
+		},
+		{
+			name:    "the style is overridden",
+			options: []Option{WithPreservedBlankLines(1), WithStyle(StyleBuildifier)},
+			want: `load(":a.bzl", "a")
+X = 1
+Y = 2
+
+# comment
+
+Z = 3
+def f(x):
+    y = x
+
+    if y:
+        y += 1
+
+        return y
+    return X
+`,
+		},
+		{
+			name:    "disabled",
+			options: []Option{WithPreservedBlankLines(3), WithPreservedBlankLines(0), WithBlankLines(BlankLinePolicy{})},
+			want: `load(":a.bzl", "a")
+X = 1
+Y = 2
+Z = 3
+def f(x):
+    y = x
+    if y:
+        y += 1
+        return y
+    return X
+`,
+		},
+		{
+			name:    "minify",
+			options: []Option{WithPreservedBlankLines(3), WithMinify(true)},
+			want: `load(":a.bzl","a");X=1;Y=2;Z=3
+def f(x):
+ y=x
+ if y:y+=1;return y
+ return X
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := syntax.Parse("test.star", src, syntax.RetainComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := StarlarkFile(f, tt.options...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("want\n%s\ngot\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWithPreservedBlankLines_grouping(t *testing.T) {
+	const src = "A = 1\nB = 2\n\nC = 3\n"
+	f, err := syntax.Parse("test.star", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the statement without the positions is separated as set by the style
+	f.Stmts = append(f.Stmts, &syntax.AssignStmt{Op: syntax.EQ, LHS: &syntax.Ident{Name: "D"}, RHS: &syntax.Literal{Token: syntax.INT, Value: 4}})
+	got, err := StarlarkFile(f, WithPreservedBlankLines(2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "A = 1\nB = 2\n\nC = 3\n\nD = 4\n"; got != want {
+		t.Errorf("want\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestWithPreservedBlankLines_noPositions(t *testing.T) {
+	input := &syntax.DefStmt{Name: &syntax.Ident{Name: "f"}, Body: []syntax.Stmt{
+		&syntax.BranchStmt{Token: syntax.PASS},
+		&syntax.BranchStmt{Token: syntax.PASS},
+	}}
+	got, err := StarlarkStmt(input, WithPreservedBlankLines(2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "def f():\n    pass\n    pass\n"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestWithPreservedBlankLines_invalid(t *testing.T) {
+	_, err := StarlarkStmt(&syntax.BranchStmt{Token: syntax.PASS}, WithPreservedBlankLines(-1))
+	const want = "invalid blank lines value -1, value must be >= 0"
+	if err == nil || err.Error() != want {
+		t.Errorf("expected error %q, got %v", want, err)
+	}
+}
@@ -0,0 +1,483 @@
+/*
+
+Package build provides concise constructors for go.starlark.net syntax tree
+nodes, producing trees which can be rendered with starlarkgen.
+
+	build.Call("cc_library",
+		build.Kw("name", "foo"),
+		build.Kw("deps", build.List(":bar", "//baz")),
+	)
+
+Functions accepting interface{} values convert them using Value, and panic
+on the unsupported value types, the same way regexp.MustCompile does for
+invalid expressions: the trees are usually built from the constant values
+known at compile time.
+
+*/
+package build
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/cyberpossum/starlarkgen/internal/lit"
+	"go.starlark.net/syntax"
+)
+
+// Ident returns an identifier node.
+func Ident(name string) *syntax.Ident {
+	return &syntax.Ident{Name: name}
+}
+
+// Name returns an identifier node, or a chain of dot expressions if the
+// name contains dots, e.g. "native.cc_library".
+func Name(name string) syntax.Expr {
+	parts := strings.Split(name, ".")
+	var x syntax.Expr = Ident(parts[0])
+	for _, p := range parts[1:] {
+		x = &syntax.DotExpr{X: x, Name: Ident(p)}
+	}
+	return x
+}
+
+// Str returns a string literal node.
+func Str(value string) *syntax.Literal {
+	return &syntax.Literal{Token: syntax.STRING, Value: value}
+}
+
+// Int returns an integer literal node.
+func Int(value int64) *syntax.Literal {
+	return &syntax.Literal{Token: syntax.INT, Value: value}
+}
+
+// BigInt returns an integer literal node for the arbitrary precision value.
+func BigInt(value *big.Int) *syntax.Literal {
+	if value == nil {
+		panic("build: nil *big.Int value")
+	}
+	return &syntax.Literal{Token: syntax.INT, Value: new(big.Int).Set(value)}
+}
+
+// Float returns a float literal node. Infinite and NaN values have no
+// literal form and cause a panic.
+func Float(value float64) *syntax.Literal {
+	return floatValue(value, 64)
+}
+
+// floatValue converts the float the same way FromGo does, with the shortest
+// representation for the bit size.
+func floatValue(value float64, bitSize int) *syntax.Literal {
+	lt, err := lit.Float(value, bitSize)
+	if err != nil {
+		panic("build: " + err.Error())
+	}
+	return lt
+}
+
+// Bool returns the True or False identifier.
+func Bool(value bool) *syntax.Ident {
+	if value {
+		return Ident("True")
+	}
+	return Ident("False")
+}
+
+// None returns the None identifier.
+func None() *syntax.Ident {
+	return Ident("None")
+}
+
+// Value converts v to an expression:
+//   - syntax.Expr values are returned as is
+//   - nil is converted to None
+//   - bool is converted to True or False
+//   - string, integer and float types are converted to literals
+//   - []string and []syntax.Expr are converted to lists
+//
+// Value panics on any other type.
+func Value(v interface{}) syntax.Expr {
+	switch t := v.(type) {
+	case nil:
+		return None()
+	case syntax.Expr:
+		return t
+	case bool:
+		return Bool(t)
+	case string:
+		return Str(t)
+	case int:
+		return Int(int64(t))
+	case int8:
+		return Int(int64(t))
+	case int16:
+		return Int(int64(t))
+	case int32:
+		return Int(int64(t))
+	case int64:
+		return Int(t)
+	case uint:
+		return uintValue(uint64(t))
+	case uint8:
+		return Int(int64(t))
+	case uint16:
+		return Int(int64(t))
+	case uint32:
+		return Int(int64(t))
+	case uint64:
+		return uintValue(t)
+	case *big.Int:
+		return BigInt(t)
+	case float32:
+		return floatValue(float64(t), 32)
+	case float64:
+		return Float(t)
+	case []string:
+		elems := make([]syntax.Expr, len(t))
+		for i, s := range t {
+			elems[i] = Str(s)
+		}
+		return &syntax.ListExpr{List: elems}
+	case []syntax.Expr:
+		return &syntax.ListExpr{List: t}
+	default:
+		panic(fmt.Sprintf("build: unsupported value type %T", v))
+	}
+}
+
+func uintValue(v uint64) *syntax.Literal {
+	if v > math.MaxInt64 {
+		return BigInt(new(big.Int).SetUint64(v))
+	}
+	return Int(int64(v))
+}
+
+func values(vs []interface{}) []syntax.Expr {
+	if len(vs) == 0 {
+		return nil
+	}
+	res := make([]syntax.Expr, len(vs))
+	for i, v := range vs {
+		res[i] = Value(v)
+	}
+	return res
+}
+
+// target converts an assignment or loop target: strings are identifiers.
+func target(v interface{}) syntax.Expr {
+	if s, ok := v.(string); ok {
+		return Name(s)
+	}
+	return Value(v)
+}
+
+// List returns a list expression, the elements are converted with Value.
+func List(elems ...interface{}) *syntax.ListExpr {
+	return &syntax.ListExpr{List: values(elems)}
+}
+
+// Tuple returns a parenthesized tuple expression, the elements are converted
+// with Value.
+func Tuple(elems ...interface{}) *syntax.ParenExpr {
+	return &syntax.ParenExpr{X: &syntax.TupleExpr{List: values(elems)}}
+}
+
+// Entry returns a dict entry, the key and the value are converted with Value.
+func Entry(key, value interface{}) *syntax.DictEntry {
+	return &syntax.DictEntry{Key: Value(key), Value: Value(value)}
+}
+
+// Dict returns a dict expression.
+func Dict(entries ...*syntax.DictEntry) *syntax.DictExpr {
+	res := &syntax.DictExpr{}
+	for _, e := range entries {
+		res.List = append(res.List, e)
+	}
+	return res
+}
+
+// Kw returns a keyword argument name=value, the value is converted with Value.
+func Kw(name string, value interface{}) *syntax.BinaryExpr {
+	return &syntax.BinaryExpr{X: Ident(name), Op: syntax.EQ, Y: Value(value)}
+}
+
+// Star returns the *args argument or parameter. Star("") returns the bare *
+// parameter separating the keyword-only parameters.
+func Star(name string) *syntax.UnaryExpr {
+	if name == "" {
+		return &syntax.UnaryExpr{Op: syntax.STAR}
+	}
+	return &syntax.UnaryExpr{Op: syntax.STAR, X: Ident(name)}
+}
+
+// StarStar returns the **kwargs argument or parameter.
+func StarStar(name string) *syntax.UnaryExpr {
+	return &syntax.UnaryExpr{Op: syntax.STARSTAR, X: Ident(name)}
+}
+
+// Call returns a call expression of the named function, see Name for
+// the dotted names. The arguments are converted with Value.
+func Call(fn string, args ...interface{}) *syntax.CallExpr {
+	return CallExpr(Name(fn), args...)
+}
+
+// CallExpr returns a call expression of an arbitrary callee. The arguments
+// are converted with Value.
+func CallExpr(fn syntax.Expr, args ...interface{}) *syntax.CallExpr {
+	return &syntax.CallExpr{Fn: operand(fn, precPrimary), Args: values(args)}
+}
+
+// Dot returns the x.name expression, x is converted with Value.
+func Dot(x interface{}, name string) *syntax.DotExpr {
+	return &syntax.DotExpr{X: operand(Value(x), precPrimary), Name: Ident(name)}
+}
+
+// Index returns the x[y] expression, both operands are converted with Value.
+func Index(x, y interface{}) *syntax.IndexExpr {
+	return &syntax.IndexExpr{X: operand(Value(x), precPrimary), Y: Value(y)}
+}
+
+// precedence of the binary operators, the higher binds tighter, see
+// https://github.com/google/starlark-go/blob/master/doc/spec.md#binary-operators
+var precedence = map[syntax.Token]int{
+	syntax.OR:         1,
+	syntax.AND:        2,
+	syntax.EQL:        4,
+	syntax.NEQ:        4,
+	syntax.LT:         4,
+	syntax.GT:         4,
+	syntax.LE:         4,
+	syntax.GE:         4,
+	syntax.IN:         4,
+	syntax.NOT_IN:     4,
+	syntax.PIPE:       5,
+	syntax.CIRCUMFLEX: 6,
+	syntax.AMP:        7,
+	syntax.LTLT:       8,
+	syntax.GTGT:       8,
+	syntax.PLUS:       9,
+	syntax.MINUS:      9,
+	syntax.STAR:       10,
+	syntax.SLASH:      10,
+	syntax.SLASHSLASH: 10,
+	syntax.PERCENT:    10,
+}
+
+const (
+	precNot        = 3
+	precComparison = 4
+	precUnary      = 11
+	precPrimary    = 12
+)
+
+// exprPrec returns the precedence of the expression as an operand, the
+// conditional expressions, the lambdas and the bare tuples bind the weakest.
+func exprPrec(x syntax.Expr) int {
+	switch t := x.(type) {
+	case *syntax.BinaryExpr:
+		return precedence[t.Op]
+	case *syntax.UnaryExpr:
+		if t.Op == syntax.NOT {
+			return precNot
+		}
+		return precUnary
+	case *syntax.CondExpr, *syntax.LambdaExpr, *syntax.TupleExpr:
+		return 0
+	}
+	return precPrimary
+}
+
+// operand parenthesizes x if it binds weaker than prec.
+func operand(x syntax.Expr, prec int) syntax.Expr {
+	if exprPrec(x) < prec {
+		return Paren(x)
+	}
+	return x
+}
+
+// Binary returns the binary expression x op y, both operands are converted
+// with Value. The operands are parenthesized if the operator binds tighter,
+// e.g.
+//   Binary(Binary(1, syntax.PLUS, 2), syntax.STAR, 3)
+// renders as
+//   (1 + 2) * 3
+func Binary(x interface{}, op syntax.Token, y interface{}) *syntax.BinaryExpr {
+	prec, ok := precedence[op]
+	if !ok {
+		return &syntax.BinaryExpr{X: Value(x), Op: op, Y: Value(y)}
+	}
+	// the operators associate to the left, the comparisons don't associate
+	xPrec := prec
+	if prec == precComparison {
+		xPrec++
+	}
+	return &syntax.BinaryExpr{X: operand(Value(x), xPrec), Op: op, Y: operand(Value(y), prec+1)}
+}
+
+// Unary returns the unary expression, e.g. not x or -x. The operand is
+// parenthesized if the operator binds tighter.
+func Unary(op syntax.Token, x interface{}) *syntax.UnaryExpr {
+	v := Value(x)
+	switch op {
+	case syntax.NOT:
+		v = operand(v, precNot)
+	case syntax.MINUS, syntax.PLUS, syntax.TILDE:
+		v = operand(v, precUnary)
+	}
+	return &syntax.UnaryExpr{Op: op, X: v}
+}
+
+// Paren returns the parenthesized expression.
+func Paren(x syntax.Expr) *syntax.ParenExpr {
+	return &syntax.ParenExpr{X: x}
+}
+
+// Params converts the def parameters: strings are converted to identifiers,
+// syntax.Expr values (e.g. Kw, Star or StarStar) are kept as is.
+func Params(params ...interface{}) []syntax.Expr {
+	if len(params) == 0 {
+		return nil
+	}
+	res := make([]syntax.Expr, len(params))
+	for i, p := range params {
+		switch t := p.(type) {
+		case string:
+			res[i] = Ident(t)
+		case syntax.Expr:
+			res[i] = t
+		default:
+			panic(fmt.Sprintf("build: unsupported parameter type %T", p))
+		}
+	}
+	return res
+}
+
+// Def returns a function definition statement, see Params for the
+// parameters conversion.
+func Def(name string, params []syntax.Expr, body ...syntax.Stmt) *syntax.DefStmt {
+	return &syntax.DefStmt{Name: Ident(name), Params: params, Body: body}
+}
+
+// Doc returns the docstring statement.
+func Doc(text string) *syntax.ExprStmt {
+	return &syntax.ExprStmt{X: Str(text)}
+}
+
+// Expr returns the expression statement.
+func Expr(x syntax.Expr) *syntax.ExprStmt {
+	return &syntax.ExprStmt{X: x}
+}
+
+// Assign returns the lhs = rhs statement, the string lhs values are
+// converted to identifiers, rhs is converted with Value.
+func Assign(lhs, rhs interface{}) *syntax.AssignStmt {
+	return AssignOp(lhs, syntax.EQ, rhs)
+}
+
+// AssignOp returns the augmented assignment statement, e.g. lhs += rhs.
+func AssignOp(lhs interface{}, op syntax.Token, rhs interface{}) *syntax.AssignStmt {
+	return &syntax.AssignStmt{LHS: target(lhs), Op: op, RHS: Value(rhs)}
+}
+
+// Return returns the return statement. Without results it renders as the
+// bare return, multiple results are returned as a tuple.
+func Return(results ...interface{}) *syntax.ReturnStmt {
+	switch len(results) {
+	case 0:
+		return &syntax.ReturnStmt{}
+	case 1:
+		return &syntax.ReturnStmt{Result: Value(results[0])}
+	default:
+		return &syntax.ReturnStmt{Result: &syntax.TupleExpr{List: values(results)}}
+	}
+}
+
+// Pass returns the pass statement.
+func Pass() *syntax.BranchStmt {
+	return &syntax.BranchStmt{Token: syntax.PASS}
+}
+
+// Break returns the break statement.
+func Break() *syntax.BranchStmt {
+	return &syntax.BranchStmt{Token: syntax.BREAK}
+}
+
+// Continue returns the continue statement.
+func Continue() *syntax.BranchStmt {
+	return &syntax.BranchStmt{Token: syntax.CONTINUE}
+}
+
+// For returns the for loop statement. String vars values are converted
+// to identifiers, x is converted with Value.
+func For(vars interface{}, x interface{}, body ...syntax.Stmt) *syntax.ForStmt {
+	return &syntax.ForStmt{Vars: target(vars), X: Value(x), Body: body}
+}
+
+// While returns the while loop statement.
+func While(cond syntax.Expr, body ...syntax.Stmt) *syntax.WhileStmt {
+	return &syntax.WhileStmt{Cond: cond, Body: body}
+}
+
+// Load returns the load statement. Each symbol is either a name, or
+// an alias=name pair:
+//
+//   Load("//foo:bar.bzl", "a", "b=c")
+//
+// renders as
+//
+//   load("//foo:bar.bzl", "a", b="c")
+func Load(module string, symbols ...string) *syntax.LoadStmt {
+	res := &syntax.LoadStmt{
+		Module: Str(module),
+		From:   make([]*syntax.Ident, len(symbols)),
+		To:     make([]*syntax.Ident, len(symbols)),
+	}
+	for i, s := range symbols {
+		to, from := s, s
+		if n := strings.IndexByte(s, '='); n >= 0 {
+			to, from = s[:n], s[n+1:]
+		}
+		res.From[i], res.To[i] = Ident(from), Ident(to)
+	}
+	return res
+}
+
+// IfBuilder builds the if/elif/else chain, see If.
+type IfBuilder struct {
+	root, last *syntax.IfStmt
+}
+
+// If starts the if statement, use Elif and Else to add the clauses and Stmt
+// to obtain the statement:
+//
+//   If(cond1, body1...).Elif(cond2, body2...).Else(body3...).Stmt()
+func If(cond syntax.Expr, body ...syntax.Stmt) *IfBuilder {
+	st := &syntax.IfStmt{Cond: cond, True: body}
+	return &IfBuilder{root: st, last: st}
+}
+
+// Elif adds the elif clause, which is represented as an if statement
+// nested in the else clause.
+func (b *IfBuilder) Elif(cond syntax.Expr, body ...syntax.Stmt) *IfBuilder {
+	st := &syntax.IfStmt{Cond: cond, True: body}
+	b.last.False = []syntax.Stmt{st}
+	b.last = st
+	return b
+}
+
+// Else sets the else clause of the innermost if statement.
+func (b *IfBuilder) Else(body ...syntax.Stmt) *IfBuilder {
+	b.last.False = body
+	return b
+}
+
+// Stmt returns the built if statement.
+func (b *IfBuilder) Stmt() *syntax.IfStmt {
+	return b.root
+}
+
+// File returns the file containing the statements.
+func File(stmts ...syntax.Stmt) *syntax.File {
+	return &syntax.File{Stmts: stmts}
+}
@@ -0,0 +1,187 @@
+package build
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/cyberpossum/starlarkgen"
+	"go.starlark.net/syntax"
+)
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name      string
+		inputExpr syntax.Expr
+		inputStmt syntax.Stmt
+		want      string
+	}{
+		{
+			name:      "dotted name",
+			inputExpr: Name("native.cc_library"),
+			want:      "native.cc_library",
+		},
+		{
+			name:      "call with kwargs",
+			inputExpr: Call("cc_library", Kw("name", "foo"), Kw("deps", List(":bar", "//baz")), Kw("linkstatic", true)),
+			want:      `cc_library(name="foo", deps=[":bar", "//baz"], linkstatic=True)`,
+		},
+		{
+			name:      "call with star args",
+			inputExpr: CallExpr(Dot(Str(", "), "join"), Star("args"), StarStar("kwargs")),
+			want:      `", ".join(*args, **kwargs)`,
+		},
+		{
+			name:      "literals",
+			inputExpr: List(1, int8(-2), uint(3), uint64(math.MaxUint64), 1.5, float32(2), float32(0.1), nil, false),
+			want:      "[1, -2, 3, 18446744073709551615, 1.5, 2.0, 0.1, None, False]",
+		},
+		{
+			name:      "big int",
+			inputExpr: BigInt(new(big.Int).Lsh(big.NewInt(1), 70)),
+			want:      "1180591620717411303424",
+		},
+		{
+			name:      "dict",
+			inputExpr: Dict(Entry("a", 1), Entry("b", []string{"c"})),
+			want:      `{"a": 1, "b": ["c"]}`,
+		},
+		{
+			name:      "tuple",
+			inputExpr: Tuple(1, "a"),
+			want:      `(1, "a")`,
+		},
+		{
+			name:      "single element tuple",
+			inputExpr: Tuple("a"),
+			want:      `("a",)`,
+		},
+		{
+			name:      "binary, unary and index",
+			inputExpr: Binary(Unary(syntax.NOT, Ident("a")), syntax.AND, Index(Ident("b"), 0)),
+			want:      "not a and b[0]",
+		},
+		{
+			name:      "precedence",
+			inputExpr: Binary(Binary(1, syntax.PLUS, 2), syntax.STAR, Binary(3, syntax.MINUS, 4)),
+			want:      "(1 + 2) * (3 - 4)",
+		},
+		{
+			name:      "left associativity",
+			inputExpr: Binary(Binary(1, syntax.MINUS, 2), syntax.MINUS, Binary(3, syntax.MINUS, 4)),
+			want:      "1 - 2 - (3 - 4)",
+		},
+		{
+			name:      "tighter operands",
+			inputExpr: Binary(Binary(1, syntax.STAR, 2), syntax.PLUS, Unary(syntax.MINUS, 3)),
+			want:      "1 * 2 + -3",
+		},
+		{
+			name:      "comparisons",
+			inputExpr: Binary(Binary(Ident("a"), syntax.LT, Ident("b")), syntax.EQL, Unary(syntax.NOT, Ident("c"))),
+			want:      "(a < b) == (not c)",
+		},
+		{
+			name:      "unary operands",
+			inputExpr: Unary(syntax.NOT, Binary(Unary(syntax.MINUS, Binary(Ident("a"), syntax.PLUS, 1)), syntax.IN, Ident("b"))),
+			want:      "not -(a + 1) in b",
+		},
+		{
+			name:      "primary operands",
+			inputExpr: Index(Dot(Binary(Ident("a"), syntax.OR, Ident("b")), "c"), Binary(1, syntax.PLUS, 2)),
+			want:      "(a or b).c[1 + 2]",
+		},
+		{
+			name:      "load",
+			inputStmt: Load("//foo:bar.bzl", "a", "b=c"),
+			want:      `load("//foo:bar.bzl", "a", b="c")` + "\n",
+		},
+		{
+			name: "def",
+			inputStmt: Def("foo", Params("a", Kw("b", 2), Star(""), "c", StarStar("kwargs")),
+				Doc("Does foo."),
+				Assign("x", Binary(Ident("a"), syntax.PLUS, Ident("b"))),
+				AssignOp("x", syntax.STAR_EQ, 2),
+				Return(Ident("x"), Ident("c")),
+			),
+			want: "def foo(a, b=2, *, c, **kwargs):\n" +
+				"    \"\"\"Does foo.\"\"\"\n" +
+				"    x = a + b\n" +
+				"    x *= 2\n" +
+				"    return x, c\n",
+		},
+		{
+			name: "if, elif, else",
+			inputStmt: If(Binary(Ident("a"), syntax.GT, 1), Return(1)).
+				Elif(Binary(Ident("a"), syntax.GT, 0), Return(0)).
+				Else(Return(-1)).
+				Stmt(),
+			want: "if a > 1:\n    return 1\nelse:\n    if a > 0:\n        return 0\n    else:\n        return -1\n",
+		},
+		{
+			name: "for and while",
+			inputStmt: For(Tuple(Ident("k"), Ident("v")), Call("d.items"),
+				While(Ident("k"), Break()),
+				If(Ident("v"), Pass()).Else(Continue()).Stmt(),
+				Expr(Call("print", Ident("k"))),
+				Pass(),
+				Return(),
+			),
+			want: "for (k, v) in d.items():\n" +
+				"    while k:\n" +
+				"        break\n" +
+				"    if v:\n" +
+				"        pass\n" +
+				"    else:\n" +
+				"        continue\n" +
+				"    print(k)\n" +
+				"    pass\n" +
+				"    return\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				got string
+				err error
+			)
+			if tt.inputExpr != nil {
+				got, err = starlarkgen.StarlarkExpr(tt.inputExpr)
+			} else {
+				got, err = starlarkgen.StarlarkStmt(tt.inputStmt)
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValue_panics(t *testing.T) {
+	tests := []struct {
+		name  string
+		input interface{}
+		want  string
+	}{
+		{name: "unsupported type", input: struct{}{}, want: "build: unsupported value type struct {}"},
+		{name: "infinite float", input: math.Inf(1), want: "build: float value +Inf has no literal form"},
+		{name: "nil big int", input: (*big.Int)(nil), want: "build: nil *big.Int value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					t.Fatal("expected a panic, got none")
+				}
+				if r != tt.want {
+					t.Errorf("expected panic %q, got %q", tt.want, r)
+				}
+			}()
+			Value(tt.input)
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package build_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/cyberpossum/starlarkgen"
+	"github.com/cyberpossum/starlarkgen/build"
+)
+
+func Example() {
+	f := build.File(
+		build.Load("@rules_cc//cc:defs.bzl", "cc_library"),
+		build.Expr(build.Call("cc_library",
+			build.Kw("name", "foo"),
+			build.Kw("srcs", []string{"foo.cc"}),
+			build.Kw("deps", build.List(":bar", "//baz")),
+		)),
+	)
+
+	for _, s := range f.Stmts {
+		st, err := starlarkgen.StarlarkStmt(s, starlarkgen.WithSpaceEqBinary(true), starlarkgen.WithCallOption(starlarkgen.CallOptionMultilineMultipleComma))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(st)
+	}
+	// Output: load("@rules_cc//cc:defs.bzl", "cc_library")
+	// cc_library(
+	//     name = "foo",
+	//     srcs = ["foo.cc"],
+	//     deps = [":bar", "//baz"],
+	// )
+}
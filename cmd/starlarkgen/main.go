@@ -0,0 +1,215 @@
+/*
+
+Command starlarkgen converts the data between Starlark and other formats.
+
+Usage:
+
+	starlarkgen json2star [flags] [file]
+	starlarkgen star2json [flags] [file]
+
+Modes:
+
+	json2star   convert the JSON value to the Starlark literal
+	star2json   convert the Starlark file of NAME = <literal> assignments,
+	            or the single expression with -expr, to JSON
+
+The input is read from the file, or from the standard input if the file is
+not provided. The output is written to the standard output.
+
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/cyberpossum/starlarkgen"
+	"go.starlark.net/syntax"
+)
+
+// layouts lists the layout flag values in the order of the rendering option
+// constants, which is the same for the dict, list, call and tuple options.
+var layouts = []string{
+	"SingleLine",
+	"SingleLineComma",
+	"SingleLineCommaTwoAndMore",
+	"MultilineMultiple",
+	"MultilineMultipleComma",
+	"MultilineMultipleCommaTwoAndMore",
+	"Multiline",
+	"MultilineComma",
+	"MultilineCommaTwoAndMore",
+}
+
+func layout(name string) (int, error) {
+	for i, l := range layouts {
+		if strings.EqualFold(l, name) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown layout %q, expected one of: %s", name, strings.Join(layouts, ", "))
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: starlarkgen json2star [flags] [file]")
+	fmt.Fprintln(w, "       starlarkgen star2json [flags] [file]")
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "starlarkgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		usage(stderr)
+		return fmt.Errorf("mode is required")
+	}
+
+	switch args[0] {
+	case "json2star":
+		return json2star(args[1:], stdin, stdout, stderr)
+	case "star2json":
+		return star2json(args[1:], stdin, stdout, stderr)
+	default:
+		usage(stderr)
+		return fmt.Errorf("unknown mode %q", args[0])
+	}
+}
+
+// input opens the file named by the only positional argument, or returns
+// stdin if there are none.
+func input(fs *flag.FlagSet, stdin io.Reader) (io.Reader, func(), error) {
+	switch fs.NArg() {
+	case 0:
+		return stdin, func() {}, nil
+	case 1:
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return nil, nil, err
+		}
+		return f, func() { f.Close() }, nil
+	default:
+		return nil, nil, fmt.Errorf("at most one input file expected, got %d", fs.NArg())
+	}
+}
+
+func json2star(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("json2star", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		dictLayout = fs.String("dict", "MultilineMultipleComma", "dict layout, one of: "+strings.Join(layouts, ", "))
+		listLayout = fs.String("list", "MultilineMultipleComma", "list layout, one of: "+strings.Join(layouts, ", "))
+		indent     = fs.String("indent", "    ", "indentation sequence")
+		varName    = fs.String("var", "", "render as assignment to the named variable instead of a bare expression")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	dl, err := layout(*dictLayout)
+	if err != nil {
+		return fmt.Errorf("-dict: %w", err)
+	}
+	ll, err := layout(*listLayout)
+	if err != nil {
+		return fmt.Errorf("-list: %w", err)
+	}
+
+	in, closeInput, err := input(fs, stdin)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	x, err := starlarkgen.FromJSON(in)
+	if err != nil {
+		return err
+	}
+
+	opts := []starlarkgen.Option{
+		starlarkgen.WithDictOption(starlarkgen.DictOption(dl)),
+		starlarkgen.WithListOption(starlarkgen.ListOption(ll)),
+		starlarkgen.WithIndent(*indent),
+	}
+	var out string
+	if *varName != "" {
+		out, err = starlarkgen.StarlarkStmt(&syntax.AssignStmt{
+			LHS: &syntax.Ident{Name: *varName},
+			Op:  syntax.EQ,
+			RHS: x,
+		}, opts...)
+	} else {
+		out, err = starlarkgen.StarlarkExpr(x, opts...)
+		out += "\n"
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(stdout, out)
+	return err
+}
+
+func star2json(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("star2json", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		indent   = fs.String("indent", "  ", "indentation sequence, empty for the compact output")
+		exprMode = fs.Bool("expr", false, "convert the single expression instead of the file of assignments")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	in, closeInput, err := input(fs, stdin)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+	src, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	filename := "<stdin>"
+	if fs.NArg() == 1 {
+		filename = fs.Arg(0)
+	}
+
+	var res []byte
+	if *exprMode {
+		x, err := syntax.ParseExpr(filename, src, 0)
+		if err != nil {
+			return err
+		}
+		if res, err = starlarkgen.ToJSON(x); err != nil {
+			return err
+		}
+	} else {
+		f, err := syntax.Parse(filename, src, 0)
+		if err != nil {
+			return err
+		}
+		if res, err = starlarkgen.FileToJSON(f); err != nil {
+			return err
+		}
+	}
+
+	if *indent != "" {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, res, "", *indent); err != nil {
+			return err
+		}
+		res = buf.Bytes()
+	}
+	_, err = stdout.Write(append(res, '\n'))
+	return err
+}
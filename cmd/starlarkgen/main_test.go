@@ -0,0 +1,130 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_run(t *testing.T) {
+	dir, err := ioutil.TempDir("", "starlarkgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	inputFile := filepath.Join(dir, "input.json")
+	if err := ioutil.WriteFile(inputFile, []byte(`[1, 2]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		stdin   string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "json2star, defaults",
+			args:  []string{"json2star"},
+			stdin: `{"name": "foo", "deps": ["a", "b"], "size": 12345678901234567890123, "ratio": 0.1, "ok": true, "none": null}`,
+			want: `{
+    "name": "foo",
+    "deps": [
+        "a",
+        "b",
+    ],
+    "size": 12345678901234567890123,
+    "ratio": 0.1,
+    "ok": True,
+    "none": None,
+}
+`,
+		},
+		{
+			name:  "json2star, variable and single line layouts",
+			args:  []string{"json2star", "-var", "DATA", "-dict", "singleline", "-list", "SingleLine"},
+			stdin: `{"a": [1, 2]}`,
+			want:  "DATA = {\"a\": [1, 2]}\n",
+		},
+		{
+			name: "json2star, input file",
+			args: []string{"json2star", "-list", "SingleLine", inputFile},
+			want: "[1, 2]\n",
+		},
+		{
+			name:    "json2star, unknown layout",
+			args:    []string{"json2star", "-dict", "foo"},
+			wantErr: `-dict: unknown layout "foo", expected one of: SingleLine, SingleLineComma, SingleLineCommaTwoAndMore, MultilineMultiple, MultilineMultipleComma, MultilineMultipleCommaTwoAndMore, Multiline, MultilineComma, MultilineCommaTwoAndMore`,
+		},
+		{
+			name:    "json2star, invalid JSON",
+			args:    []string{"json2star"},
+			stdin:   `[1, x]`,
+			wantErr: "element 1: reading JSON: invalid character 'x' looking for beginning of value",
+		},
+		{
+			name:  "star2json, defaults",
+			args:  []string{"star2json"},
+			stdin: "NAME = \"foo\"\nDEPS = [\"b\", \"a\"]\n",
+			want: `{
+  "NAME": "foo",
+  "DEPS": [
+    "b",
+    "a"
+  ]
+}
+`,
+		},
+		{
+			name:  "star2json, compact expression",
+			args:  []string{"star2json", "-expr", "-indent", ""},
+			stdin: `{"b": 1, "a": (True, None)}`,
+			want:  "{\"b\":1,\"a\":[true,null]}\n",
+		},
+		{
+			name:    "star2json, non-literal",
+			args:    []string{"star2json"},
+			stdin:   "NAME = \"foo\"\nDEPS = [NAME]\n",
+			wantErr: `<stdin>:2:9: DEPS: element 0: unsupported identifier "NAME", only True, False and None are allowed`,
+		},
+		{
+			name:    "star2json, syntax error",
+			args:    []string{"star2json"},
+			stdin:   "NAME = \n",
+			wantErr: "<stdin>:2:1: got newline, want primary expression",
+		},
+		{
+			name:    "no mode",
+			wantErr: "mode is required",
+		},
+		{
+			name:    "unknown mode",
+			args:    []string{"foo"},
+			wantErr: `unknown mode "foo"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr strings.Builder
+			err := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				if gotErr := err.Error(); gotErr != tt.wantErr {
+					t.Fatalf("expected error %q, got %q", tt.wantErr, gotErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got := stdout.String(); got != tt.want {
+				t.Errorf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,732 @@
+package starlarkgen
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"go.starlark.net/syntax"
+)
+
+// commentMap holds the comments rendered with the buildifier styles. The
+// parser attaches the comments after a block or before a closing bracket to
+// the next node, so the comments with the positions are placed anew, the way
+// buildifier attaches them, and the ones without the positions are kept on
+// their nodes.
+type commentMap struct {
+	// before are the line comments put before the statement or the sequence
+	// element, the empty comments are the blank lines
+	before map[syntax.Node][]syntax.Comment
+	// after are the line comments put after the statement
+	after map[syntax.Node][]syntax.Comment
+	// blocks are the comment blocks separated with the blank lines put before
+	// the statement, trailing the ones after the last statement of the block
+	blocks   map[syntax.Node][][]syntax.Comment
+	trailing map[syntax.Node][][]syntax.Comment
+	// suffix are the end of line comments put after the node
+	suffix map[syntax.Node][]syntax.Comment
+	// end are the line comments put before the closing bracket of
+	// the sequence, or the parenthesis of the def parameters
+	end map[syntax.Node][]syntax.Comment
+	// elseSuffix are the end of line comments of the else clauses
+	elseSuffix map[*syntax.IfStmt][]syntax.Comment
+	// file are the comment blocks of the file without statements
+	file [][]syntax.Comment
+
+	// pending are the end of line comments written at the next line break
+	pending []syntax.Comment
+}
+
+// newCommentMap places the comments of the syntax tree, nil is returned if
+// there are no comments.
+func newCommentMap(root syntax.Node) *commentMap {
+	cm := &commentMap{
+		before:     make(map[syntax.Node][]syntax.Comment),
+		after:      make(map[syntax.Node][]syntax.Comment),
+		blocks:     make(map[syntax.Node][][]syntax.Comment),
+		trailing:   make(map[syntax.Node][][]syntax.Comment),
+		suffix:     make(map[syntax.Node][]syntax.Comment),
+		end:        make(map[syntax.Node][]syntax.Comment),
+		elseSuffix: make(map[*syntax.IfStmt][]syntax.Comment),
+	}
+	var (
+		lines    []syntax.Comment
+		suffixes []syntax.Comment
+		owners   []syntax.Node
+		ifs      []*syntax.IfStmt
+		found    bool
+	)
+	// the names loaded without the alias are both From and To
+	seen := make(map[syntax.Node]bool)
+	syntax.Walk(root, func(n syntax.Node) bool {
+		if n == nil || seen[n] {
+			return true
+		}
+		seen[n] = true
+		if t, ok := n.(*syntax.IfStmt); ok {
+			ifs = append(ifs, t)
+		}
+		c := n.Comments()
+		if c == nil {
+			return true
+		}
+		for _, list := range [][]syntax.Comment{c.Before, c.After} {
+			for _, cm := range list {
+				if cm.Start.IsValid() {
+					lines = append(lines, cm)
+				}
+			}
+		}
+		for _, cm := range c.Suffix {
+			if cm.Start.IsValid() {
+				suffixes = append(suffixes, cm)
+				owners = append(owners, n)
+			}
+		}
+		found = found || len(c.Before)+len(c.Suffix)+len(c.After) > 0
+		cm.attach(n, c)
+		return true
+	})
+	if !found {
+		return nil
+	}
+
+	for i, c := range suffixes {
+		cm.placeSuffix(c, owners[i], ifs)
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return posBefore(lines[i].Start, lines[j].Start) })
+	switch t := root.(type) {
+	case *syntax.File:
+		cm.placeBlock(t.Stmts, lines)
+	case syntax.Stmt:
+		cm.placeBlock([]syntax.Stmt{t}, lines)
+	case syntax.Expr:
+		cm.placeExprs([]syntax.Node{t}, nil, t, lines)
+	}
+	return cm
+}
+
+// attach keeps the comments without the positions on their node, the ones
+// of the file are the blocks before the first statement and after the last.
+func (cm *commentMap) attach(n syntax.Node, c *syntax.Comments) {
+	before, suffix, after := unpositioned(c.Before), unpositioned(c.Suffix), unpositioned(c.After)
+	f, ok := n.(*syntax.File)
+	switch {
+	case !ok:
+		cm.before[n] = append(cm.before[n], before...)
+		cm.suffix[n] = append(cm.suffix[n], suffix...)
+		cm.after[n] = append(cm.after[n], after...)
+	case len(f.Stmts) == 0:
+		for _, list := range [][]syntax.Comment{before, suffix, after} {
+			if len(list) > 0 {
+				cm.file = append(cm.file, list)
+			}
+		}
+	default:
+		first, last := f.Stmts[0], f.Stmts[len(f.Stmts)-1]
+		if lead := append(before, suffix...); len(lead) > 0 {
+			cm.blocks[first] = append([][]syntax.Comment{lead}, cm.blocks[first]...)
+		}
+		if len(after) > 0 {
+			cm.trailing[last] = append(cm.trailing[last], after)
+		}
+	}
+}
+
+func unpositioned(list []syntax.Comment) []syntax.Comment {
+	var res []syntax.Comment
+	for _, c := range list {
+		if !c.Start.IsValid() {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// placeSuffix keeps the end of line comment on the node the parser attached
+// it to, the ones of the block statements go to their last simple
+// statement. The comments of the else clauses are attached to the node
+// ending on the line before, they are kept for the else clause.
+func (cm *commentMap) placeSuffix(c syntax.Comment, owner syntax.Node, ifs []*syntax.IfStmt) {
+	for {
+		st, ok := owner.(syntax.Stmt)
+		if !ok {
+			break
+		}
+		body := lastBody(st)
+		if len(body) == 0 {
+			break
+		}
+		owner = body[len(body)-1]
+	}
+	if _, end := nodeSpan(owner); end.Line != c.Start.Line {
+		for _, t := range ifs {
+			if t.ElsePos.Line == c.Start.Line && len(t.False) > 0 {
+				cm.elseSuffix[t] = append(cm.elseSuffix[t], c)
+				return
+			}
+		}
+	}
+	cm.suffix[owner] = append(cm.suffix[owner], c)
+}
+
+func posBefore(a, b syntax.Position) bool {
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Col < b.Col
+}
+
+// placeBlock places the line comments located within the block of
+// the statements: the comments inside the statements go to the statements,
+// the comments between the statements are attached to the adjacent ones or
+// become the comment blocks. The comments following the block statement are
+// the trailing comments of its last block, unless they are dedented.
+func (cm *commentMap) placeBlock(stmts []syntax.Stmt, lines []syntax.Comment) {
+	if len(lines) == 0 {
+		return
+	}
+	if len(stmts) == 0 {
+		cm.file = append(cm.file, lineGroups(lines)...)
+		return
+	}
+	inside := make([][]syntax.Comment, len(stmts))
+	gaps := make([][]syntax.Comment, len(stmts)+1)
+	for _, c := range lines {
+		k := sort.Search(len(stmts), func(i int) bool {
+			start, _ := nodeSpan(stmts[i])
+			return posBefore(c.Start, start)
+		})
+		if k > 0 {
+			if _, end := nodeSpan(stmts[k-1]); posBefore(c.Start, end) || len(gaps[k]) == 0 && nestedComment(stmts[k-1], c) {
+				inside[k-1] = append(inside[k-1], c)
+				continue
+			}
+		}
+		gaps[k] = append(gaps[k], c)
+	}
+	for i, st := range stmts {
+		if len(inside[i]) > 0 {
+			cm.placeStmt(st, inside[i])
+		}
+	}
+	for k, gap := range gaps {
+		if len(gap) > 0 {
+			cm.placeGap(stmts, k, gap)
+		}
+	}
+}
+
+// nestedComment reports whether the comment following the block statement
+// is indented as its last block.
+func nestedComment(st syntax.Stmt, c syntax.Comment) bool {
+	body := lastBody(st)
+	if len(body) == 0 {
+		return false
+	}
+	start, _ := nodeSpan(body[0])
+	return c.Start.Col >= start.Col
+}
+
+// lastBody returns the last block of the block statement, the one of
+// the last elif clause for the if statement.
+func lastBody(st syntax.Stmt) []syntax.Stmt {
+	switch t := st.(type) {
+	case *syntax.DefStmt:
+		return t.Body
+	case *syntax.ForStmt:
+		return t.Body
+	case *syntax.WhileStmt:
+		return t.Body
+	case *syntax.IfStmt:
+		if len(t.False) == 0 {
+			return t.True
+		}
+		// the elif token is both the else of the statement and the if of
+		// the nested one
+		if elif, ok := t.False[0].(*syntax.IfStmt); ok && len(t.False) == 1 && elif.If == t.ElsePos {
+			return lastBody(elif)
+		}
+		return t.False
+	}
+	return nil
+}
+
+// lineGroups splits the comments to the groups of the consecutive lines.
+func lineGroups(lines []syntax.Comment) [][]syntax.Comment {
+	var groups [][]syntax.Comment
+	for i, c := range lines {
+		if i == 0 || c.Start.Line != lines[i-1].Start.Line+1 {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], c)
+	}
+	return groups
+}
+
+// placeGap places the comments before the statement at the index k: the
+// group following the simple statement on the next line is attached after
+// it, the group followed by the statement on the next line is attached
+// before it, the other groups are the comment blocks.
+func (cm *commentMap) placeGap(stmts []syntax.Stmt, k int, gap []syntax.Comment) {
+	groups := lineGroups(gap)
+	var (
+		prev, next              syntax.Stmt
+		attachAfter, attachNext bool
+	)
+	if k > 0 {
+		prev = stmts[k-1]
+		_, end := nodeSpan(prev)
+		attachAfter = lastBody(prev) == nil && groups[0][0].Start.Line == end.Line+1
+	}
+	if k < len(stmts) {
+		next = stmts[k]
+		start, _ := nodeSpan(next)
+		attachNext = gap[len(gap)-1].Start.Line+1 == start.Line
+	}
+	for j, g := range groups {
+		switch {
+		case j == len(groups)-1 && attachNext:
+			cm.before[next] = append(cm.before[next], g...)
+		case j == 0 && attachAfter:
+			cm.after[prev] = append(cm.after[prev], g...)
+		case next != nil:
+			cm.blocks[next] = append(cm.blocks[next], g)
+		default:
+			cm.trailing[prev] = append(cm.trailing[prev], g)
+		}
+	}
+}
+
+// placeStmt places the line comments located within the statement, either
+// in its header or its blocks.
+func (cm *commentMap) placeStmt(st syntax.Stmt, lines []syntax.Comment) {
+	var (
+		header []syntax.Node
+		blocks [][]syntax.Stmt
+		split  []syntax.Position
+	)
+	switch t := st.(type) {
+	case *syntax.DefStmt:
+		for _, p := range t.Params {
+			header = append(header, p)
+		}
+		blocks = [][]syntax.Stmt{t.Body}
+	case *syntax.ForStmt:
+		header = []syntax.Node{t.Vars, t.X}
+		blocks = [][]syntax.Stmt{t.Body}
+	case *syntax.WhileStmt:
+		header = []syntax.Node{t.Cond}
+		blocks = [][]syntax.Stmt{t.Body}
+	case *syntax.IfStmt:
+		header = []syntax.Node{t.Cond}
+		blocks = [][]syntax.Stmt{t.True}
+		if len(t.False) > 0 {
+			blocks = append(blocks, t.False)
+			split = append(split, t.ElsePos)
+		}
+	default:
+		cm.placeExprs([]syntax.Node{st}, nil, st, lines)
+		return
+	}
+
+	var end syntax.Position
+	for _, x := range header {
+		if _, e := nodeSpan(x); posBefore(end, e) {
+			end = e
+		}
+	}
+	var (
+		inHeader []syntax.Comment
+		inBlocks = make([][]syntax.Comment, len(blocks))
+	)
+	for _, c := range lines {
+		if c.Start.Line <= end.Line || defParamsComment(st, c) {
+			inHeader = append(inHeader, c)
+			continue
+		}
+		i := 0
+		for i < len(split) && !posBefore(c.Start, split[i]) {
+			i++
+		}
+		inBlocks[i] = append(inBlocks[i], c)
+	}
+	if len(inHeader) > 0 {
+		var def *syntax.DefStmt
+		if t, ok := st.(*syntax.DefStmt); ok {
+			def = t
+		}
+		cm.placeExprs(header, def, st, inHeader)
+	}
+	for i, b := range blocks {
+		cm.placeBlock(b, inBlocks[i])
+	}
+}
+
+// defParamsComment reports whether the comment before the body of the def
+// statement is the one before its closing parenthesis, which position is
+// not known, i.e. it is indented more than the body.
+func defParamsComment(st syntax.Stmt, c syntax.Comment) bool {
+	def, ok := st.(*syntax.DefStmt)
+	if !ok || len(def.Body) == 0 {
+		return false
+	}
+	start, _ := nodeSpan(def.Body[0])
+	return posBefore(c.Start, start) && c.Start.Col > start.Col
+}
+
+// sequence returns the brackets positions and the elements of the node
+// rendered as the sequence, the sequence with no elements has all the
+// comments as the end ones.
+func sequence(n syntax.Node) (open, close syntax.Position, elems []syntax.Expr, ok bool) {
+	switch t := n.(type) {
+	case *syntax.CallExpr:
+		return t.Lparen, t.Rparen, t.Args, true
+	case *syntax.ListExpr:
+		return t.Lbrack, t.Rbrack, t.List, true
+	case *syntax.DictExpr:
+		return t.Lbrace, t.Rbrace, t.List, true
+	case *syntax.ParenExpr:
+		if tuple, ok := t.X.(*syntax.TupleExpr); ok {
+			return t.Lparen, t.Rparen, tuple.List, true
+		}
+		return t.Lparen, t.Rparen, []syntax.Expr{t.X}, true
+	case *syntax.Comprehension:
+		return t.Lbrack, t.Rbrack, nil, true
+	case *syntax.LoadStmt:
+		elems := []syntax.Expr{t.Module}
+		for _, from := range t.From {
+			elems = append(elems, from)
+		}
+		return t.Load, t.Rparen, elems, true
+	}
+	return
+}
+
+// placeExprs places the line comments located within the expressions: the
+// comment goes before the element of the innermost sequence following it,
+// or before the closing bracket. The comments outside any sequence are the
+// ones of the def parameters, if any, otherwise they go before the owner.
+func (cm *commentMap) placeExprs(roots []syntax.Node, def *syntax.DefStmt, owner syntax.Node, lines []syntax.Comment) {
+	for _, c := range lines {
+		var (
+			seq         syntax.Node
+			open        syntax.Position
+			elems       []syntax.Expr
+			innermostAt syntax.Position
+		)
+		for _, root := range roots {
+			syntax.Walk(root, func(n syntax.Node) bool {
+				if n == nil {
+					return true
+				}
+				o, cl, el, ok := sequence(n)
+				if ok && posBefore(o, c.Start) && posBefore(c.Start, cl) && !posBefore(o, innermostAt) {
+					seq, open, elems, innermostAt = n, o, el, o
+				}
+				return true
+			})
+		}
+		if seq == nil {
+			if def == nil {
+				cm.before[owner] = append(cm.before[owner], c)
+				continue
+			}
+			seq, open, elems = def, def.Def, def.Params
+		}
+		cm.placeElem(seq, open, elems, c)
+	}
+}
+
+// placeElem puts the comment before the element following it, or before
+// the closing bracket of the sequence. The blank line before the comment is
+// kept as the empty comment.
+func (cm *commentMap) placeElem(seq syntax.Node, open syntax.Position, elems []syntax.Expr, c syntax.Comment) {
+	prevLine := open.Line
+	var target syntax.Node = seq
+	slot := cm.end
+	for _, x := range elems {
+		start, end := nodeSpan(x)
+		if posBefore(c.Start, start) {
+			target, slot = x, cm.before
+			break
+		}
+		prevLine = end.Line
+	}
+	if comp, ok := seq.(*syntax.Comprehension); ok {
+		// the comments follow the body and the clauses
+		for _, n := range append([]syntax.Node{comp.Body}, comp.Clauses...) {
+			if _, end := nodeSpan(n); posBefore(end, c.Start) {
+				prevLine = end.Line
+			}
+		}
+	}
+	if list := slot[target]; len(list) > 0 && list[len(list)-1].Start.Line > prevLine {
+		prevLine = list[len(list)-1].Start.Line
+	}
+	if c.Start.Line-prevLine > 1 {
+		slot[target] = append(slot[target], syntax.Comment{})
+	}
+	slot[target] = append(slot[target], c)
+}
+
+// queue adds the end of line comments of the node to the pending ones.
+func (cm *commentMap) queue(n syntax.Node) {
+	if cm != nil {
+		cm.pending = append(cm.pending, cm.suffix[n]...)
+	}
+}
+
+// queueElse adds the end of line comments of the else clause of the if
+// statement to the pending ones.
+func (cm *commentMap) queueElse(input *syntax.IfStmt) {
+	if cm != nil {
+		cm.pending = append(cm.pending, cm.elseSuffix[input]...)
+	}
+}
+
+// keepElse reports whether the comments of the else clause of the if
+// statement, or the ones before the nested if statement, prevent rendering
+// it as elif.
+func (cm *commentMap) keepElse(input, elif *syntax.IfStmt) bool {
+	if cm == nil {
+		return false
+	}
+	return len(cm.elseSuffix[input])+len(cm.before[elif])+len(cm.blocks[elif]) > 0
+}
+
+// commented reports whether the node has the comments placed on it, which
+// make the sequence it is an element of multiline.
+func (cm *commentMap) commented(n syntax.Node, suffix bool) bool {
+	if cm == nil {
+		return false
+	}
+	return len(cm.before[n]) > 0 || suffix && len(cm.suffix[n]) > 0
+}
+
+// multiline reports whether the comments of the sequence elements or
+// the ones before the closing bracket make the sequence multiline.
+func (cm *commentMap) multiline(mode seqMode, n syntax.Node, elems []syntax.Expr) bool {
+	if cm == nil || mode == seqBareTuple {
+		return false
+	}
+	for _, x := range elems {
+		if cm.commented(x, mode != seqDef) {
+			return true
+		}
+	}
+	return len(cm.end[n]) > 0
+}
+
+// inside reports whether any of the statements have the comments, which
+// prevents putting them on the header line.
+func (cm *commentMap) inside(stmts []syntax.Stmt) bool {
+	if cm == nil {
+		return false
+	}
+	if len(cm.pending) > 0 {
+		return true
+	}
+	var found bool
+	for _, st := range stmts {
+		syntax.Walk(st, func(n syntax.Node) bool {
+			if n == nil || found {
+				return !found
+			}
+			if len(cm.before[n])+len(cm.after[n])+len(cm.suffix[n])+len(cm.end[n])+len(cm.blocks[n])+len(cm.trailing[n]) > 0 {
+				found = true
+			}
+			if t, ok := n.(*syntax.IfStmt); ok && len(cm.elseSuffix[t]) > 0 {
+				found = true
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// writeComment writes the text of the comment, the empty one is the blank
+// line. The comments have to be single line and start with "#", otherwise
+// they would break or inject the code.
+func writeComment(out io.StringWriter, c syntax.Comment) error {
+	text := strings.TrimSpace(c.Text)
+	if text == "" {
+		return nil
+	}
+	if !strings.HasPrefix(text, "#") || strings.ContainsAny(text, "\r\n") {
+		return fmt.Errorf("invalid comment %q", c.Text)
+	}
+	if _, err := out.WriteString(text); err != nil {
+		return fmt.Errorf("comment: %w", err)
+	}
+	return nil
+}
+
+// commentLines writes the line comments at the indentation of the options,
+// each followed by the newline.
+func (o *outputOpts) commentLines(out io.StringWriter, lines []syntax.Comment) error {
+	for _, c := range lines {
+		if strings.TrimSpace(c.Text) != "" {
+			if err := writeRepeat(out, o.indent, o.depth); err != nil {
+				return fmt.Errorf("indent: %w", err)
+			}
+		}
+		if err := writeComment(out, c); err != nil {
+			return err
+		}
+		if _, err := out.WriteString(newline); err != nil {
+			return fmt.Errorf("NEWLINE token: %w", err)
+		}
+	}
+	return nil
+}
+
+// newline writes the pending end of line comments and the newline.
+func (o *outputOpts) newline(out io.StringWriter) error {
+	if cm := o.comments; cm != nil && len(cm.pending) > 0 {
+		pending := cm.pending
+		cm.pending = nil
+		for i, c := range pending {
+			sep := space + space
+			if i > 0 {
+				sep = newline + strings.Repeat(o.indent, o.depth)
+			}
+			if _, err := out.WriteString(sep); err != nil {
+				return err
+			}
+			if err := writeComment(out, c); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := out.WriteString(newline)
+	return err
+}
+
+// endStmt writes the end of line comments of the simple statement and
+// the token ending it.
+func (o *outputOpts) endStmt(out io.StringWriter, input syntax.Stmt) error {
+	end := o.stmtEnd()
+	if o.comments == nil || end != newline {
+		_, err := out.WriteString(end)
+		return err
+	}
+	o.comments.queue(input)
+	return o.newline(out)
+}
+
+// endComments writes the line comments before the closing bracket of
+// the multiline sequence, the line is broken after the last element, if
+// any.
+func (o *outputOpts) endComments(out io.StringWriter, lines []syntax.Comment, afterElem bool) error {
+	for i, c := range lines {
+		if afterElem || i > 0 {
+			if err := o.newline(out); err != nil {
+				return fmt.Errorf("NEWLINE token: %w", err)
+			}
+		}
+		if strings.TrimSpace(c.Text) != "" {
+			if err := writeRepeat(out, o.indent, o.depth); err != nil {
+				return fmt.Errorf("indent: %w", err)
+			}
+		}
+		if err := writeComment(out, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commentStmtList writes the statements of the same level with their
+// comments, the way buildifier does: the comment blocks and the statements
+// with the comments before them or the ones after the previous statement are
+// separated with the blank line, the other statements as stmtList does.
+func commentStmtList(out io.StringWriter, input []syntax.Stmt, opts *outputOpts) error {
+	cm := opts.comments
+	if len(input) == 0 {
+		return opts.commentBlocks(out, cm.file, false)
+	}
+	for ii, st := range input {
+		blocks := cm.blocks[st]
+		if err := opts.commentBlocks(out, blocks, ii > 0); err != nil {
+			return fmt.Errorf("statement index %d: %w", ii, err)
+		}
+		blank := 0
+		switch {
+		case len(blocks) > 0:
+			blank = 1
+		case ii == 0:
+		case len(cm.before[st]) > 0 || len(cm.after[input[ii-1]]) > 0:
+			blank = 1
+		default:
+			blank = opts.blankLines(input, ii)
+		}
+		if err := writeRepeat(out, newline, blank); err != nil {
+			return fmt.Errorf("statement index %d: blank line: %w", ii, err)
+		}
+		if err := opts.commentLines(out, cm.before[st]); err != nil {
+			return fmt.Errorf("statement index %d: %w", ii, err)
+		}
+		if err := stmt(out, st, opts.joinStmt(input, ii)); err != nil {
+			return fmt.Errorf("statement index %d: %w", ii, err)
+		}
+		if err := opts.commentLines(out, cm.after[st]); err != nil {
+			return fmt.Errorf("statement index %d: %w", ii, err)
+		}
+	}
+	last := input[len(input)-1]
+	if err := opts.commentBlocks(out, cm.trailing[last], true); err != nil {
+		return fmt.Errorf("statement index %d: %w", len(input)-1, err)
+	}
+	return nil
+}
+
+// commentBlocks writes the comment blocks separated with the blank lines,
+// the first one is preceded by the blank line if it follows the statement.
+func (o *outputOpts) commentBlocks(out io.StringWriter, blocks [][]syntax.Comment, afterStmt bool) error {
+	for i, lines := range blocks {
+		if i > 0 || afterStmt {
+			if _, err := out.WriteString(newline); err != nil {
+				return fmt.Errorf("blank line: %w", err)
+			}
+		}
+		if err := o.commentLines(out, lines); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pendingLines writes the pending end of line comments as the line
+// comments, which buildifier does for the ones after the opening bracket of
+// the multiline sequence.
+func (o *outputOpts) pendingLines(out io.StringWriter) error {
+	if o.comments == nil {
+		return nil
+	}
+	pending := o.comments.pending
+	o.comments.pending = nil
+	return o.commentLines(out, pending)
+}
+
+// loadArgs moves the comments of the loaded names to the load arguments
+// rendered for them.
+func (cm *commentMap) loadArgs(input *syntax.LoadStmt, order []int, args []syntax.Expr) {
+	if cm == nil {
+		return
+	}
+	for k, i := range order {
+		arg := args[k+1]
+		names := []syntax.Node{input.From[i]}
+		if to := input.To[i]; to != nil && to != input.From[i] {
+			names = append(names, to)
+		}
+		for _, n := range names {
+			cm.before[arg] = append(cm.before[arg], cm.before[n]...)
+			cm.suffix[arg] = append(cm.suffix[arg], cm.suffix[n]...)
+			delete(cm.before, n)
+			delete(cm.suffix, n)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package starlarkgen
+
+import (
+	"strings"
+	"testing"
+
+	"go.starlark.net/syntax"
+)
+
+func TestStyleComments(t *testing.T) {
+	tests := []struct {
+		name  string
+		style Style
+		src   string
+		want  string
+	}{
+		{
+			name:  "statement comments",
+			style: StyleBuildifierBzl,
+			src: `# block
+
+# before
+x = 1  # suffix
+# after
+y = 2
+`,
+			want: `# block
+
+# before
+x = 1  # suffix
+
+# after
+y = 2
+`,
+		},
+		{
+			name:  "sequence comments",
+			style: StyleBuildifierBzl,
+			src: `x = [  # open
+    1,  # one
+
+    # two
+    2,
+    # end
+]
+`,
+			want: `x = [
+    # open
+    1,  # one
+
+    # two
+    2,
+    # end
+]
+`,
+		},
+		{
+			name:  "suffix comment makes the call multiline",
+			style: StyleBuildifierBzl,
+			src: `foo(a, b = 1,  # b
+)
+`,
+			want: `foo(
+    a,
+    b = 1,  # b
+)
+`,
+		},
+		{
+			name:  "comments after the block",
+			style: StyleBuildifierBzl,
+			src: `def f():
+    pass
+    # nested
+# dedented
+x = 1
+`,
+			want: `def f():
+    pass
+    # nested
+
+# dedented
+x = 1
+`,
+		},
+		{
+			name:  "else comment keeps the nested if",
+			style: StyleBuildifierBzl,
+			src: `if a:
+    pass
+else:  # else
+    if b:
+        pass
+`,
+			want: `if a:
+    pass
+else:  # else
+    if b:
+        pass
+`,
+		},
+		{
+			name:  "blank line before elif is kept",
+			style: StyleBuildifierBzl,
+			src: `if a:
+    pass
+
+elif b:
+    pass
+`,
+			want: `if a:
+    pass
+
+elif b:
+    pass
+`,
+		},
+		{
+			name:  "def parameters comments",
+			style: StyleBuildifierBzl,
+			src: `def f(
+        a,  # a
+        # b
+        b):
+    pass
+`,
+			want: `def f(
+        a,  # a
+        # b
+        b):
+    pass
+`,
+		},
+		{
+			name:  "load comments",
+			style: StyleBuildifier,
+			src: `load(
+    ":a.bzl",
+    "b",  # b
+    # a
+    "a",
+)
+`,
+			want: `load(
+    ":a.bzl",
+    # a
+    "a",
+    "b",  # b
+)
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := syntax.Parse("test.bzl", tt.src, syntax.RetainComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := StarlarkFile(f, WithStyle(tt.style))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("want\n%s\ngot\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStyleComments_unpositioned(t *testing.T) {
+	stmt := &syntax.AssignStmt{LHS: &syntax.Ident{Name: "x"}, Op: syntax.EQ, RHS: &syntax.Literal{Token: syntax.INT, Value: 1}}
+	stmt.AllocComments()
+	stmt.Comments().Before = []syntax.Comment{{Text: "# before"}}
+	stmt.Comments().Suffix = []syntax.Comment{{Text: "# suffix"}}
+	file := &syntax.File{Stmts: []syntax.Stmt{stmt}}
+	file.AllocComments()
+	file.Comments().Before = []syntax.Comment{{Text: "# header"}}
+	file.Comments().After = []syntax.Comment{{Text: "# footer"}}
+
+	got, err := StarlarkFile(file, WithStyle(StyleBuildifierBzl))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	const want = `# header
+
+# before
+x = 1  # suffix
+
+# footer
+`
+	if got != want {
+		t.Errorf("want\n%s\ngot\n%s", want, got)
+	}
+
+	// the comments are only rendered with the styles
+	got, err = StarlarkFile(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "x = 1\n"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestStyleComments_invalid(t *testing.T) {
+	for _, text := range []string{"no hash", "# two\n# lines"} {
+		stmt := &syntax.BranchStmt{Token: syntax.PASS}
+		stmt.AllocComments()
+		stmt.Comments().Suffix = []syntax.Comment{{Text: text}}
+		_, err := StarlarkStmt(stmt, WithStyle(StyleBuildifierBzl))
+		if err == nil || !strings.Contains(err.Error(), "invalid comment") {
+			t.Errorf("comment %q: expected invalid comment error, got %v", text, err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package starlarkgen
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"go.starlark.net/syntax"
+)
+
+// WithCompactSuites sets the behavior of how the bodies of the def, if, else,
+// for and while statements made of the simple statements only, i.e. return,
+// pass, break, continue, assignment and expression, are rendered. The body is
+// put on the header line, joined with "; ", if the line is at most maxWidth
+// characters long, e.g.
+//   if x: return y
+//   for x in y: foo(x); bar(x)
+// The zero maxWidth means no limit, the negative one disables the compact
+// bodies, which is the default. The minified output always has the compact
+// bodies, see WithMinify.
+func WithCompactSuites(maxWidth int) Option {
+	return func(o *outputOpts) (*outputOpts, error) {
+		c := o.copy()
+		c.compactSuites = maxWidth >= 0
+		c.compactWidth = maxWidth
+		return c, nil
+	}
+}
+
+// column returns the number of the characters written on the current line,
+// if the output is tracked or is the strings.Builder, e.g. the one of
+// the statements edited, see WriteEditFile.
+func column(out io.StringWriter) int {
+	switch w := out.(type) {
+	case *trackingWriter:
+		return w.pos.col - 1
+	case *strings.Builder:
+		s := w.String()
+		return utf8.RuneCountInString(s[strings.LastIndexByte(s, '\n')+1:])
+	}
+	return 0
+}
+
+// compactSuite writes the block of the statements on the header line, after
+// the colon, if the suites are compact, all the statements are simple and
+// the line fits the width. Otherwise nothing is written and the block is left
+// to stmtSequence.
+func compactSuite(out io.StringWriter, input []syntax.Stmt, opts *outputOpts) (bool, error) {
+	width := opts.compactWidth
+	if opts.minify {
+		width = 0
+	} else if !opts.compactSuites || opts.comments.inside(input) {
+		return false, nil
+	}
+	if len(input) == 0 {
+		if !opts.autoPass {
+			return false, nil
+		}
+		input = []syntax.Stmt{&syntax.BranchStmt{Token: syntax.PASS}}
+	}
+	for _, st := range input {
+		if _, ok := st.(*syntax.LoadStmt); ok || !simpleStmt(st) {
+			return false, nil
+		}
+	}
+
+	sep := space
+	if opts.minify {
+		sep = ""
+	}
+	stOpts := opts.copy()
+	stOpts.depth = 0
+	stOpts.level++
+	stOpts.inline = true
+
+	// the trial rendering checks the statements fit the single line, the
+	// failed ones are left to the block to report the error
+	var sb strings.Builder
+	if err := stmtList(&sb, input, stOpts); err != nil {
+		return false, nil
+	}
+	line := strings.TrimSuffix(sb.String(), newline)
+	if strings.Contains(line, newline) {
+		return false, nil
+	}
+	if width > 0 && column(out)+utf8.RuneCountInString(sep+line) > width {
+		return false, nil
+	}
+
+	if _, err := out.WriteString(sep); err != nil {
+		return false, fmt.Errorf("space: %w", err)
+	}
+	if err := stmtList(out, input, stOpts); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,170 @@
+package starlarkgen
+
+import (
+	"testing"
+
+	"go.starlark.net/syntax"
+)
+
+func TestWithCompactSuites(t *testing.T) {
+	const src = `def check(x, y):
+    if not x:
+        return None
+    elif x in y:
+        y.remove(x)
+        return x
+    else:
+        fail("unexpected value")
+    for i in y:
+        if i:
+            continue
+        print(i)
+
+def noop():
+    pass
+`
+	tests := []struct {
+		name    string
+		options []Option
+		// the output is nested in the block
+		nested bool
+		want   string
+	}{
+		{
+			name:    "no limit",
+			options: []Option{WithCompactSuites(0)},
+			want: `def check(x, y):
+    if not x: return None
+    elif x in y: y.remove(x); return x
+    else: fail("unexpected value")
+    for i in y:
+        if i: continue
+        print(i)
+
+def noop(): pass
+`,
+		},
+		{
+			name:    "width limit",
+			options: []Option{WithCompactSuites(30)},
+			want: `def check(x, y):
+    if not x: return None
+    elif x in y:
+        y.remove(x)
+        return x
+    else:
+        fail("unexpected value")
+    for i in y:
+        if i: continue
+        print(i)
+
+def noop(): pass
+`,
+		},
+		{
+			name:    "width limit with the indentation",
+			options: []Option{WithCompactSuites(27), WithDepth(1)},
+			nested:  true,
+			want: `    def check(x, y):
+        if not x:
+            return None
+        elif x in y:
+            y.remove(x)
+            return x
+        else:
+            fail("unexpected value")
+        for i in y:
+            if i: continue
+            print(i)
+
+    def noop(): pass
+`,
+		},
+		{
+			name:    "disabled",
+			options: []Option{WithCompactSuites(0), WithCompactSuites(-1)},
+			want:    src,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := syntax.Parse("test.star", src, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := StarlarkFile(f, append(tt.options, WithStyle(StyleBuildifier))...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("want\n%s\ngot\n%s", tt.want, got)
+			}
+			if tt.nested {
+				got = "if True:\n" + got
+			}
+			if _, err := syntax.Parse("out.star", got, 0); err != nil {
+				t.Errorf("parsing the output: %v", err)
+			}
+		})
+	}
+}
+
+func TestWithCompactSuites_stmt(t *testing.T) {
+	ident := func(name string) *syntax.Ident { return &syntax.Ident{Name: name} }
+	tests := []struct {
+		name    string
+		input   syntax.Stmt
+		options []Option
+		want    string
+	}{
+		{
+			name:  "while",
+			input: &syntax.WhileStmt{Cond: ident("x"), Body: []syntax.Stmt{&syntax.AssignStmt{LHS: ident("x"), Op: syntax.MINUS_EQ, RHS: &syntax.Literal{Value: 1}}}},
+			want:  "while x: x -= 1\n",
+		},
+		{
+			name:    "empty body with auto pass",
+			input:   &syntax.ForStmt{Vars: ident("x"), X: ident("y")},
+			options: []Option{WithAutoPass(true)},
+			want:    "for x in y: pass\n",
+		},
+		{
+			name:  "docstring",
+			input: &syntax.DefStmt{Name: ident("f"), Body: []syntax.Stmt{&syntax.ExprStmt{X: &syntax.Literal{Token: syntax.STRING, Value: "Doc."}}}},
+			want:  "def f(): \"\"\"Doc.\"\"\"\n",
+		},
+		{
+			name:  "multiline docstring",
+			input: &syntax.DefStmt{Name: ident("f"), Body: []syntax.Stmt{&syntax.ExprStmt{X: &syntax.Literal{Token: syntax.STRING, Value: "Doc.\n\nMore.\n"}}}},
+			want:  "def f():\n    \"\"\"Doc.\n\n    More.\n    \"\"\"\n",
+		},
+		{
+			name: "multiline layout",
+			input: &syntax.IfStmt{Cond: ident("x"), True: []syntax.Stmt{&syntax.ExprStmt{X: &syntax.CallExpr{
+				Fn:   ident("f"),
+				Args: []syntax.Expr{ident("a"), ident("b")},
+			}}}},
+			options: []Option{WithCallOption(CallOptionMultilineComma)},
+			want:    "if x:\n    f(\n        a,\n        b,\n    )\n",
+		},
+		{
+			name: "keep going",
+			input: &syntax.IfStmt{Cond: ident("x"), True: []syntax.Stmt{
+				&syntax.ReturnStmt{Result: &syntax.UnaryExpr{Op: syntax.MINUS}},
+			}},
+			options: []Option{WithKeepGoing(true)},
+			want:    "if x:\n    return fail(\"starlarkgen: error: rendering unary expression, nil X value for \\\"-\\\" token\")\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := StarlarkStmt(tt.input, append(tt.options, WithCompactSuites(0))...)
+			if err != nil && got == "" {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("want\n%q\ngot\n%q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,593 @@
+package starlarkgen
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"go.starlark.net/syntax"
+)
+
+// EditFile produces the source of the modified file, keeping the bytes of
+// the original source for everything not modified, see WriteEditFile.
+// In case of an error the string output is always empty.
+func EditFile(src []byte, input *syntax.File, options ...Option) (string, error) {
+	var sb strings.Builder
+	if err := WriteEditFile(&sb, src, input, options...); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// WriteEditFile writes the modified file to the provided writer, re-rendering
+// only the modified parts of the original source, the other bytes, including
+// the comments and the blank lines, are written unchanged.
+//
+// The input must be the result of parsing the src, modified in place: the
+// top-level statements are matched with the original ones by their start
+// positions, the statements without the positions are considered new, and
+// the original statements without the match are considered removed, along
+// with the comments and the blank lines preceding them. The comments and
+// the blank lines preceding the matched statements are moved with them, if
+// the statements are reordered, except the ones before the first statement,
+// which stay at the start of the file. The matched statements are compared
+// with the original ones structurally, ignoring the positions and the
+// comments, and the smallest changed statements and expressions are
+// re-rendered using the options supplied and spliced in. The sequences of
+// a different length are re-rendered as a whole, as are the def, if, for
+// and while statements with the modified header.
+//
+// In case of an error incomplete results might be written to the output,
+// use EditFile to avoid handling partial input.
+func WriteEditFile(output io.StringWriter, src []byte, input *syntax.File, options ...Option) error {
+	opts, err := getOutputOpts(options...)
+	if err != nil {
+		return err
+	}
+	if input == nil {
+		return errors.New("editing file: nil input")
+	}
+	orig, err := syntax.Parse(input.Path, src, 0)
+	if err != nil {
+		return fmt.Errorf("editing file: parsing the original source: %w", err)
+	}
+
+	e := &editor{src: string(src), opts: opts}
+	e.lines = []int{0}
+	for i, c := range e.src {
+		if c == '\n' {
+			e.lines = append(e.lines, i+1)
+		}
+	}
+	return renderError(input, e.file(output, orig, input), e.src)
+}
+
+type editor struct {
+	src   string
+	lines []int // the line start offsets
+	opts  *outputOpts
+}
+
+// edit replaces the source bytes between the offsets with the text.
+type edit struct {
+	start, end int
+	text       string
+}
+
+// offset returns the byte offset of the position in the source.
+func (e *editor) offset(pos syntax.Position) int {
+	line := int(pos.Line) - 1
+	if line < 0 {
+		return 0
+	}
+	if line >= len(e.lines) {
+		return len(e.src)
+	}
+	off := e.lines[line]
+	for col := int32(1); col < pos.Col && off < len(e.src) && e.src[off] != '\n'; col++ {
+		_, size := utf8.DecodeRuneInString(e.src[off:])
+		off += size
+	}
+	return off
+}
+
+// lineStart returns the offset of the start of the line containing offset.
+func (e *editor) lineStart(off int) int {
+	return strings.LastIndexByte(e.src[:off], '\n') + 1
+}
+
+// lineEnd returns the offset after the newline ending the line containing
+// offset.
+func (e *editor) lineEnd(off int) int {
+	if i := strings.IndexByte(e.src[off:], '\n'); i >= 0 {
+		return off + i + 1
+	}
+	return len(e.src)
+}
+
+// region returns the offsets of the full lines of the statement.
+func (e *editor) region(st syntax.Stmt) (int, int) {
+	start, end := nodeSpan(st)
+	return e.lineStart(e.offset(start)), e.lineEnd(e.offset(end))
+}
+
+// file writes the modified file. Each original statement is written along
+// with its leading gap, i.e. the comments and the blank lines after the
+// previous statement, so the reordered statements keep their comments. The
+// source before the first statement, e.g. the header comment, is written
+// once at the start of the file.
+func (e *editor) file(out io.StringWriter, orig, input *syntax.File) error {
+	type origStmt struct {
+		stmt       syntax.Stmt
+		lead       int // the offset of the leading gap
+		start, end int
+	}
+	var (
+		byPos  = make(map[syntax.Position]*origStmt)
+		prefix = len(e.src)
+		suffix = 0
+	)
+	for i, st := range orig.Stmts {
+		start, end := e.region(st)
+		lead := suffix
+		if i == 0 {
+			prefix, lead = start, start
+		}
+		byPos[stmtPos(st)] = &origStmt{stmt: st, lead: lead, start: start, end: end}
+		suffix = end
+	}
+	if len(orig.Stmts) == 0 {
+		prefix, suffix = 0, 0
+	}
+
+	var sb strings.Builder
+	sb.WriteString(e.src[:prefix])
+	for i, st := range input.Stmts {
+		if s := sb.String(); s != "" && !strings.HasSuffix(s, newline) {
+			sb.WriteString(newline)
+		}
+		o, ok := byPos[stmtPos(st)]
+		if ok && o.stmt != nil {
+			lead := e.src[o.lead:o.start]
+			if i == 0 {
+				// the blank lines are kept after the prefix only
+				lead = lead[len(lead)-len(trimBlankLines(lead)):]
+			}
+			sb.WriteString(lead)
+			var edits []edit
+			if err := e.stmt(&edits, o.stmt, st, e.opts); err != nil {
+				return fmt.Errorf("statement index %d: %w", i, err)
+			}
+			e.apply(&sb, o.start, o.end, edits)
+			// the statement is matched at most once
+			o.stmt = nil
+			continue
+		}
+
+		if i > 0 {
+			if err := writeRepeat(&sb, newline, e.opts.blankLines(input.Stmts, i)); err != nil {
+				return fmt.Errorf("statement index %d: %w", i, err)
+			}
+		}
+		if err := stmt(&sb, st, e.opts); err != nil {
+			return fmt.Errorf("statement index %d: %w", i, err)
+		}
+	}
+	if s := sb.String(); suffix < len(e.src) && s != "" && !strings.HasSuffix(s, newline) {
+		sb.WriteString(newline)
+	}
+	sb.WriteString(e.src[suffix:])
+
+	if _, err := out.WriteString(sb.String()); err != nil {
+		return fmt.Errorf("editing file: %w", err)
+	}
+	return nil
+}
+
+// trimBlankLines removes the leading blank lines.
+func trimBlankLines(s string) string {
+	for {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 || strings.TrimSpace(s[:i]) != "" {
+			return s
+		}
+		s = s[i+1:]
+	}
+}
+
+// stmtPos returns the start position of the statement, ignoring the file name.
+func stmtPos(st syntax.Stmt) syntax.Position {
+	start, _ := nodeSpan(st)
+	if !start.IsValid() {
+		return syntax.Position{}
+	}
+	return syntax.MakePosition(nil, start.Line, start.Col)
+}
+
+// apply writes the source between the offsets with the edits applied.
+func (e *editor) apply(sb *strings.Builder, start, end int, edits []edit) {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	for _, ed := range edits {
+		sb.WriteString(e.src[start:ed.start])
+		sb.WriteString(ed.text)
+		start = ed.end
+	}
+	sb.WriteString(e.src[start:end])
+}
+
+// indentOpts returns the options to render the node starting at the offset
+// with, along with the indentation of the line.
+func (e *editor) indentOpts(off int, opts *outputOpts) (*outputOpts, string) {
+	ls := e.lineStart(off)
+	ws := e.src[ls : ls+len(e.src[ls:])-len(strings.TrimLeft(e.src[ls:], " \t"))]
+	c := opts.copy()
+	c.depth = 0
+	if opts.indent != "" && strings.Count(ws, opts.indent)*len(opts.indent) == len(ws) {
+		c.depth = len(ws) / len(opts.indent)
+		ws = ""
+	}
+	return c, ws
+}
+
+// reindent adds the indentation to every line but the first.
+func reindent(s, ws string) string {
+	if ws == "" {
+		return s
+	}
+	return strings.Replace(s, newline, newline+ws, -1)
+}
+
+// stmt collects the edits turning the original statement into the modified one.
+func (e *editor) stmt(edits *[]edit, orig, input syntax.Stmt, opts *outputOpts) error {
+	if sameStmt(orig, input) {
+		return nil
+	}
+
+	n := len(*edits)
+	err := errReplace
+	switch o := orig.(type) {
+	case *syntax.ExprStmt:
+		if t, ok := input.(*syntax.ExprStmt); ok {
+			err = e.expr(edits, o.X, t.X, opts)
+		}
+	case *syntax.AssignStmt:
+		if t, ok := input.(*syntax.AssignStmt); ok && o.Op == t.Op {
+			if err = e.expr(edits, o.LHS, t.LHS, opts); err == nil {
+				err = e.expr(edits, o.RHS, t.RHS, opts)
+			}
+		}
+	case *syntax.DefStmt:
+		if t, ok := input.(*syntax.DefStmt); ok && sameExpr(o.Name, t.Name) && sameExprs(o.Params, t.Params) {
+			err = e.body(edits, o.Body, t.Body, opts)
+		}
+	case *syntax.ForStmt:
+		if t, ok := input.(*syntax.ForStmt); ok && sameExpr(o.Vars, t.Vars) && sameExpr(o.X, t.X) {
+			err = e.body(edits, o.Body, t.Body, opts)
+		}
+	case *syntax.WhileStmt:
+		if t, ok := input.(*syntax.WhileStmt); ok && sameExpr(o.Cond, t.Cond) {
+			err = e.body(edits, o.Body, t.Body, opts)
+		}
+	case *syntax.IfStmt:
+		// the elif branches are re-rendered with the whole statement
+		if t, ok := input.(*syntax.IfStmt); ok && sameExpr(o.Cond, t.Cond) && !isElif(o.False) && !isElif(t.False) &&
+			(len(o.False) > 0) == (len(t.False) > 0) {
+			if err = e.body(edits, o.True, t.True, opts); err == nil && len(o.False) > 0 {
+				err = e.body(edits, o.False, t.False, opts)
+			}
+		}
+	}
+	if err != errReplace {
+		return err
+	}
+	*edits = (*edits)[:n]
+
+	// replace the statement up to its end, keeping the suffix comment
+	start, end := nodeSpan(orig)
+	so, eo := e.lineStart(e.offset(start)), e.offset(end)
+	sOpts, ws := e.indentOpts(e.offset(start), opts)
+	var sb strings.Builder
+	if ws != "" {
+		sb.WriteString(ws)
+	}
+	if err := stmt(&sb, input, sOpts); err != nil {
+		return err
+	}
+	*edits = append(*edits, edit{start: so, end: eo, text: reindent(strings.TrimSuffix(sb.String(), newline), ws)})
+	return nil
+}
+
+func isElif(stmts []syntax.Stmt) bool {
+	if len(stmts) != 1 {
+		return false
+	}
+	_, ok := stmts[0].(*syntax.IfStmt)
+	return ok
+}
+
+// body collects the edits of the statements of the block, which must be of
+// the same length, otherwise the parent statement is re-rendered.
+func (e *editor) body(edits *[]edit, orig, input []syntax.Stmt, opts *outputOpts) error {
+	if len(orig) != len(input) {
+		return errReplace
+	}
+	nested := opts.copy()
+	nested.level++
+	for i := range orig {
+		if err := e.stmt(edits, orig[i], input[i], nested); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errReplace is returned when the statement must be re-rendered as a whole.
+var errReplace = errors.New("replace")
+
+// expr collects the edits turning the original expression into the modified
+// one.
+func (e *editor) expr(edits *[]edit, orig, input syntax.Expr, opts *outputOpts) error {
+	if sameExpr(orig, input) {
+		return nil
+	}
+	if pairs, ok := exprChildren(orig, input); ok {
+		for _, p := range pairs {
+			if err := e.expr(edits, p[0], p[1], opts); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	start, end := nodeSpan(orig)
+	so := e.offset(start)
+	xOpts, ws := e.indentOpts(so, opts)
+	var sb strings.Builder
+	if err := expr(&sb, input, xOpts); err != nil {
+		return err
+	}
+	*edits = append(*edits, edit{start: so, end: e.offset(end), text: reindent(sb.String(), ws)})
+	return nil
+}
+
+// exprChildren returns the pairs of the original and the modified
+// subexpressions, if the expressions differ only in them.
+func exprChildren(orig, input syntax.Expr) ([][2]syntax.Expr, bool) {
+	switch o := orig.(type) {
+	case *syntax.BinaryExpr:
+		if t, ok := input.(*syntax.BinaryExpr); ok && o.Op == t.Op {
+			return [][2]syntax.Expr{{o.X, t.X}, {o.Y, t.Y}}, true
+		}
+	case *syntax.UnaryExpr:
+		if t, ok := input.(*syntax.UnaryExpr); ok && o.Op == t.Op && o.X != nil && t.X != nil {
+			return [][2]syntax.Expr{{o.X, t.X}}, true
+		}
+	case *syntax.CallExpr:
+		if t, ok := input.(*syntax.CallExpr); ok && len(o.Args) == len(t.Args) {
+			return append([][2]syntax.Expr{{o.Fn, t.Fn}}, zipExprs(o.Args, t.Args)...), true
+		}
+	case *syntax.ListExpr:
+		if t, ok := input.(*syntax.ListExpr); ok && len(o.List) == len(t.List) {
+			return zipExprs(o.List, t.List), true
+		}
+	case *syntax.TupleExpr:
+		if t, ok := input.(*syntax.TupleExpr); ok && len(o.List) == len(t.List) && len(o.List) > 0 {
+			return zipExprs(o.List, t.List), true
+		}
+	case *syntax.DictExpr:
+		if t, ok := input.(*syntax.DictExpr); ok && len(o.List) == len(t.List) {
+			return zipExprs(o.List, t.List), true
+		}
+	case *syntax.DictEntry:
+		if t, ok := input.(*syntax.DictEntry); ok {
+			return [][2]syntax.Expr{{o.Key, t.Key}, {o.Value, t.Value}}, true
+		}
+	case *syntax.ParenExpr:
+		if t, ok := input.(*syntax.ParenExpr); ok {
+			return [][2]syntax.Expr{{o.X, t.X}}, true
+		}
+	case *syntax.DotExpr:
+		if t, ok := input.(*syntax.DotExpr); ok {
+			return [][2]syntax.Expr{{o.X, t.X}, {o.Name, t.Name}}, true
+		}
+	case *syntax.IndexExpr:
+		if t, ok := input.(*syntax.IndexExpr); ok {
+			return [][2]syntax.Expr{{o.X, t.X}, {o.Y, t.Y}}, true
+		}
+	case *syntax.CondExpr:
+		if t, ok := input.(*syntax.CondExpr); ok {
+			return [][2]syntax.Expr{{o.True, t.True}, {o.Cond, t.Cond}, {o.False, t.False}}, true
+		}
+	}
+	return nil, false
+}
+
+func zipExprs(a, b []syntax.Expr) [][2]syntax.Expr {
+	res := make([][2]syntax.Expr, len(a))
+	for i := range a {
+		res[i] = [2]syntax.Expr{a[i], b[i]}
+	}
+	return res
+}
+
+// sameStmt reports whether the statements are structurally equal, ignoring
+// the positions and the comments.
+func sameStmt(a, b syntax.Stmt) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	switch x := a.(type) {
+	case *syntax.AssignStmt:
+		y, ok := b.(*syntax.AssignStmt)
+		return ok && x.Op == y.Op && sameExpr(x.LHS, y.LHS) && sameExpr(x.RHS, y.RHS)
+	case *syntax.BranchStmt:
+		y, ok := b.(*syntax.BranchStmt)
+		return ok && x.Token == y.Token
+	case *syntax.DefStmt:
+		y, ok := b.(*syntax.DefStmt)
+		return ok && sameExpr(x.Name, y.Name) && sameExprs(x.Params, y.Params) && sameStmts(x.Body, y.Body)
+	case *syntax.ExprStmt:
+		y, ok := b.(*syntax.ExprStmt)
+		return ok && sameExpr(x.X, y.X)
+	case *syntax.ForStmt:
+		y, ok := b.(*syntax.ForStmt)
+		return ok && sameExpr(x.Vars, y.Vars) && sameExpr(x.X, y.X) && sameStmts(x.Body, y.Body)
+	case *syntax.IfStmt:
+		y, ok := b.(*syntax.IfStmt)
+		return ok && sameExpr(x.Cond, y.Cond) && sameStmts(x.True, y.True) && sameStmts(x.False, y.False)
+	case *syntax.LoadStmt:
+		y, ok := b.(*syntax.LoadStmt)
+		if !ok || !sameExpr(x.Module, y.Module) || len(x.From) != len(y.From) || len(x.To) != len(y.To) {
+			return false
+		}
+		for i := range x.From {
+			if x.From[i].Name != y.From[i].Name {
+				return false
+			}
+		}
+		for i := range x.To {
+			if x.To[i].Name != y.To[i].Name {
+				return false
+			}
+		}
+		return true
+	case *syntax.ReturnStmt:
+		y, ok := b.(*syntax.ReturnStmt)
+		return ok && sameExpr(x.Result, y.Result)
+	case *syntax.WhileStmt:
+		y, ok := b.(*syntax.WhileStmt)
+		return ok && sameExpr(x.Cond, y.Cond) && sameStmts(x.Body, y.Body)
+	}
+	return false
+}
+
+func sameStmts(a, b []syntax.Stmt) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !sameStmt(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameExpr reports whether the expressions are structurally equal, ignoring
+// the positions and the comments.
+func sameExpr(a, b syntax.Expr) bool {
+	if isNilExpr(a) || isNilExpr(b) {
+		return isNilExpr(a) && isNilExpr(b)
+	}
+	switch x := a.(type) {
+	case *syntax.BinaryExpr:
+		y, ok := b.(*syntax.BinaryExpr)
+		return ok && x.Op == y.Op && sameExpr(x.X, y.X) && sameExpr(x.Y, y.Y)
+	case *syntax.CallExpr:
+		y, ok := b.(*syntax.CallExpr)
+		return ok && sameExpr(x.Fn, y.Fn) && sameExprs(x.Args, y.Args)
+	case *syntax.Comprehension:
+		y, ok := b.(*syntax.Comprehension)
+		if !ok || x.Curly != y.Curly || !sameExpr(x.Body, y.Body) || len(x.Clauses) != len(y.Clauses) {
+			return false
+		}
+		for i := range x.Clauses {
+			switch cx := x.Clauses[i].(type) {
+			case *syntax.ForClause:
+				cy, ok := y.Clauses[i].(*syntax.ForClause)
+				if !ok || !sameExpr(cx.Vars, cy.Vars) || !sameExpr(cx.X, cy.X) {
+					return false
+				}
+			case *syntax.IfClause:
+				cy, ok := y.Clauses[i].(*syntax.IfClause)
+				if !ok || !sameExpr(cx.Cond, cy.Cond) {
+					return false
+				}
+			default:
+				return false
+			}
+		}
+		return true
+	case *syntax.CondExpr:
+		y, ok := b.(*syntax.CondExpr)
+		return ok && sameExpr(x.Cond, y.Cond) && sameExpr(x.True, y.True) && sameExpr(x.False, y.False)
+	case *syntax.DictEntry:
+		y, ok := b.(*syntax.DictEntry)
+		return ok && sameExpr(x.Key, y.Key) && sameExpr(x.Value, y.Value)
+	case *syntax.DictExpr:
+		y, ok := b.(*syntax.DictExpr)
+		return ok && sameExprs(x.List, y.List)
+	case *syntax.DotExpr:
+		y, ok := b.(*syntax.DotExpr)
+		return ok && sameExpr(x.X, y.X) && sameExpr(x.Name, y.Name)
+	case *syntax.Ident:
+		y, ok := b.(*syntax.Ident)
+		return ok && x.Name == y.Name
+	case *syntax.IndexExpr:
+		y, ok := b.(*syntax.IndexExpr)
+		return ok && sameExpr(x.X, y.X) && sameExpr(x.Y, y.Y)
+	case *syntax.LambdaExpr:
+		y, ok := b.(*syntax.LambdaExpr)
+		return ok && sameExprs(x.Params, y.Params) && sameExpr(x.Body, y.Body)
+	case *syntax.ListExpr:
+		y, ok := b.(*syntax.ListExpr)
+		return ok && sameExprs(x.List, y.List)
+	case *syntax.Literal:
+		y, ok := b.(*syntax.Literal)
+		return ok && x.Token == y.Token && sameValue(x, y)
+	case *syntax.ParenExpr:
+		y, ok := b.(*syntax.ParenExpr)
+		return ok && sameExpr(x.X, y.X)
+	case *syntax.SliceExpr:
+		y, ok := b.(*syntax.SliceExpr)
+		return ok && sameExpr(x.X, y.X) && sameExpr(x.Lo, y.Lo) && sameExpr(x.Hi, y.Hi) && sameExpr(x.Step, y.Step)
+	case *syntax.TupleExpr:
+		y, ok := b.(*syntax.TupleExpr)
+		return ok && sameExprs(x.List, y.List)
+	case *syntax.UnaryExpr:
+		y, ok := b.(*syntax.UnaryExpr)
+		return ok && x.Op == y.Op && sameExpr(x.X, y.X)
+	}
+	return false
+}
+
+func sameExprs(a, b []syntax.Expr) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !sameExpr(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isNilExpr(x syntax.Expr) bool {
+	if x == nil {
+		return true
+	}
+	rv := reflect.ValueOf(x)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// sameValue reports whether the literals have the same value, the raw tokens
+// are compared if the values are not set.
+func sameValue(a, b *syntax.Literal) bool {
+	if a.Value == nil || b.Value == nil {
+		return a.Value == nil && b.Value == nil && a.Raw == b.Raw
+	}
+	if ba, ok := a.Value.(*big.Int); ok {
+		bb, ok := b.Value.(*big.Int)
+		return ok && ba.Cmp(bb) == 0
+	}
+	if _, ok := b.Value.(*big.Int); ok {
+		return false
+	}
+	return a.Value == b.Value
+}
@@ -0,0 +1,287 @@
+package starlarkgen
+
+import (
+	"testing"
+
+	"go.starlark.net/syntax"
+)
+
+func TestEditFile(t *testing.T) {
+	const source = `# Header comment.
+
+load("@rules_cc//cc:defs.bzl", "cc_library")
+
+# The library.
+cc_library(
+    name = "foo",
+    srcs = ["foo.cc"],  # the sources
+    deps = [
+        ":bar",
+        "//baz",
+    ],
+    copts = ["-O2"],
+)
+
+X = {'a': 0x10, "b": 1.5}
+
+def f(x):
+    if x:
+        return  [x]
+    return None
+`
+	tests := []struct {
+		name    string
+		modify  func(f *syntax.File)
+		options []Option
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "unchanged",
+			modify: func(f *syntax.File) {},
+			want:   source,
+		},
+		{
+			name: "literal in a kwarg",
+			modify: func(f *syntax.File) {
+				call := f.Stmts[1].(*syntax.ExprStmt).X.(*syntax.CallExpr)
+				srcs := call.Args[1].(*syntax.BinaryExpr).Y.(*syntax.ListExpr)
+				srcs.List[0].(*syntax.Literal).Value = "foo2.cc"
+			},
+			want: `# Header comment.
+
+load("@rules_cc//cc:defs.bzl", "cc_library")
+
+# The library.
+cc_library(
+    name = "foo",
+    srcs = ["foo2.cc"],  # the sources
+    deps = [
+        ":bar",
+        "//baz",
+    ],
+    copts = ["-O2"],
+)
+
+X = {'a': 0x10, "b": 1.5}
+
+def f(x):
+    if x:
+        return  [x]
+    return None
+`,
+		},
+		{
+			name: "list element appended",
+			modify: func(f *syntax.File) {
+				call := f.Stmts[1].(*syntax.ExprStmt).X.(*syntax.CallExpr)
+				deps := call.Args[2].(*syntax.BinaryExpr).Y.(*syntax.ListExpr)
+				deps.List = append(deps.List, &syntax.Literal{Token: syntax.STRING, Value: "@qux"})
+			},
+			options: []Option{WithStyle(StyleBuildifier)},
+			want: `# Header comment.
+
+load("@rules_cc//cc:defs.bzl", "cc_library")
+
+# The library.
+cc_library(
+    name = "foo",
+    srcs = ["foo.cc"],  # the sources
+    deps = [
+        ":bar",
+        "//baz",
+        "@qux",
+    ],
+    copts = ["-O2"],
+)
+
+X = {'a': 0x10, "b": 1.5}
+
+def f(x):
+    if x:
+        return  [x]
+    return None
+`,
+		},
+		{
+			name: "nested statement",
+			modify: func(f *syntax.File) {
+				def := f.Stmts[3].(*syntax.DefStmt)
+				ret := def.Body[0].(*syntax.IfStmt).True[0].(*syntax.ReturnStmt)
+				ret.Result = &syntax.Ident{Name: "x"}
+			},
+			want: `# Header comment.
+
+load("@rules_cc//cc:defs.bzl", "cc_library")
+
+# The library.
+cc_library(
+    name = "foo",
+    srcs = ["foo.cc"],  # the sources
+    deps = [
+        ":bar",
+        "//baz",
+    ],
+    copts = ["-O2"],
+)
+
+X = {'a': 0x10, "b": 1.5}
+
+def f(x):
+    if x:
+        return x
+    return None
+`,
+		},
+		{
+			name: "statements removed and added",
+			modify: func(f *syntax.File) {
+				f.Stmts = append(f.Stmts[:2], f.Stmts[3],
+					&syntax.AssignStmt{Op: syntax.EQ, LHS: &syntax.Ident{Name: "Y"}, RHS: &syntax.Literal{Token: syntax.INT, Value: int64(1)}})
+			},
+			want: `# Header comment.
+
+load("@rules_cc//cc:defs.bzl", "cc_library")
+
+# The library.
+cc_library(
+    name = "foo",
+    srcs = ["foo.cc"],  # the sources
+    deps = [
+        ":bar",
+        "//baz",
+    ],
+    copts = ["-O2"],
+)
+
+def f(x):
+    if x:
+        return  [x]
+    return None
+
+Y = 1
+`,
+		},
+		{
+			name: "statement replaced",
+			modify: func(f *syntax.File) {
+				f.Stmts[2].(*syntax.AssignStmt).Op = syntax.PLUS_EQ
+			},
+			want: `# Header comment.
+
+load("@rules_cc//cc:defs.bzl", "cc_library")
+
+# The library.
+cc_library(
+    name = "foo",
+    srcs = ["foo.cc"],  # the sources
+    deps = [
+        ":bar",
+        "//baz",
+    ],
+    copts = ["-O2"],
+)
+
+X += {"a": 0x10, "b": 1.5}
+
+def f(x):
+    if x:
+        return  [x]
+    return None
+`,
+			options: []Option{WithStyle(StyleBuildifierBzl)},
+		},
+		{
+			name: "render error",
+			modify: func(f *syntax.File) {
+				f.Stmts[2].(*syntax.AssignStmt).Op = syntax.PLUS_EQ
+			},
+			wantErr: "statement index 2: rendering assignment statement RHS: rendering dict expression: element 1: rendering dict entry Value: unsupported literal value type float64, expected string, int, int64, uint, uint64 or *big.Int\n" +
+				"BUILD:16:22:\n" +
+				"X = {'a': 0x10, \"b\": 1.5}\n" +
+				"                     ^",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := syntax.Parse("BUILD", source, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.modify(f)
+			got, err := EditFile([]byte(source), f, tt.options...)
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				if gotErr := err.Error(); gotErr != tt.wantErr {
+					t.Fatalf("expected error %q, got %q", tt.wantErr, gotErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("want:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEditFile_reorder(t *testing.T) {
+	reverse := func(f *syntax.File) {
+		for i, j := 0, len(f.Stmts)-1; i < j; i, j = i+1, j-1 {
+			f.Stmts[i], f.Stmts[j] = f.Stmts[j], f.Stmts[i]
+		}
+	}
+	tests := []struct {
+		name   string
+		source string
+		modify func(f *syntax.File)
+		want   string
+	}{
+		{
+			name:   "comments",
+			source: "# header comment\nA = 1\n# about B\nB = 2\n",
+			modify: reverse,
+			want:   "# header comment\n# about B\nB = 2\nA = 1\n",
+		},
+		{
+			name:   "blank lines",
+			source: "# header comment\n\nA = 1\n\n# about B\nB = 2\n\n# about C\nC = 3  # suffix\n\n# trailing comment\n",
+			modify: reverse,
+			want:   "# header comment\n\n# about C\nC = 3  # suffix\n\n# about B\nB = 2\nA = 1\n\n# trailing comment\n",
+		},
+		{
+			name:   "no trailing newline",
+			source: "A = 1\n# about B\nB = 2",
+			modify: reverse,
+			want:   "# about B\nB = 2\nA = 1\n",
+		},
+		{
+			name:   "moved and removed",
+			source: "# about A\nA = 1\n# about B\nB = 2\n# about C\nC = 3\n",
+			modify: func(f *syntax.File) {
+				f.Stmts = []syntax.Stmt{f.Stmts[2], f.Stmts[0]}
+			},
+			want: "# about A\n# about C\nC = 3\nA = 1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := syntax.Parse("BUILD", tt.source, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.modify(f)
+			got, err := EditFile([]byte(tt.source), f)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("want:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}
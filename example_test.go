@@ -15,35 +15,26 @@ func Example() {
 		log.Fatal(err)
 	}
 
-	// rename all the functions and function calls
-	syntax.Walk(f, func(n syntax.Node) bool {
-		switch t := n.(type) {
-		case *syntax.DefStmt:
-			t.Name.Name = "new_" + t.Name.Name
-		case *syntax.CallExpr:
-			if ident, ok := t.Fn.(*syntax.Ident); ok {
-				ident.Name = "new_" + ident.Name
+	// rename all the functions and their references, leaving alone
+	// the locals and the built-ins with the same names
+	for _, s := range f.Stmts {
+		if def, ok := s.(*syntax.DefStmt); ok {
+			if err := Rename(f, def.Name.Name, "new_"+def.Name.Name); err != nil {
+				log.Fatal(err)
 			}
 		}
-		return true
-	})
+	}
 
 	// Build the Starlark source back from the AST tree
 	//
-	// Note that node positions will be ignored
-	var sb strings.Builder
-	sep := ""
-	for _, s := range f.Stmts {
-		sb.WriteString(sep)
-		st, err := StarlarkStmt(s)
-		if err != nil {
-			log.Fatal(err)
-		}
-		sb.WriteString(st)
-		sep = "\n"
+	// Note that node positions will be ignored, the blank lines between
+	// the statements are set by the renderer
+	out, err := StarlarkFile(f)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	fmt.Println(sb.String())
+	fmt.Println(out)
 	// Output: """test import file"""
 	//
 	// def new_foo(n):
@@ -452,7 +443,7 @@ func ExampleWithTupleOption() {
 	}
 	// Output: TupleOptionSingleLine
 	// (foo, bar)
-	// (foo)
+	// (foo,)
 	// ()
 	// TupleOptionSingleLineComma
 	// (foo, bar,)
@@ -460,14 +451,14 @@ func ExampleWithTupleOption() {
 	// ()
 	// TupleOptionSingleLineCommaTwoAndMore
 	// (foo, bar,)
-	// (foo)
+	// (foo,)
 	// ()
 	// TupleOptionMultilineMultiple
 	// (
 	//     foo,
 	//     bar
 	// )
-	// (foo)
+	// (foo,)
 	// ()
 	// TupleOptionMultilineMultipleComma
 	// (
@@ -481,7 +472,7 @@ func ExampleWithTupleOption() {
 	//     foo,
 	//     bar,
 	// )
-	// (foo)
+	// (foo,)
 	// ()
 	// TupleOptionMultiline
 	// (
@@ -489,7 +480,7 @@ func ExampleWithTupleOption() {
 	//     bar
 	// )
 	// (
-	//     foo
+	//     foo,
 	// )
 	// ()
 	// TupleOptionMultilineComma
@@ -507,7 +498,59 @@ func ExampleWithTupleOption() {
 	//     bar,
 	// )
 	// (
-	//     foo
+	//     foo,
 	// )
 	// ()
 }
+
+func ExampleFromGo() {
+	ex, err := FromGo(map[string]interface{}{
+		"name":    "foo",
+		"srcs":    []string{"foo.go", "bar.go"},
+		"enabled": true,
+		"size":    Tuple{640, 480},
+		"parent":  nil,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	st, err := StarlarkExpr(ex, WithDictOption(DictOptionMultilineComma))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(st)
+	// Output: {
+	//     "enabled": True,
+	//     "name": "foo",
+	//     "parent": None,
+	//     "size": (640, 480),
+	//     "srcs": ["foo.go", "bar.go"],
+	// }
+}
+
+func ExampleWithSort() {
+	f, err := syntax.Parse("BUILD", `go_library(
+    name = "foo",
+    srcs = ["b.go", "a.go"],
+    deps = ["@org_golang_x_tools//go/ast", "//bar", ":baz", "//bar"],
+    tags = ["z", "y"],
+)
+`, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := StarlarkFile(f, WithStyle(StyleBuildifierBzl), WithSort(SortKwargs, "srcs", "deps"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(out)
+	// Output: go_library(
+	//     name = "foo",
+	//     srcs = ["a.go", "b.go"],
+	//     deps = [":baz", "//bar", "@org_golang_x_tools//go/ast"],
+	//     tags = ["z", "y"],
+	// )
+}
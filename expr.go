@@ -6,6 +6,7 @@ import (
 	"io"
 	"math/big"
 	"strconv"
+	"strings"
 	"unsafe"
 
 	"go.starlark.net/starlark"
@@ -36,17 +37,24 @@ func writeRepeat(out io.StringWriter, source string, n int) error {
 	return nil
 }
 
-func exprSequence(out io.StringWriter, source []syntax.Expr, ro renderOption, opts *outputOpts) error {
+// exprSequence writes the elements of the sequence node. The multiline
+// sequence has the comments of its elements and the ones before its closing
+// bracket, if they are rendered.
+func exprSequence(out io.StringWriter, node syntax.Node, source []syntax.Expr, ro renderOption, opts *outputOpts) error {
 	var (
 		sep          sepType
 		prefixIndent bool
 		lastComma    bool
 		sourceLen    = len(source)
 		expOpts      *outputOpts
+		cm           = opts.comments
 	)
+	if opts.minify {
+		ro = makeRenderOption(singleLine, noLastComma)
+	}
 	switch ro.multiLineType() {
 	case multiLine:
-		prefixIndent = sourceLen > 0
+		prefixIndent = sourceLen > 0 || cm != nil && len(cm.end[node]) > 0
 	case multiLineMultiple:
 		prefixIndent = sourceLen > 1
 	}
@@ -62,8 +70,8 @@ func exprSequence(out io.StringWriter, source []syntax.Expr, ro renderOption, op
 		if _, err := out.WriteString(newline); err != nil {
 			return fmt.Errorf("NEWLINE token: %w", err)
 		}
-		if err := writeRepeat(out, expOpts.indent, expOpts.depth); err != nil {
-			return fmt.Errorf("indent: %w", err)
+		if err := expOpts.pendingLines(out); err != nil {
+			return err
 		}
 	}
 
@@ -74,21 +82,28 @@ func exprSequence(out io.StringWriter, source []syntax.Expr, ro renderOption, op
 			if _, err := out.WriteString(syntax.COMMA.String()); err != nil {
 				return fmt.Errorf("COMMA token: %w", err)
 			}
-			if _, err := out.WriteString(space); err != nil {
-				return fmt.Errorf("space: %w", err)
+			if !opts.minify {
+				if _, err := out.WriteString(space); err != nil {
+					return fmt.Errorf("space: %w", err)
+				}
 			}
 		case sepCommaNewlineIndent:
 			if _, err := out.WriteString(syntax.COMMA.String()); err != nil {
 				return fmt.Errorf("COMMA token: %w", err)
 			}
-			if _, err := out.WriteString(newline); err != nil {
+			if err := expOpts.newline(out); err != nil {
 				return fmt.Errorf("NEWLINE token: %w", err)
 			}
+		}
+		if prefixIndent {
+			if cm != nil {
+				if err := expOpts.commentLines(out, cm.before[arg]); err != nil {
+					return fmt.Errorf("element %d: %w", i, err)
+				}
+			}
 			if err := writeRepeat(out, expOpts.indent, expOpts.depth); err != nil {
 				return fmt.Errorf("indent: %w", err)
 			}
-		}
-		if prefixIndent {
 			if err := expr(out, arg, expOpts); err != nil {
 				return fmt.Errorf("element %d: %w", i, err)
 			}
@@ -109,7 +124,12 @@ func exprSequence(out io.StringWriter, source []syntax.Expr, ro renderOption, op
 	}
 	// indent and newline for multiline
 	if prefixIndent {
-		if _, err := out.WriteString(newline); err != nil {
+		if cm != nil {
+			if err := expOpts.endComments(out, cm.end[node], sourceLen > 0); err != nil {
+				return err
+			}
+		}
+		if err := opts.newline(out); err != nil {
 			return fmt.Errorf("NEWLINE token: %w", err)
 		}
 		if err := writeRepeat(out, opts.indent, opts.depth); err != nil {
@@ -129,23 +149,32 @@ func binaryExpr(out io.StringWriter, input *syntax.BinaryExpr, opts *outputOpts)
 		return fmt.Errorf("rendering binary expression X: %w", err)
 	}
 
-	if input.Op != syntax.EQ || opts.spaceEqBinary {
+	spaced := input.Op != syntax.EQ || opts.spaceEqBinary
+	if opts.minify {
+		spaced = keywordOp(input.Op)
+	}
+	if spaced {
 		if _, err := out.WriteString(space); err != nil {
 			return fmt.Errorf("rendering binary expression space: %w", err)
 		}
 	}
 
+	setPos(out, &input.OpPos)
 	if _, err := out.WriteString(input.Op.String()); err != nil {
 		return fmt.Errorf("rendering binary expression Op token: %w", err)
 	}
 
-	if input.Op != syntax.EQ || opts.spaceEqBinary {
+	if spaced {
 		if _, err := out.WriteString(space); err != nil {
 			return fmt.Errorf("rendering binary expression space: %w", err)
 		}
 	}
 
-	if err := expr(out, input.Y, opts); err != nil {
+	yOpts := opts
+	if input.Op == syntax.EQ && opts.sortScope != SortNone {
+		yOpts = opts.sortValue(input.X, input, input.X)
+	}
+	if err := expr(out, input.Y, yOpts); err != nil {
 		return fmt.Errorf("rendering binary expression Y: %w", err)
 	}
 
@@ -161,14 +190,21 @@ func callExpr(out io.StringWriter, input *syntax.CallExpr, opts *outputOpts) err
 		return fmt.Errorf("rendering call expression Fn: %w", err)
 	}
 
+	setPos(out, &input.Lparen)
 	if _, err := out.WriteString(syntax.LPAREN.String()); err != nil {
 		return fmt.Errorf("rendering call expression LPAREN token: %w", err)
 	}
 
-	if err := exprSequence(out, input.Args, renderOption(opts.callOption), opts); err != nil {
+	ro := renderOption(opts.callOption)
+	args := opts.rewrites.callArgs(input)
+	if opts.style != StyleDefault {
+		ro = opts.styleSequence(seqCall, input, input.Lparen, args, input.Rparen)
+	}
+	if err := exprSequence(out, input, args, ro, opts); err != nil {
 		return fmt.Errorf("rendering call expression: %w", err)
 	}
 
+	setPos(out, &input.Rparen)
 	if _, err := out.WriteString(syntax.RPAREN.String()); err != nil {
 		return fmt.Errorf("rendering call expression RPAREN token: %w", err)
 	}
@@ -187,10 +223,34 @@ func comprehension(out io.StringWriter, input *syntax.Comprehension, opts *outpu
 		tokens = []syntax.Token{syntax.LBRACE, syntax.RBRACE}
 	}
 
+	setPos(out, &input.Lbrack)
 	if _, err := out.WriteString(tokens[0].String()); err != nil {
 		return fmt.Errorf("rendering comprehension left token: %w", err)
 	}
 
+	// the comprehension multiline in the source or with the comments before
+	// the closing bracket has the body and each clause on its own line
+	outer, multiline := opts, opts.style != StyleDefault && !opts.minify && multilineComprehension(input)
+	if cm := opts.comments; cm != nil && len(cm.end[input]) > 0 {
+		multiline = true
+	}
+	clauseSep := func() error {
+		if !multiline {
+			_, err := out.WriteString(space)
+			return err
+		}
+		if err := opts.newline(out); err != nil {
+			return err
+		}
+		return writeRepeat(out, opts.indent, opts.depth)
+	}
+	if multiline {
+		opts = opts.addDepth(1)
+		if err := clauseSep(); err != nil {
+			return fmt.Errorf("rendering comprehension: %w", err)
+		}
+	}
+
 	if err := expr(out, input.Body, opts); err != nil {
 		return fmt.Errorf("rendering comprehension Body: %w", err)
 	}
@@ -198,9 +258,10 @@ func comprehension(out io.StringWriter, input *syntax.Comprehension, opts *outpu
 	for _, cl := range input.Clauses {
 		switch t := cl.(type) {
 		case *syntax.ForClause:
-			if _, err := out.WriteString(space); err != nil {
+			if err := clauseSep(); err != nil {
 				return fmt.Errorf("rendering comprehension space: %w", err)
 			}
+			setPos(out, &t.For)
 			if _, err := out.WriteString(syntax.FOR.String()); err != nil {
 				return fmt.Errorf("rendering comprehension FOR token: %w", err)
 			}
@@ -213,6 +274,7 @@ func comprehension(out io.StringWriter, input *syntax.Comprehension, opts *outpu
 			if _, err := out.WriteString(space); err != nil {
 				return fmt.Errorf("rendering comprehension space: %w", err)
 			}
+			setPos(out, &t.In)
 			if _, err := out.WriteString(syntax.IN.String()); err != nil {
 				return fmt.Errorf("rendering comprehension IN token: %w", err)
 			}
@@ -223,9 +285,10 @@ func comprehension(out io.StringWriter, input *syntax.Comprehension, opts *outpu
 				return fmt.Errorf("rendering comprehension for clause X: %w", err)
 			}
 		case *syntax.IfClause:
-			if _, err := out.WriteString(space); err != nil {
+			if err := clauseSep(); err != nil {
 				return fmt.Errorf("rendering comprehension space: %w", err)
 			}
+			setPos(out, &t.If)
 			if _, err := out.WriteString(syntax.IF.String()); err != nil {
 				return fmt.Errorf("rendering comprehension IF token: %w", err)
 			}
@@ -238,8 +301,21 @@ func comprehension(out io.StringWriter, input *syntax.Comprehension, opts *outpu
 		default:
 			return fmt.Errorf("unexpected clause type %T rendering comprehension", t)
 		}
+		opts.comments.queue(cl)
 	}
 
+	if multiline {
+		if err := opts.endComments(out, opts.comments.end[input], true); err != nil {
+			return fmt.Errorf("rendering comprehension: %w", err)
+		}
+		if err := outer.newline(out); err != nil {
+			return fmt.Errorf("rendering comprehension NEWLINE token: %w", err)
+		}
+		if err := writeRepeat(out, outer.indent, outer.depth); err != nil {
+			return fmt.Errorf("rendering comprehension indent: %w", err)
+		}
+	}
+	setPos(out, &input.Rbrack)
 	if _, err := out.WriteString(tokens[1].String()); err != nil {
 		return fmt.Errorf("rendering comprehension right token: %w", err)
 	}
@@ -258,6 +334,7 @@ func condExpr(out io.StringWriter, input *syntax.CondExpr, opts *outputOpts) err
 	if _, err := out.WriteString(space); err != nil {
 		return fmt.Errorf("rendering condition expression space: %w", err)
 	}
+	setPos(out, &input.If)
 	if _, err := out.WriteString(syntax.IF.String()); err != nil {
 		return fmt.Errorf("rendering condition expression IF token: %w", err)
 	}
@@ -270,6 +347,7 @@ func condExpr(out io.StringWriter, input *syntax.CondExpr, opts *outputOpts) err
 	if _, err := out.WriteString(space); err != nil {
 		return fmt.Errorf("rendering condition expression space: %w", err)
 	}
+	setPos(out, &input.ElsePos)
 	if _, err := out.WriteString(syntax.ELSE.String()); err != nil {
 		return fmt.Errorf("rendering condition expression ELSE token: %w", err)
 	}
@@ -290,11 +368,14 @@ func dictEntry(out io.StringWriter, input *syntax.DictEntry, opts *outputOpts) e
 	if err := expr(out, input.Key, opts); err != nil {
 		return fmt.Errorf("rendering dict entry Key: %w", err)
 	}
+	setPos(out, &input.Colon)
 	if _, err := out.WriteString(syntax.COLON.String()); err != nil {
 		return fmt.Errorf("rendering dict entry COLON token: %w", err)
 	}
-	if _, err := out.WriteString(space); err != nil {
-		return fmt.Errorf("rendering dict entry space: %w", err)
+	if !opts.minify {
+		if _, err := out.WriteString(space); err != nil {
+			return fmt.Errorf("rendering dict entry space: %w", err)
+		}
 	}
 	if err := expr(out, input.Value, opts); err != nil {
 		return fmt.Errorf("rendering dict entry Value: %w", err)
@@ -315,14 +396,21 @@ func dictExpr(out io.StringWriter, input *syntax.DictExpr, opts *outputOpts) err
 		}
 	}
 
+	setPos(out, &input.Lbrace)
 	if _, err := out.WriteString(syntax.LBRACE.String()); err != nil {
 		return fmt.Errorf("rendering dict expression LBRACE token: %w", err)
 	}
 
-	if err := exprSequence(out, input.List, renderOption(opts.dictOption), opts); err != nil {
+	ro := renderOption(opts.dictOption)
+	if opts.style != StyleDefault {
+		ro = opts.styleSequence(seqDict, input, input.Lbrace, input.List, input.Rbrace)
+	}
+	elems, opts := opts.sortElems(input.List)
+	if err := exprSequence(out, input, elems, ro, opts); err != nil {
 		return fmt.Errorf("rendering dict expression: %w", err)
 	}
 
+	setPos(out, &input.Rbrace)
 	if _, err := out.WriteString(syntax.RBRACE.String()); err != nil {
 		return fmt.Errorf("rendering dict expression RBRACE token: %w", err)
 	}
@@ -338,6 +426,7 @@ func dotExpr(out io.StringWriter, input *syntax.DotExpr, opts *outputOpts) error
 	if err := expr(out, input.X, opts); err != nil {
 		return fmt.Errorf("rendering dot expression X: %w", err)
 	}
+	setPos(out, &input.Dot)
 	if _, err := out.WriteString(syntax.DOT.String()); err != nil {
 		return fmt.Errorf("rendering dot expression DOT token: %w", err)
 	}
@@ -352,8 +441,18 @@ func ident(out io.StringWriter, input *syntax.Ident, opts *outputOpts) error {
 	if input == nil {
 		return errors.New("rendering ident: nil input")
 	}
+	// the names are written verbatim, so the invalid ones would break or
+	// inject the code
+	name := input.Name
+	if !IsIdent(name) {
+		if !opts.sanitizeIdents {
+			return fmt.Errorf("rendering ident: invalid identifier %q", name)
+		}
+		name = SanitizeIdent(name)
+	}
 
-	if _, err := out.WriteString(input.Name); err != nil {
+	setPos(out, &input.NamePos)
+	if _, err := out.WriteString(name); err != nil {
 		return fmt.Errorf("rendering ident Name: %w", err)
 	}
 
@@ -368,12 +467,14 @@ func indexExpr(out io.StringWriter, input *syntax.IndexExpr, opts *outputOpts) e
 	if err := expr(out, input.X, opts); err != nil {
 		return fmt.Errorf("rendering index expression X: %w", err)
 	}
+	setPos(out, &input.Lbrack)
 	if _, err := out.WriteString(syntax.LBRACK.String()); err != nil {
 		return fmt.Errorf("rendering index expression LBRACK token: %w", err)
 	}
 	if err := expr(out, input.Y, opts); err != nil {
 		return fmt.Errorf("rendering index expression Y: %w", err)
 	}
+	setPos(out, &input.Rbrack)
 	if _, err := out.WriteString(syntax.RBRACK.String()); err != nil {
 		return fmt.Errorf("rendering index expression RBRACK token: %w", err)
 	}
@@ -385,14 +486,22 @@ func listExpr(out io.StringWriter, input *syntax.ListExpr, opts *outputOpts) err
 	if input == nil {
 		return errors.New("rendering list expression: nil input")
 	}
+	setPos(out, &input.Lbrack)
 	if _, err := out.WriteString(syntax.LBRACK.String()); err != nil {
 		return fmt.Errorf("rendering list expression LBRACK token: %w", err)
 	}
 
-	if err := exprSequence(out, input.List, renderOption(opts.listOption), opts); err != nil {
+	ro := renderOption(opts.listOption)
+	elems := opts.rewrites.listElems(input)
+	if opts.style != StyleDefault {
+		ro = opts.styleSequence(seqList, input, input.Lbrack, elems, input.Rbrack)
+	}
+	elems, opts = opts.sortElems(elems)
+	if err := exprSequence(out, input, elems, ro, opts); err != nil {
 		return fmt.Errorf("rendering list expression: %w", err)
 	}
 
+	setPos(out, &input.Rbrack)
 	if _, err := out.WriteString(syntax.RBRACK.String()); err != nil {
 		return fmt.Errorf("rendering list expression RBRACK token: %w", err)
 	}
@@ -405,6 +514,24 @@ func literal(out io.StringWriter, input *syntax.Literal, opts *outputOpts) error
 		return errors.New("rendering literal: nil input")
 	}
 
+	// the literal gets the raw token of the output as well
+	if w := positions(out); w != nil {
+		setPos(out, &input.TokenPos)
+		var raw strings.Builder
+		if err := literal(&teeWriter{out: out, buf: &raw}, input, opts); err != nil {
+			return err
+		}
+		token := raw.String()
+		w.updates = append(w.updates, func() { input.Raw = token })
+		return nil
+	}
+
+	if opts.style != StyleDefault {
+		if ok, err := styleLiteral(out, input); ok {
+			return err
+		}
+	}
+
 	if input.Value == nil {
 		if _, err := out.WriteString(input.Raw); err != nil {
 			return fmt.Errorf("rendering literal raw value: %w", err)
@@ -473,12 +600,28 @@ func parenExpr(out io.StringWriter, input *syntax.ParenExpr, opts *outputOpts) e
 		return errors.New("rendering paren expression: nil input")
 	}
 
+	setPos(out, &input.Lparen)
 	if _, err := out.WriteString(syntax.LPAREN.String()); err != nil {
 		return fmt.Errorf("rendering paren expression LPAREN token: %w", err)
 	}
-	if err := expr(out, input.X, opts); err != nil {
+	// the tuple layout depends on the parentheses positions
+	if tuple, ok := input.X.(*syntax.TupleExpr); ok && opts.style != StyleDefault {
+		ro := tupleRenderOption(opts.styleSequence(seqTuple, input, input.Lparen, tuple.List, input.Rparen), tuple.List)
+		if err := exprSequence(out, input, tuple.List, ro, opts); err != nil {
+			return fmt.Errorf("rendering paren expression X: rendering tuple expression: %w", err)
+		}
+		if err := minifiedTupleComma(out, tuple.List, opts); err != nil {
+			return fmt.Errorf("rendering paren expression X: rendering tuple expression: %w", err)
+		}
+	} else if opts.style != StyleDefault {
+		ro := opts.styleSequence(seqParen, input, input.Lparen, []syntax.Expr{input.X}, input.Rparen)
+		if err := exprSequence(out, input, []syntax.Expr{input.X}, ro, opts); err != nil {
+			return fmt.Errorf("rendering paren expression X: %w", err)
+		}
+	} else if err := expr(out, input.X, opts); err != nil {
 		return fmt.Errorf("rendering paren expression X: %w", err)
 	}
+	setPos(out, &input.Rparen)
 	if _, err := out.WriteString(syntax.RPAREN.String()); err != nil {
 		return fmt.Errorf("rendering paren expression RPAREN token: %w", err)
 	}
@@ -494,6 +637,7 @@ func sliceExpr(out io.StringWriter, input *syntax.SliceExpr, opts *outputOpts) e
 	if err := expr(out, input.X, opts); err != nil {
 		return fmt.Errorf("rendering slice expression X: %w", err)
 	}
+	setPos(out, &input.Lbrack)
 	if _, err := out.WriteString(syntax.LBRACK.String()); err != nil {
 		return fmt.Errorf("rendering slice expression LBRACK token: %w", err)
 	}
@@ -522,6 +666,7 @@ func sliceExpr(out io.StringWriter, input *syntax.SliceExpr, opts *outputOpts) e
 		}
 	}
 
+	setPos(out, &input.Rbrack)
 	if _, err := out.WriteString(syntax.RBRACK.String()); err != nil {
 		return fmt.Errorf("rendering slice expression RBRACK token: %w", err)
 	}
@@ -534,13 +679,56 @@ func tupleExpr(out io.StringWriter, input *syntax.TupleExpr, opts *outputOpts) e
 		return errors.New("rendering tuple expression: nil input")
 	}
 
-	if err := exprSequence(out, input.List, renderOption(opts.tupleOption), opts); err != nil {
+	// the parser produces the empty tuple with parentheses positions set,
+	// other tuples are wrapped with *syntax.ParenExpr
+	if len(input.List) == 0 && input.Lparen.IsValid() {
+		setPos(out, &input.Lparen)
+		if _, err := out.WriteString(syntax.LPAREN.String()); err != nil {
+			return fmt.Errorf("rendering tuple expression LPAREN token: %w", err)
+		}
+		setPos(out, &input.Rparen)
+		if _, err := out.WriteString(syntax.RPAREN.String()); err != nil {
+			return fmt.Errorf("rendering tuple expression RPAREN token: %w", err)
+		}
+		return nil
+	}
+
+	ro := renderOption(opts.tupleOption)
+	if opts.style != StyleDefault {
+		ro = opts.styleSequence(seqBareTuple, input, input.Lparen, input.List, input.Rparen)
+	}
+	ro = tupleRenderOption(ro, input.List)
+	if err := exprSequence(out, input, input.List, ro, opts); err != nil {
+		return fmt.Errorf("rendering tuple expression: %w", err)
+	}
+	if err := minifiedTupleComma(out, input.List, opts); err != nil {
 		return fmt.Errorf("rendering tuple expression: %w", err)
 	}
 
 	return nil
 }
 
+// tupleRenderOption adds the last comma the single element tuple requires,
+// otherwise it is rendered as the parenthesized element.
+func tupleRenderOption(ro renderOption, list []syntax.Expr) renderOption {
+	if len(list) == 1 && ro.commaType() != alwaysLastComma {
+		return makeRenderOption(ro.multiLineType(), alwaysLastComma)
+	}
+	return ro
+}
+
+// minifiedTupleComma writes the comma the single element tuple requires,
+// which the minified sequence omits.
+func minifiedTupleComma(out io.StringWriter, list []syntax.Expr, opts *outputOpts) error {
+	if !opts.minify || len(list) != 1 {
+		return nil
+	}
+	if _, err := out.WriteString(syntax.COMMA.String()); err != nil {
+		return fmt.Errorf("COMMA token: %w", err)
+	}
+	return nil
+}
+
 func unaryExpr(out io.StringWriter, input *syntax.UnaryExpr, opts *outputOpts) error {
 	if input == nil {
 		return errors.New("rendering unary expression: nil input")
@@ -554,9 +742,16 @@ func unaryExpr(out io.StringWriter, input *syntax.UnaryExpr, opts *outputOpts) e
 		return fmt.Errorf("rendering unary expression, nil X value for %q token", input.Op)
 	}
 
+	setPos(out, &input.OpPos)
 	if _, err := out.WriteString(input.Op.String()); err != nil {
 		return fmt.Errorf("rendering unary expression, writing %q token: %w", input.Op, err)
 	}
+	// "not" is a keyword and has to be separated from the operand
+	if input.Op == syntax.NOT {
+		if _, err := out.WriteString(space); err != nil {
+			return fmt.Errorf("rendering unary expression space: %w", err)
+		}
+	}
 
 	if input.X != nil {
 		if err := expr(out, input.X, opts); err != nil {
@@ -567,7 +762,25 @@ func unaryExpr(out io.StringWriter, input *syntax.UnaryExpr, opts *outputOpts) e
 	return nil
 }
 
-func expr(out io.StringWriter, input syntax.Expr, opts *outputOpts) error {
+func expr(out io.StringWriter, input syntax.Expr, opts *outputOpts) (err error) {
+	defer func() {
+		if err != nil {
+			err = nodeError(input, err)
+		}
+	}()
+	if sm := sourceMap(out); sm != nil {
+		defer sm.end(sm.begin(input, ""))
+	}
+	if kg := keepGoing(out); kg != nil {
+		defer kg.recover(kg.checkpoint(), input, "", "", &err)
+	}
+	// the end of line comments are written at the next line break
+	defer opts.comments.queue(input)
+	if opts.sortNext && !sortPassThrough(input) {
+		opts = opts.copy()
+		opts.sortNext = false
+	}
+
 	switch t := input.(type) {
 	case *syntax.BinaryExpr:
 		return binaryExpr(out, t, opts)
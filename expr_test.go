@@ -353,6 +353,11 @@ func Test_expr(t *testing.T) {
 			inputSliceExpr: &syntax.SliceExpr{X: &syntax.Ident{Name: "foo"}, Lo: &syntax.Ident{Name: "lo"}, Hi: &syntax.Literal{Value: 2}, Step: &syntax.Literal{Value: 3}},
 			want:           "foo[lo:2:3]",
 		},
+		{
+			name:           "tuple, single element",
+			inputTupleExpr: &syntax.TupleExpr{List: []syntax.Expr{&syntax.Literal{Value: 1}}},
+			want:           "1,",
+		},
 		{
 			name:           "tuple, pair",
 			inputTupleExpr: &syntax.TupleExpr{List: []syntax.Expr{&syntax.Literal{Value: 1}, &syntax.Literal{Value: 2}}},
@@ -368,6 +373,11 @@ func Test_expr(t *testing.T) {
 			inputUnaryExpr: &syntax.UnaryExpr{Op: syntax.MINUS, X: &syntax.Ident{Name: "foo"}},
 			want:           "-foo",
 		},
+		{
+			name:           "unary expr, not",
+			inputUnaryExpr: &syntax.UnaryExpr{Op: syntax.NOT, X: &syntax.Ident{Name: "foo"}},
+			want:           "not foo",
+		},
 		{
 			name:           "unary expr, special case single star",
 			inputUnaryExpr: &syntax.UnaryExpr{Op: syntax.STAR},
@@ -845,7 +855,7 @@ func Test_withTupleOption(t *testing.T) {
 		{
 			withTupleOption: TupleOptionSingleLine,
 			want: map[string]string{
-				"single": "(foo)",
+				"single": "(foo,)",
 				"multi":  "(foo, 1, bar, 2, test, 3)",
 			},
 		},
@@ -859,14 +869,14 @@ func Test_withTupleOption(t *testing.T) {
 		{
 			withTupleOption: TupleOptionSingleLineCommaTwoAndMore,
 			want: map[string]string{
-				"single": "(foo)",
+				"single": "(foo,)",
 				"multi":  "(foo, 1, bar, 2, test, 3,)",
 			},
 		},
 		{
 			withTupleOption: TupleOptionMultiline,
 			want: map[string]string{
-				"single": "(\n++foo\n+)",
+				"single": "(\n++foo,\n+)",
 				"multi":  "(\n++foo,\n++1,\n++bar,\n++2,\n++test,\n++3\n+)",
 			},
 		},
@@ -880,14 +890,14 @@ func Test_withTupleOption(t *testing.T) {
 		{
 			withTupleOption: TupleOptionMultilineCommaTwoAndMore,
 			want: map[string]string{
-				"single": "(\n++foo\n+)",
+				"single": "(\n++foo,\n+)",
 				"multi":  "(\n++foo,\n++1,\n++bar,\n++2,\n++test,\n++3,\n+)",
 			},
 		},
 		{
 			withTupleOption: TupleOptionMultilineMultiple,
 			want: map[string]string{
-				"single": "(foo)",
+				"single": "(foo,)",
 				"multi":  "(\n++foo,\n++1,\n++bar,\n++2,\n++test,\n++3\n+)",
 			},
 		},
@@ -901,7 +911,7 @@ func Test_withTupleOption(t *testing.T) {
 		{
 			withTupleOption: TupleOptionMultilineMultipleCommaTwoAndMore,
 			want: map[string]string{
-				"single": "(foo)",
+				"single": "(foo,)",
 				"multi":  "(\n++foo,\n++1,\n++bar,\n++2,\n++test,\n++3,\n+)",
 			},
 		},
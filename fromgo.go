@@ -0,0 +1,471 @@
+package starlarkgen
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/cyberpossum/starlarkgen/internal/lit"
+	"go.starlark.net/syntax"
+)
+
+// Tuple is rendered as a Starlark tuple by FromGo, all other slices and
+// arrays are rendered as lists.
+type Tuple []interface{}
+
+var (
+	bigIntType    = reflect.TypeOf((*big.Int)(nil))
+	exprType      = reflect.TypeOf((*syntax.Expr)(nil)).Elem()
+	marshalerType = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	calleeType    = reflect.TypeOf((*Callee)(nil)).Elem()
+	tupleType     = reflect.TypeOf(Tuple(nil))
+)
+
+// Marshaler is implemented by the types which provide their own Starlark
+// representation to FromGo.
+type Marshaler interface {
+	MarshalStarlark() (syntax.Expr, error)
+}
+
+// Callee is implemented by the struct types which are converted by FromGo
+// to the calls of the named function instead of the default struct(...)
+// call, e.g. http_archive(...). The name may contain dots, e.g.
+// "native.genrule".
+type Callee interface {
+	StarlarkCallee() string
+}
+
+const defaultCallee = "struct"
+
+// visitKey identifies a reference value on the current conversion path,
+// the type is needed as the pointer to a struct and to its first field
+// are equal.
+type visitKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+type goEncoder struct {
+	visiting map[visitKey]bool
+}
+
+// FromGo converts the Go value to the Starlark expression:
+//   - nil values and nil pointers are converted to None
+//   - bool values are converted to True and False
+//   - strings, integers, *big.Int and floats are converted to literals
+//   - Tuple values are converted to tuples
+//   - other slices and arrays are converted to lists
+//   - maps are converted to dicts, sorted by key
+//   - structs are converted to calls with keyword arguments, see below
+//   - syntax.Expr values are returned as is
+//   - Marshaler values are converted with MarshalStarlark
+//
+// Structs are converted to struct(...) calls, or to the calls of the function
+// returned by StarlarkCallee if the type implements Callee. Each exported
+// field is a keyword argument, in the order of declaration. The keyword name
+// is the field name, unless it is overridden with the struct field tag:
+//
+//   // Field appears as name=...
+//   Field string `starlark:"name"`
+//
+//   // Field is omitted if empty, the keyword name is not changed
+//   Field string `starlark:",omitempty"`
+//
+//   // Field is always omitted
+//   Field string `starlark:"-"`
+//
+// The "omitempty" option skips false, 0, "", nil pointers and interfaces,
+// and empty slices, maps and arrays. The fields of embedded structs without
+// a name in the tag are promoted to the outer struct, the conflicting names
+// are resolved by the encoding/json rules.
+//
+// Pointers and interfaces are followed, cyclic values are reported as an error.
+// Infinite and NaN floats, as well as channels, functions and complex numbers
+// are not supported.
+func FromGo(v interface{}) (syntax.Expr, error) {
+	enc := &goEncoder{visiting: make(map[visitKey]bool)}
+	return enc.value(reflect.ValueOf(v))
+}
+
+func (enc *goEncoder) value(v reflect.Value) (syntax.Expr, error) {
+	if !v.IsValid() {
+		return noneIdent(), nil
+	}
+	if m, ok, err := marshaler(v); ok {
+		if err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	if v.Type().Implements(exprType) {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return noneIdent(), nil
+		}
+		return v.Interface().(syntax.Expr), nil
+	}
+	if v.Type() == bigIntType {
+		if v.IsNil() {
+			return noneIdent(), nil
+		}
+		return &syntax.Literal{Token: syntax.INT, Value: new(big.Int).Set(v.Interface().(*big.Int))}, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return boolIdent(v.Bool()), nil
+	case reflect.String:
+		return &syntax.Literal{Token: syntax.STRING, Value: v.String()}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &syntax.Literal{Token: syntax.INT, Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := v.Uint(); u > math.MaxInt64 {
+			return &syntax.Literal{Token: syntax.INT, Value: new(big.Int).SetUint64(u)}, nil
+		}
+		return &syntax.Literal{Token: syntax.INT, Value: int64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		lt, err := lit.Float(v.Float(), v.Type().Bits())
+		if err != nil {
+			return nil, err
+		}
+		return lt, nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return noneIdent(), nil
+		}
+		if v.Kind() == reflect.Ptr {
+			key := visitKey{ptr: v.Pointer(), typ: v.Type()}
+			if enc.visiting[key] {
+				return nil, fmt.Errorf("cycle detected at %s value", v.Type())
+			}
+			enc.visiting[key] = true
+			defer delete(enc.visiting, key)
+		}
+		return enc.value(v.Elem())
+	case reflect.Slice, reflect.Array:
+		return enc.sequence(v)
+	case reflect.Map:
+		return enc.mapValue(v)
+	case reflect.Struct:
+		return enc.structValue(v)
+	default:
+		return nil, fmt.Errorf("unsupported value kind %s of type %s", v.Kind(), v.Type())
+	}
+}
+
+func (enc *goEncoder) sequence(v reflect.Value) (syntax.Expr, error) {
+	if v.Kind() == reflect.Slice {
+		if v.IsNil() {
+			if v.Type() == tupleType {
+				return &syntax.ParenExpr{X: &syntax.TupleExpr{}}, nil
+			}
+			return &syntax.ListExpr{}, nil
+		}
+		key := visitKey{ptr: v.Pointer(), typ: v.Type()}
+		if enc.visiting[key] {
+			return nil, fmt.Errorf("cycle detected at %s value", v.Type())
+		}
+		enc.visiting[key] = true
+		defer delete(enc.visiting, key)
+	}
+
+	elems := make([]syntax.Expr, v.Len())
+	for i := range elems {
+		e, err := enc.value(v.Index(i))
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		elems[i] = e
+	}
+	if v.Type() == tupleType {
+		return &syntax.ParenExpr{X: &syntax.TupleExpr{List: elems}}, nil
+	}
+	return &syntax.ListExpr{List: elems}, nil
+}
+
+func (enc *goEncoder) mapValue(v reflect.Value) (syntax.Expr, error) {
+	if v.IsNil() {
+		return &syntax.DictExpr{}, nil
+	}
+	key := visitKey{ptr: v.Pointer(), typ: v.Type()}
+	if enc.visiting[key] {
+		return nil, fmt.Errorf("cycle detected at %s value", v.Type())
+	}
+	enc.visiting[key] = true
+	defer delete(enc.visiting, key)
+
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return lessKey(keys[i], keys[j]) })
+
+	res := &syntax.DictExpr{List: make([]syntax.Expr, len(keys))}
+	for i, k := range keys {
+		ke, err := enc.value(k)
+		if err != nil {
+			return nil, fmt.Errorf("key %v: %w", k, err)
+		}
+		ve, err := enc.value(v.MapIndex(k))
+		if err != nil {
+			return nil, fmt.Errorf("value for key %v: %w", k, err)
+		}
+		res.List[i] = &syntax.DictEntry{Key: ke, Value: ve}
+	}
+
+	return res, nil
+}
+
+// marshaler calls MarshalStarlark if v or its address implements Marshaler,
+// the second return value is false if it does not.
+func marshaler(v reflect.Value) (syntax.Expr, bool, error) {
+	if v.Kind() != reflect.Ptr && v.CanAddr() && reflect.PtrTo(v.Type()).Implements(marshalerType) {
+		v = v.Addr()
+	}
+	if !v.Type().Implements(marshalerType) {
+		return nil, false, nil
+	}
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return noneIdent(), true, nil
+	}
+	res, err := v.Interface().(Marshaler).MarshalStarlark()
+	if err != nil {
+		return nil, true, fmt.Errorf("calling MarshalStarlark for %s: %w", v.Type(), err)
+	}
+	if res == nil {
+		return nil, true, fmt.Errorf("calling MarshalStarlark for %s: nil expression returned", v.Type())
+	}
+	return res, true, nil
+}
+
+// structField is the exported struct field rendered as a keyword argument.
+type structField struct {
+	name      string
+	index     []int
+	tagged    bool
+	omitEmpty bool
+}
+
+// structFields lists the fields of the struct type, promoting the fields
+// of the embedded structs. The fields with the same name are resolved the
+// same way encoding/json does: the least nested one wins, then the one with
+// the name set by the tag, and if it is still ambiguous, all of them are
+// omitted.
+func structFields(t reflect.Type) []structField {
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+	var (
+		fields  []structField
+		next    = []embedded{{typ: t}}
+		visited = map[reflect.Type]bool{}
+	)
+	for len(next) > 0 {
+		current := next
+		next = nil
+		for _, e := range current {
+			if visited[e.typ] {
+				continue
+			}
+			for i := 0; i < e.typ.NumField(); i++ {
+				f := e.typ.Field(i)
+				tag := f.Tag.Get("starlark")
+				if tag == "-" {
+					continue
+				}
+				name, opts := tag, ""
+				if n := strings.IndexByte(tag, ','); n >= 0 {
+					name, opts = tag[:n], tag[n+1:]
+				}
+				index := make([]int, len(e.index)+1)
+				copy(index, e.index)
+				index[len(e.index)] = i
+
+				ft := f.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+					next = append(next, embedded{typ: ft, index: index})
+					continue
+				}
+				if f.PkgPath != "" {
+					// unexported
+					continue
+				}
+				field := structField{
+					name:      name,
+					index:     index,
+					tagged:    name != "",
+					omitEmpty: opts == "omitempty",
+				}
+				if field.name == "" {
+					field.name = f.Name
+				}
+				fields = append(fields, field)
+			}
+		}
+		// the types embedded several times at the same depth are expanded
+		// each time, so their fields are ambiguous
+		for _, e := range current {
+			visited[e.typ] = true
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		a, b := fields[i], fields[j]
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		if len(a.index) != len(b.index) {
+			return len(a.index) < len(b.index)
+		}
+		return a.tagged && !b.tagged
+	})
+	res := fields[:0]
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].name == fields[i].name {
+			j++
+		}
+		// the fields are sorted by the depth, then the tagged ones first
+		if j-i == 1 || len(fields[i].index) != len(fields[i+1].index) || fields[i].tagged != fields[i+1].tagged {
+			res = append(res, fields[i])
+		}
+		i = j
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i].index, res[j].index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return res
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex, which reports nil embedded
+// pointers instead of panicking.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+func (enc *goEncoder) structValue(v reflect.Value) (syntax.Expr, error) {
+	callee := defaultCallee
+	if v.Type().Implements(calleeType) {
+		callee = v.Interface().(Callee).StarlarkCallee()
+	} else if v.CanAddr() && reflect.PtrTo(v.Type()).Implements(calleeType) {
+		callee = v.Addr().Interface().(Callee).StarlarkCallee()
+	}
+
+	var fn syntax.Expr
+	for i, part := range strings.Split(callee, ".") {
+		if i == 0 {
+			fn = &syntax.Ident{Name: part}
+			continue
+		}
+		fn = &syntax.DotExpr{X: fn, Name: &syntax.Ident{Name: part}}
+	}
+
+	res := &syntax.CallExpr{Fn: fn}
+	for _, sf := range structFields(v.Type()) {
+		fv, ok := fieldByIndex(v, sf.index)
+		if !ok || sf.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		fe, err := enc.value(fv)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", sf.name, err)
+		}
+		res.Args = append(res.Args, &syntax.BinaryExpr{
+			X:  &syntax.Ident{Name: sf.name},
+			Op: syntax.EQ,
+			Y:  fe,
+		})
+	}
+
+	return res, nil
+}
+
+// lessKey orders the map keys: values of the same kind are compared
+// naturally, different kinds are ordered by kind, and the values which
+// are not naturally ordered by their string representation.
+func lessKey(a, b reflect.Value) bool {
+	for a.Kind() == reflect.Interface && !a.IsNil() {
+		a = a.Elem()
+	}
+	for b.Kind() == reflect.Interface && !b.IsNil() {
+		b = b.Elem()
+	}
+	if ak, bk := keyKind(a), keyKind(b); ak != bk {
+		return ak < bk
+	}
+	switch keyKind(a) {
+	case reflect.String:
+		return a.String() < b.String()
+	case reflect.Int:
+		return a.Int() < b.Int()
+	case reflect.Uint:
+		return a.Uint() < b.Uint()
+	case reflect.Float64:
+		return a.Float() < b.Float()
+	case reflect.Bool:
+		return !a.Bool() && b.Bool()
+	default:
+		return fmt.Sprint(a) < fmt.Sprint(b)
+	}
+}
+
+// keyKind groups the kinds which are compared the same way.
+func keyKind(v reflect.Value) reflect.Kind {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.Int
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return reflect.Uint
+	case reflect.Float32, reflect.Float64:
+		return reflect.Float64
+	default:
+		return v.Kind()
+	}
+}
+
+func boolIdent(b bool) *syntax.Ident {
+	if b {
+		return &syntax.Ident{Name: "True"}
+	}
+	return &syntax.Ident{Name: "False"}
+}
+
+func noneIdent() *syntax.Ident {
+	return &syntax.Ident{Name: "None"}
+}
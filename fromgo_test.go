@@ -0,0 +1,192 @@
+package starlarkgen
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+
+	"go.starlark.net/syntax"
+)
+
+type testArchive struct {
+	Name    string   `starlark:"name"`
+	URLs    []string `starlark:"urls,omitempty"`
+	Sha256  string   `starlark:"sha256,omitempty"`
+	Patches []string `starlark:"-"`
+	ignored string
+}
+
+func (testArchive) StarlarkCallee() string { return "http_archive" }
+
+type testLabel string
+
+func (l *testLabel) MarshalStarlark() (syntax.Expr, error) {
+	if *l == "" {
+		return nil, errors.New("empty label")
+	}
+	return &syntax.CallExpr{Fn: &syntax.Ident{Name: "Label"}, Args: []syntax.Expr{&syntax.Literal{Value: string(*l)}}}, nil
+}
+
+type testNilMarshaler struct{}
+
+func (testNilMarshaler) MarshalStarlark() (syntax.Expr, error) { return nil, nil }
+
+type testBase struct {
+	Visibility []string `starlark:"visibility,omitempty"`
+}
+
+type testRule struct {
+	Name string `starlark:"name"`
+	Dep  *testLabel
+	*testBase
+}
+
+func (*testRule) StarlarkCallee() string { return "native.go_library" }
+
+type testInner struct {
+	A, B, C int
+	E       int `starlark:"D"`
+}
+
+type testOther struct {
+	C, D int
+}
+
+type testShadow struct {
+	A int
+	testInner
+	testOther
+	Self *testShadow
+}
+
+type testRecursive struct {
+	X int
+	*testRecursive
+}
+
+func TestFromGo(t *testing.T) {
+	type named string
+
+	var (
+		cyclicSlice = []interface{}{1, nil}
+		cyclicMap   = map[string]interface{}{}
+		nilPtr      *int
+		two         = 2
+	)
+	cyclicSlice[1] = cyclicSlice
+	cyclicMap["self"] = cyclicMap
+
+	shared := []int{1}
+
+	tests := []struct {
+		name    string
+		input   interface{}
+		opts    []Option
+		want    string
+		wantErr string
+	}{
+		{name: "nil", input: nil, want: "None"},
+		{name: "nil pointer", input: nilPtr, want: "None"},
+		{name: "pointer", input: &two, want: "2"},
+		{name: "bool", input: []bool{true, false}, want: "[True, False]"},
+		{name: "string", input: "foo\n\"bar\"", want: `"foo\n\"bar\""`},
+		{name: "named string", input: named("foo"), want: `"foo"`},
+		{name: "ints", input: []interface{}{-1, int8(2), int16(3), int32(4), int64(5)}, want: "[-1, 2, 3, 4, 5]"},
+		{name: "uints", input: []interface{}{uint(1), uint8(2), uint16(3), uint32(4), uint64(math.MaxUint64)}, want: "[1, 2, 3, 4, 18446744073709551615]"},
+		{name: "big int", input: new(big.Int).Lsh(big.NewInt(1), 70), want: "1180591620717411303424"},
+		{name: "floats", input: []interface{}{1.5, 2.0, float32(0.1), 1e100}, want: "[1.5, 2.0, 0.1, 1e+100]"},
+		{name: "array", input: [2]string{"a", "b"}, want: `["a", "b"]`},
+		{name: "nil slice", input: []string(nil), want: "[]"},
+		{name: "tuple", input: Tuple{1, "a"}, want: `(1, "a")`},
+		{name: "empty tuple", input: Tuple{}, want: "()"},
+		{name: "single element tuple", input: Tuple{1}, want: "(1,)"},
+		{
+			name:  "map, sorted string keys",
+			input: map[string]interface{}{"b": []int{1}, "a": nil, "c": map[int]bool{2: true, -1: false}},
+			want:  `{"a": None, "b": [1], "c": {-1: False, 2: True}}`,
+		},
+		{
+			name:  "map, mixed keys",
+			input: map[interface{}]int{"b": 1, 2: 2, "a": 3, 1: 4, true: 5},
+			want:  `{True: 5, 1: 4, 2: 2, "a": 3, "b": 1}`,
+		},
+		{name: "nil map", input: map[string]int(nil), want: "{}"},
+		{name: "syntax.Expr", input: []interface{}{&syntax.Ident{Name: "foo"}}, want: "[foo]"},
+		{
+			name:  "struct, default callee",
+			input: struct{ A, B int }{A: 1, B: 2},
+			want:  "struct(A=1, B=2)",
+		},
+		{
+			name:  "struct, tags and callee",
+			input: testArchive{Name: "foo", Patches: []string{"a.patch"}, ignored: "foo"},
+			opts:  []Option{WithSpaceEqBinary(true)},
+			want:  `http_archive(name = "foo")`,
+		},
+		{
+			name:  "struct, callee on pointer, marshaler and embedded struct",
+			input: &testRule{Name: "foo", Dep: func() *testLabel { l := testLabel("//bar"); return &l }(), testBase: &testBase{Visibility: []string{"//visibility:public"}}},
+			opts:  []Option{WithCallOption(CallOptionMultilineMultipleCommaTwoAndMore)},
+			want:  "native.go_library(\n    name=\"foo\",\n    Dep=Label(\"//bar\"),\n    visibility=[\"//visibility:public\"],\n)",
+		},
+		{
+			name:  "struct, nil embedded pointer and nil marshaler",
+			input: &testRule{Name: "foo"},
+			want:  `native.go_library(name="foo", Dep=None)`,
+		},
+		{
+			name:  "struct, shadowed and ambiguous embedded fields",
+			input: testShadow{A: 1, testInner: testInner{A: 2, B: 3, C: 4, E: 5}, testOther: testOther{C: 6, D: 7}},
+			want:  "struct(A=1, B=3, D=5, Self=None)",
+		},
+		{
+			name:  "struct, recursive embedded struct",
+			input: testRecursive{X: 1, testRecursive: &testRecursive{X: 2}},
+			want:  "struct(X=1)",
+		},
+		{
+			name:    "marshaler error",
+			input:   map[string]*testRule{"a": {Dep: new(testLabel)}},
+			wantErr: "value for key a: field Dep: calling MarshalStarlark for *starlarkgen.testLabel: empty label",
+		},
+		{
+			name:    "marshaler returns nil",
+			input:   testNilMarshaler{},
+			wantErr: "calling MarshalStarlark for starlarkgen.testNilMarshaler: nil expression returned",
+		},
+		{name: "shared value is not a cycle", input: [][]int{shared, shared}, want: "[[1], [1]]"},
+		{name: "cyclic slice", input: cyclicSlice, wantErr: "element 1: cycle detected at []interface {} value"},
+		{name: "cyclic map", input: cyclicMap, wantErr: "value for key self: cycle detected at map[string]interface {} value"},
+		{name: "unsupported chan", input: make(chan int), wantErr: "unsupported value kind chan of type chan int"},
+		{name: "unsupported func", input: []interface{}{func() {}}, wantErr: "element 0: unsupported value kind func of type func()"},
+		{name: "infinite float", input: math.Inf(-1), wantErr: "float value -Inf has no literal form"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromGo(tt.input)
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				if got != nil {
+					t.Fatalf("expected nil result on error, got %v", got)
+				}
+				if gotErr := err.Error(); gotErr != tt.wantErr {
+					t.Fatalf("expected error %q, got %q", tt.wantErr, gotErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			gotStr, err := StarlarkExpr(got, tt.opts...)
+			if err != nil {
+				t.Fatalf("expected no error rendering %v, got %v", got, err)
+			}
+			if gotStr != tt.want {
+				t.Errorf("want %q, got %q", tt.want, gotStr)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package starlarkgen
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/cyberpossum/starlarkgen/internal/lit"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// ValueConverter converts the starlark.Value types which FromValue does not
+// handle itself. The convert argument converts the nested values with the
+// same set of converters. The converter returns false if it does not handle
+// the type of v, in which case the next converter is tried.
+type ValueConverter func(v starlark.Value, convert func(starlark.Value) (syntax.Expr, error)) (syntax.Expr, bool, error)
+
+// StructConverter is a ValueConverter for the *starlarkstruct.Struct values,
+// which are converted to the calls of the struct constructor with
+// the fields as keyword arguments, sorted by name, e.g.
+//   struct(bar=2, foo=1)
+func StructConverter(v starlark.Value, convert func(starlark.Value) (syntax.Expr, error)) (syntax.Expr, bool, error) {
+	s, ok := v.(*starlarkstruct.Struct)
+	if !ok {
+		return nil, false, nil
+	}
+
+	name := defaultCallee
+	if b, ok := s.Constructor().(*starlark.Builtin); ok {
+		name = b.Name()
+	}
+	res := &syntax.CallExpr{Fn: &syntax.Ident{Name: name}}
+	names := s.AttrNames()
+	sort.Strings(names)
+	for _, n := range names {
+		av, err := s.Attr(n)
+		if err != nil {
+			return nil, true, fmt.Errorf("field %s: %w", n, err)
+		}
+		ae, err := convert(av)
+		if err != nil {
+			return nil, true, fmt.Errorf("field %s: %w", n, err)
+		}
+		res.Args = append(res.Args, &syntax.BinaryExpr{X: &syntax.Ident{Name: n}, Op: syntax.EQ, Y: ae})
+	}
+	return res, true, nil
+}
+
+type valueEncoder struct {
+	converters []ValueConverter
+	visiting   map[starlark.Value]bool
+}
+
+// FromValue converts the go.starlark.net runtime value to the Starlark
+// expression, e.g. to store the evaluation results as source code:
+//   - starlark.String, starlark.Int and starlark.Float are converted to literals
+//   - starlark.Bool and starlark.None are converted to True, False and None
+//   - *starlark.List and starlark.Tuple are converted to lists and tuples
+//   - *starlark.Dict is converted to dict in the insertion order
+//   - *starlark.Set is converted to the set([...]) call
+//   - values implementing Marshaler are converted with MarshalStarlark
+//
+// The converters are tried in order before the rules above, use them to handle
+// the custom types, e.g. StructConverter for *starlarkstruct.Struct values.
+// Cyclic values, infinite and NaN floats, functions and other types without
+// the converter are reported as an error.
+func FromValue(v starlark.Value, converters ...ValueConverter) (syntax.Expr, error) {
+	enc := &valueEncoder{converters: converters, visiting: make(map[starlark.Value]bool)}
+	return enc.value(v)
+}
+
+func (enc *valueEncoder) value(v starlark.Value) (syntax.Expr, error) {
+	if v == nil {
+		return nil, errors.New("nil starlark.Value")
+	}
+	for _, c := range enc.converters {
+		res, ok, err := c(v, enc.value)
+		if !ok {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+	if m, ok := v.(Marshaler); ok {
+		res, err := m.MarshalStarlark()
+		if err != nil {
+			return nil, fmt.Errorf("calling MarshalStarlark for %s: %w", v.Type(), err)
+		}
+		if res == nil {
+			return nil, fmt.Errorf("calling MarshalStarlark for %s: nil expression returned", v.Type())
+		}
+		return res, nil
+	}
+
+	switch t := v.(type) {
+	case starlark.NoneType:
+		return noneIdent(), nil
+	case starlark.Bool:
+		return boolIdent(bool(t)), nil
+	case starlark.String:
+		return &syntax.Literal{Token: syntax.STRING, Value: string(t)}, nil
+	case starlark.Int:
+		if i, ok := t.Int64(); ok {
+			return &syntax.Literal{Token: syntax.INT, Value: i}, nil
+		}
+		return &syntax.Literal{Token: syntax.INT, Value: t.BigInt()}, nil
+	case starlark.Float:
+		lt, err := lit.Float(float64(t), 64)
+		if err != nil {
+			return nil, err
+		}
+		return lt, nil
+	case starlark.Tuple:
+		elems, err := enc.sequence(t)
+		if err != nil {
+			return nil, err
+		}
+		return &syntax.ParenExpr{X: &syntax.TupleExpr{List: elems}}, nil
+	case *starlark.List:
+		return enc.reference(t, func() (syntax.Expr, error) {
+			elems := make([]starlark.Value, t.Len())
+			for i := range elems {
+				elems[i] = t.Index(i)
+			}
+			res, err := enc.sequence(elems)
+			if err != nil {
+				return nil, err
+			}
+			return &syntax.ListExpr{List: res}, nil
+		})
+	case *starlark.Dict:
+		return enc.reference(t, func() (syntax.Expr, error) {
+			items := t.Items()
+			res := &syntax.DictExpr{List: make([]syntax.Expr, len(items))}
+			for i, item := range items {
+				k, err := enc.value(item[0])
+				if err != nil {
+					return nil, fmt.Errorf("key %s: %w", item[0], err)
+				}
+				v, err := enc.value(item[1])
+				if err != nil {
+					return nil, fmt.Errorf("value for key %s: %w", item[0], err)
+				}
+				res.List[i] = &syntax.DictEntry{Key: k, Value: v}
+			}
+			return res, nil
+		})
+	case *starlark.Set:
+		return enc.reference(t, func() (syntax.Expr, error) {
+			var elems []starlark.Value
+			iter := t.Iterate()
+			defer iter.Done()
+			var x starlark.Value
+			for iter.Next(&x) {
+				elems = append(elems, x)
+			}
+			res, err := enc.sequence(elems)
+			if err != nil {
+				return nil, err
+			}
+			return &syntax.CallExpr{
+				Fn:   &syntax.Ident{Name: "set"},
+				Args: []syntax.Expr{&syntax.ListExpr{List: res}},
+			}, nil
+		})
+	default:
+		return nil, fmt.Errorf("unsupported value type %s", v.Type())
+	}
+}
+
+// reference converts the mutable value, checking for cycles.
+func (enc *valueEncoder) reference(v starlark.Value, convert func() (syntax.Expr, error)) (syntax.Expr, error) {
+	if enc.visiting[v] {
+		return nil, fmt.Errorf("cycle detected at %s value", v.Type())
+	}
+	enc.visiting[v] = true
+	defer delete(enc.visiting, v)
+	return convert()
+}
+
+func (enc *valueEncoder) sequence(source []starlark.Value) ([]syntax.Expr, error) {
+	res := make([]syntax.Expr, len(source))
+	for i, elem := range source {
+		e, err := enc.value(elem)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		res[i] = e
+	}
+	return res, nil
+}
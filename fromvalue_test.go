@@ -0,0 +1,153 @@
+package starlarkgen
+
+import (
+	"errors"
+	"testing"
+
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+type testValue struct{ starlark.NoneType }
+
+func (testValue) MarshalStarlark() (syntax.Expr, error) {
+	return &syntax.CallExpr{Fn: &syntax.Ident{Name: "custom"}}, nil
+}
+
+func TestFromValue(t *testing.T) {
+	const source = `
+config = {
+    "name": "foo",
+    "count": 3,
+    "big": 1 << 70,
+    "ratio": 0.5,
+    "enabled": True,
+    "parent": None,
+    "srcs": ["a.go", "b.go"],
+    "size": (640, 480),
+    "unique": set(["b", "a", "b"]),
+    "struct": struct(z = 1, a = [2]),
+}
+cyclic = [1]
+cyclic.append(cyclic)
+func = len
+inf = 1e308 * 10
+`
+	// floats and sets are optional features of this go.starlark.net version
+	allowFloat, allowSet := resolve.AllowFloat, resolve.AllowSet
+	defer func() { resolve.AllowFloat, resolve.AllowSet = allowFloat, allowSet }()
+	resolve.AllowFloat, resolve.AllowSet = true, true
+
+	predeclared := starlark.StringDict{"struct": starlark.NewBuiltin("struct", starlarkstruct.Make)}
+	globals, err := starlark.ExecFile(&starlark.Thread{}, "test.star", source, predeclared)
+	if err != nil {
+		t.Fatalf("error executing source: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		input      starlark.Value
+		converters []ValueConverter
+		opts       []Option
+		want       string
+		wantErr    string
+	}{
+		{
+			name:       "dict with struct converter",
+			input:      globals["config"],
+			converters: []ValueConverter{StructConverter},
+			opts:       []Option{WithDictOption(DictOptionMultilineComma)},
+			want: `{
+    "name": "foo",
+    "count": 3,
+    "big": 1180591620717411303424,
+    "ratio": 0.5,
+    "enabled": True,
+    "parent": None,
+    "srcs": ["a.go", "b.go"],
+    "size": (640, 480),
+    "unique": set(["b", "a"]),
+    "struct": struct(a=[2], z=1),
+}`,
+		},
+		{
+			name:    "struct without converter",
+			input:   globals["config"],
+			wantErr: `value for key "struct": unsupported value type struct`,
+		},
+		{
+			name:  "custom converter",
+			input: starlark.NewList([]starlark.Value{starlark.String("a"), starlark.MakeInt(1)}),
+			converters: []ValueConverter{func(v starlark.Value, convert func(starlark.Value) (syntax.Expr, error)) (syntax.Expr, bool, error) {
+				if s, ok := v.(starlark.String); ok {
+					return &syntax.Ident{Name: string(s)}, true, nil
+				}
+				return nil, false, nil
+			}},
+			want: "[a, 1]",
+		},
+		{
+			name:  "single element tuple",
+			input: starlark.Tuple{starlark.MakeInt(1)},
+			want:  "(1,)",
+		},
+		{
+			name:  "converter error",
+			input: starlark.Tuple{starlark.MakeInt(1)},
+			converters: []ValueConverter{func(v starlark.Value, convert func(starlark.Value) (syntax.Expr, error)) (syntax.Expr, bool, error) {
+				return nil, true, errors.New("failed")
+			}},
+			wantErr: "failed",
+		},
+		{
+			name:  "marshaler",
+			input: starlark.NewList([]starlark.Value{testValue{}}),
+			want:  "[custom()]",
+		},
+		{
+			name:    "cycle",
+			input:   globals["cyclic"],
+			wantErr: "element 1: cycle detected at list value",
+		},
+		{
+			name:    "function",
+			input:   globals["func"],
+			wantErr: "unsupported value type builtin_function_or_method",
+		},
+		{
+			name:    "infinite float",
+			input:   globals["inf"],
+			wantErr: "float value +Inf has no literal form",
+		},
+		{
+			name:    "nil",
+			wantErr: "nil starlark.Value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromValue(tt.input, tt.converters...)
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				if gotErr := err.Error(); gotErr != tt.wantErr {
+					t.Fatalf("expected error %q, got %q", tt.wantErr, gotErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			gotStr, err := StarlarkExpr(got, tt.opts...)
+			if err != nil {
+				t.Fatalf("expected no error rendering %v, got %v", got, err)
+			}
+			if gotStr != tt.want {
+				t.Errorf("want %q, got %q", tt.want, gotStr)
+			}
+		})
+	}
+}
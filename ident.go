@@ -0,0 +1,84 @@
+package starlarkgen
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// keywords are the Starlark keywords and the reserved words, which can't be
+// used as identifiers. The set is the one of the go.starlark.net scanner,
+// e.g. "assert", "async" and "await" are the identifiers there.
+var keywords = map[string]bool{
+	"and": true, "break": true, "continue": true, "def": true, "elif": true,
+	"else": true, "for": true, "if": true, "in": true, "lambda": true,
+	"load": true, "not": true, "or": true, "pass": true, "return": true,
+	"while": true,
+
+	"as": true, "class": true, "del": true, "except": true, "finally": true,
+	"from": true, "global": true, "import": true, "is": true, "nonlocal": true,
+	"raise": true, "try": true, "with": true, "yield": true,
+}
+
+func isIdentStart(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentRune(r rune) bool {
+	return isIdentStart(r) || '0' <= r && r <= '9'
+}
+
+// IsIdent reports whether the name is the valid Starlark identifier: it is
+// made of the letters, the ASCII digits and the underscores, does not start
+// with a digit, and is not a keyword or a reserved word, e.g. "def" or "class".
+func IsIdent(name string) bool {
+	if name == "" || keywords[name] {
+		return false
+	}
+	for i, r := range name {
+		if r == utf8.RuneError || !isIdentRune(r) || i == 0 && !isIdentStart(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// SanitizeIdent turns the string into the valid Starlark identifier, see
+// IsIdent: the characters not allowed are replaced with the underscores,
+// the underscore is prepended if the string is empty or starts with
+// a digit, and appended to the keywords, e.g.
+//   "my-rule"  -> "my_rule"
+//   "1st"      -> "_1st"
+//   "def"      -> "def_"
+// The result is deterministic, but different strings might produce the same
+// identifier, e.g. "a-b" and "a.b".
+func SanitizeIdent(s string) string {
+	if IsIdent(s) {
+		return s
+	}
+	var sb strings.Builder
+	for i, r := range s {
+		if i == 0 && !isIdentStart(r) && isIdentRune(r) {
+			sb.WriteByte('_')
+		}
+		if r == utf8.RuneError || !isIdentRune(r) {
+			r = '_'
+		}
+		sb.WriteRune(r)
+	}
+	res := sb.String()
+	if res == "" || keywords[res] {
+		res += "_"
+	}
+	return res
+}
+
+// WithSanitizedIdents makes the identifiers which are not valid, see IsIdent,
+// rendered with SanitizeIdent, instead of failing the rendering.
+func WithSanitizedIdents(value bool) Option {
+	return func(o *outputOpts) (*outputOpts, error) {
+		c := o.copy()
+		c.sanitizeIdents = value
+		return c, nil
+	}
+}
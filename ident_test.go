@@ -0,0 +1,151 @@
+package starlarkgen
+
+import (
+	"strings"
+	"testing"
+
+	"go.starlark.net/syntax"
+)
+
+func TestIsIdent(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "foo", want: true},
+		{name: "_foo_1", want: true},
+		{name: "Δx", want: true},
+		{name: ""},
+		{name: "my rule"},
+		{name: "my-rule"},
+		{name: "1st"},
+		{name: "def"},
+		{name: "class"},
+		{name: "assert", want: true},
+		{name: "async", want: true},
+		{name: "await", want: true},
+		{name: "a.b"},
+		{name: "x\nfail()"},
+		{name: "a\xffb"},
+		{name: "a١"},
+	}
+	for _, tt := range tests {
+		if got := IsIdent(tt.name); got != tt.want {
+			t.Errorf("IsIdent(%q): expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+// TestIsIdent_parser checks the keywords are the ones the parser rejects as
+// identifiers.
+func TestIsIdent_parser(t *testing.T) {
+	words := []string{"assert", "async", "await", "print", "True", "None", "struct", "a١"}
+	for k := range keywords {
+		words = append(words, k)
+	}
+	for _, w := range words {
+		_, err := syntax.Parse("test.star", w+" = 1\n", 0)
+		if got, want := IsIdent(w), err == nil; got != want {
+			t.Errorf("IsIdent(%q): expected %v, got %v", w, want, got)
+		}
+	}
+}
+
+func TestIdent_parsed(t *testing.T) {
+	const src = "assert(x)\nasync = 1\nawait = async\n"
+	f, err := syntax.Parse("test.star", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := StarlarkFile(f, WithBlankLines(BlankLinePolicy{}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != src {
+		t.Errorf("want\n%s\ngot\n%s", src, got)
+	}
+}
+
+func TestSanitizeIdent(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{in: "foo", want: "foo"},
+		{in: "my-rule", want: "my_rule"},
+		{in: "my rule", want: "my_rule"},
+		{in: "1st", want: "_1st"},
+		{in: "def", want: "def_"},
+		{in: "", want: "_"},
+		{in: "x\nfail()", want: "x_fail__"},
+		{in: "-", want: "_"},
+		{in: "a\xffb", want: "a_b"},
+		{in: "a١", want: "a_"},
+		{in: "١", want: "_"},
+	}
+	for _, tt := range tests {
+		got := SanitizeIdent(tt.in)
+		if got != tt.want {
+			t.Errorf("SanitizeIdent(%q): expected %q, got %q", tt.in, tt.want, got)
+		}
+		if !IsIdent(got) {
+			t.Errorf("SanitizeIdent(%q): %q is not an identifier", tt.in, got)
+		}
+	}
+}
+
+func TestIdent_invalid(t *testing.T) {
+	ident := func(name string) *syntax.Ident { return &syntax.Ident{Name: name} }
+	tests := []struct {
+		name    string
+		input   syntax.Stmt
+		wantErr string
+		want    string
+	}{
+		{
+			name:    "injected ident",
+			input:   &syntax.ExprStmt{X: ident("x\nfail()")},
+			wantErr: `invalid identifier "x\nfail()"`,
+			want:    "x_fail__\n",
+		},
+		{
+			name:    "dot expression name",
+			input:   &syntax.ExprStmt{X: &syntax.DotExpr{X: ident("native"), Name: ident("my-rule")}},
+			wantErr: `invalid identifier "my-rule"`,
+			want:    "native.my_rule\n",
+		},
+		{
+			name: "kwarg name",
+			input: &syntax.ExprStmt{X: &syntax.CallExpr{Fn: ident("foo"), Args: []syntax.Expr{
+				&syntax.BinaryExpr{X: ident("if"), Op: syntax.EQ, Y: ident("a")},
+			}}},
+			wantErr: `invalid identifier "if"`,
+			want:    "foo(if_=a)\n",
+		},
+		{
+			name:    "def name",
+			input:   &syntax.DefStmt{Name: ident("1f"), Body: []syntax.Stmt{&syntax.ReturnStmt{}}},
+			wantErr: `invalid identifier "1f"`,
+			want:    "def _1f():\n    return\n",
+		},
+		{
+			name:    "param name",
+			input:   &syntax.DefStmt{Name: ident("f"), Params: []syntax.Expr{ident("a b")}, Body: []syntax.Stmt{&syntax.ReturnStmt{}}},
+			wantErr: `invalid identifier "a b"`,
+			want:    "def f(a_b):\n    return\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := StarlarkStmt(tt.input); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+			got, err := StarlarkStmt(tt.input, WithSanitizedIdents(true))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("want\n%s\ngot\n%s", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+// Package lit builds the literal nodes shared by starlarkgen and its
+// subpackages.
+package lit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"go.starlark.net/syntax"
+)
+
+// Float formats the float with the shortest representation for the bit
+// size, which is still parsed as a float, e.g. 1.0 instead of 1. Infinite
+// and NaN values have no literal form and cause an error.
+func Float(f float64, bitSize int) (*syntax.Literal, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("float value %v has no literal form", f)
+	}
+	raw := strconv.FormatFloat(f, 'g', -1, bitSize)
+	if !strings.ContainsAny(raw, ".e") {
+		raw += ".0"
+	}
+	return &syntax.Literal{Token: syntax.FLOAT, Raw: raw}, nil
+}
@@ -0,0 +1,39 @@
+package lit
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFloat(t *testing.T) {
+	tests := []struct {
+		value   float64
+		bitSize int
+		want    string
+		wantErr string
+	}{
+		{value: 1, bitSize: 64, want: "1.0"},
+		{value: 1.5, bitSize: 64, want: "1.5"},
+		{value: 1e100, bitSize: 64, want: "1e+100"},
+		{value: float64(float32(0.1)), bitSize: 32, want: "0.1"},
+		{value: float64(float32(0.1)), bitSize: 64, want: "0.10000000149011612"},
+		{value: math.Inf(-1), bitSize: 64, wantErr: "float value -Inf has no literal form"},
+		{value: math.NaN(), bitSize: 64, wantErr: "float value NaN has no literal form"},
+	}
+	for _, tt := range tests {
+		got, err := Float(tt.value, tt.bitSize)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Float(%v, %d): expected error %q, got %v", tt.value, tt.bitSize, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Float(%v, %d): unexpected error: %v", tt.value, tt.bitSize, err)
+			continue
+		}
+		if got.Raw != tt.want {
+			t.Errorf("Float(%v, %d): expected %q, got %q", tt.value, tt.bitSize, tt.want, got.Raw)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package starlarkgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/cyberpossum/starlarkgen/internal/lit"
+	"go.starlark.net/syntax"
+)
+
+// FromJSON converts the single JSON value read from r to the Starlark
+// expression. Objects are converted to dicts keeping the key order, arrays are
+// converted to lists, true, false and null are converted to True, False and
+// None. Integer numbers keep the full precision, using *big.Int values if
+// needed, other numbers are converted to float literals.
+//
+// The input is streamed using the json.Decoder tokens, use the dict and list
+// options when rendering the result to control the multiline layout.
+func FromJSON(r io.Reader) (syntax.Expr, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	res, err := jsonValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		if err != nil {
+			return nil, fmt.Errorf("reading JSON: %w", err)
+		}
+		return nil, errors.New("reading JSON: unexpected data after the top-level value")
+	}
+
+	return res, nil
+}
+
+// WriteJSON converts the JSON value read from r to Starlark and writes it to
+// the output using the options supplied, see FromJSON.
+func WriteJSON(output io.StringWriter, r io.Reader, options ...Option) error {
+	opts, err := getOutputOpts(options...)
+	if err != nil {
+		return err
+	}
+	x, err := FromJSON(r)
+	if err != nil {
+		return err
+	}
+	return expr(output, x, opts)
+}
+
+func jsonValue(dec *json.Decoder) (syntax.Expr, error) {
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return nil, errors.New("reading JSON: unexpected end of input")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading JSON: %w", err)
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '[':
+			res := &syntax.ListExpr{}
+			for dec.More() {
+				x, err := jsonValue(dec)
+				if err != nil {
+					return nil, fmt.Errorf("element %d: %w", len(res.List), err)
+				}
+				res.List = append(res.List, x)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, fmt.Errorf("reading JSON: %w", err)
+			}
+			return res, nil
+		case '{':
+			res := &syntax.DictExpr{}
+			for dec.More() {
+				kt, err := dec.Token()
+				if err != nil {
+					return nil, fmt.Errorf("reading JSON: %w", err)
+				}
+				// the decoder guarantees object keys are strings
+				key := kt.(string)
+				x, err := jsonValue(dec)
+				if err != nil {
+					return nil, fmt.Errorf("key %q: %w", key, err)
+				}
+				res.List = append(res.List, &syntax.DictEntry{
+					Key:   &syntax.Literal{Token: syntax.STRING, Value: key},
+					Value: x,
+				})
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, fmt.Errorf("reading JSON: %w", err)
+			}
+			return res, nil
+		default:
+			return nil, fmt.Errorf("reading JSON: unexpected delimiter %v", t)
+		}
+	case string:
+		return &syntax.Literal{Token: syntax.STRING, Value: t}, nil
+	case json.Number:
+		return jsonNumber(t)
+	case bool:
+		return boolIdent(t), nil
+	case nil:
+		return noneIdent(), nil
+	default:
+		return nil, fmt.Errorf("reading JSON: unexpected token %T", t)
+	}
+}
+
+func jsonNumber(n json.Number) (syntax.Expr, error) {
+	s := n.String()
+	if !strings.ContainsAny(s, ".eE") {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return &syntax.Literal{Token: syntax.INT, Value: i}, nil
+		}
+		if b, ok := new(big.Int).SetString(s, 10); ok {
+			return &syntax.Literal{Token: syntax.INT, Value: b}, nil
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("converting JSON number %s: %w", s, err)
+	}
+	lt, err := lit.Float(f, 64)
+	if err != nil {
+		return nil, fmt.Errorf("converting JSON number %s: %w", s, err)
+	}
+	return lt, nil
+}
+
+// ToJSON converts the pure-data Starlark expression to JSON without
+// evaluating it, it is the inverse of FromJSON:
+//   - string, int and float literals, optionally negated, are converted to
+//     JSON strings and numbers, ints keep the full precision
+//   - True, False and None are converted to true, false and null
+//   - lists and tuples are converted to arrays
+//   - dicts with string keys are converted to objects keeping the key order
+//
+// Any other expression, such as calls, other identifiers or comprehensions,
+// is reported as an *UnmarshalError with the expression position.
+// The result is compact, use json.Indent to format it.
+func ToJSON(input syntax.Expr) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeJSONValue(&buf, input); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// FileToJSON converts the file made of NAME = <literal> assignments to
+// the JSON object with the NAME keys in the order of the assignments, see
+// ToJSON for the expression rules. Docstrings are skipped.
+func FileToJSON(f *syntax.File) ([]byte, error) {
+	if f == nil {
+		return nil, errors.New("converting to JSON: nil file")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	seen := make(map[string]bool, len(f.Stmts))
+	for i, st := range f.Stmts {
+		if es, ok := st.(*syntax.ExprStmt); ok && isDocstring(es) {
+			continue
+		}
+		as, ok := st.(*syntax.AssignStmt)
+		if !ok || as.Op != syntax.EQ {
+			return nil, withPath(unmarshalErrorf(st, "expected NAME = <literal> assignment, got %T", st), fmt.Sprintf("statement index %d", i))
+		}
+		id, ok := as.LHS.(*syntax.Ident)
+		if !ok {
+			return nil, withPath(unmarshalErrorf(as.LHS, "expected identifier on the left side of assignment, got %T", as.LHS), fmt.Sprintf("statement index %d", i))
+		}
+		if seen[id.Name] {
+			return nil, unmarshalErrorf(as, "duplicate assignment to %s", id.Name)
+		}
+		if len(seen) > 0 {
+			buf.WriteByte(',')
+		}
+		seen[id.Name] = true
+		writeJSONString(&buf, id.Name)
+		buf.WriteByte(':')
+		if err := writeJSONValue(&buf, as.RHS); err != nil {
+			return nil, withPath(err, id.Name)
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func writeJSONValue(buf *bytes.Buffer, input syntax.Expr) error {
+	switch t := input.(type) {
+	case nil:
+		return unmarshalErrorf(nil, "nil expression")
+	case *syntax.ParenExpr:
+		return writeJSONValue(buf, t.X)
+	case *syntax.Literal:
+		return writeJSONLiteral(buf, t, false)
+	case *syntax.UnaryExpr:
+		lt, ok := t.X.(*syntax.Literal)
+		if !ok || (t.Op != syntax.MINUS && t.Op != syntax.PLUS) {
+			return unmarshalErrorf(t, "unsupported unary expression, only numeric literals with sign are allowed")
+		}
+		if _, ok := lt.Value.(string); ok {
+			return unmarshalErrorf(t, "unsupported unary expression, only numeric literals with sign are allowed")
+		}
+		return writeJSONLiteral(buf, lt, t.Op == syntax.MINUS)
+	case *syntax.Ident:
+		switch t.Name {
+		case "True":
+			buf.WriteString("true")
+		case "False":
+			buf.WriteString("false")
+		case "None":
+			buf.WriteString("null")
+		default:
+			return unmarshalErrorf(t, "unsupported identifier %q, only True, False and None are allowed", t.Name)
+		}
+		return nil
+	case *syntax.ListExpr:
+		return writeJSONArray(buf, t.List)
+	case *syntax.TupleExpr:
+		return writeJSONArray(buf, t.List)
+	case *syntax.DictExpr:
+		buf.WriteByte('{')
+		seen := make(map[string]bool, len(t.List))
+		for i, elem := range t.List {
+			de, ok := elem.(*syntax.DictEntry)
+			if !ok {
+				return unmarshalErrorf(elem, "expected *syntax.DictEntry, got %T in dict", elem)
+			}
+			lt, ok := de.Key.(*syntax.Literal)
+			if !ok {
+				return withPath(unmarshalErrorf(de.Key, "unsupported dict key %T, only string keys are allowed", de.Key), fmt.Sprintf("key %d", i))
+			}
+			key, ok := lt.Value.(string)
+			if !ok {
+				return withPath(unmarshalErrorf(de.Key, "unsupported dict key %s, only string keys are allowed", lt.Token), fmt.Sprintf("key %d", i))
+			}
+			if seen[key] {
+				return unmarshalErrorf(de, "duplicate key %q", key)
+			}
+			seen[key] = true
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONString(buf, key)
+			buf.WriteByte(':')
+			if err := writeJSONValue(buf, de.Value); err != nil {
+				return withPath(err, key)
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	default:
+		return unmarshalErrorf(input, "unsupported expression %T, only literals, lists, tuples and dicts are allowed", input)
+	}
+}
+
+func writeJSONArray(buf *bytes.Buffer, elems []syntax.Expr) error {
+	buf.WriteByte('[')
+	for i, e := range elems {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := writeJSONValue(buf, e); err != nil {
+			return withPath(err, fmt.Sprintf("element %d", i))
+		}
+	}
+	buf.WriteByte(']')
+	return nil
+}
+
+func writeJSONLiteral(buf *bytes.Buffer, input *syntax.Literal, negate bool) error {
+	val, err := literalValue(input)
+	if err != nil {
+		return unmarshalErrorf(input, "%w", err)
+	}
+
+	switch t := val.(type) {
+	case string:
+		writeJSONString(buf, t)
+	case int64:
+		if negate {
+			buf.WriteString(new(big.Int).Neg(big.NewInt(t)).String())
+		} else {
+			buf.WriteString(strconv.FormatInt(t, 10))
+		}
+	case *big.Int:
+		if negate {
+			buf.WriteString(new(big.Int).Neg(t).String())
+		} else {
+			buf.WriteString(t.String())
+		}
+	case float64:
+		if negate {
+			t = -t
+		}
+		b, err := json.Marshal(t)
+		if err != nil {
+			return unmarshalErrorf(input, "%w", err)
+		}
+		buf.Write(b)
+	}
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	// encoding a string never fails, drop the newline Encode appends
+	_ = enc.Encode(s)
+	buf.Truncate(buf.Len() - 1)
+}
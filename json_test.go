@@ -0,0 +1,239 @@
+package starlarkgen
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go.starlark.net/syntax"
+)
+
+func TestFromJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    []Option
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "key order preserved",
+			input: `{"z": 1, "a": 2, "m": {"y": null, "b": [true, false]}}`,
+			want:  `{"z": 1, "a": 2, "m": {"y": None, "b": [True, False]}}`,
+		},
+		{
+			name:  "numbers",
+			input: `[0, -12, 9223372036854775807, 9223372036854775808, -99999999999999999999, 1.5, -0.25, 1e3, 2E-3, 1.0]`,
+			want:  `[0, -12, 9223372036854775807, 9223372036854775808, -99999999999999999999, 1.5, -0.25, 1000.0, 0.002, 1.0]`,
+		},
+		{
+			name:  "strings",
+			input: `["a\"b", "é\n"]`,
+			want:  `["a\"b", "é\n"]`,
+		},
+		{
+			name:  "multiline layout",
+			input: `{"a": [1, 2], "b": {}}`,
+			opts:  []Option{WithDictOption(DictOptionMultilineComma), WithListOption(ListOptionMultilineMultipleComma)},
+			want:  "{\n    \"a\": [\n        1,\n        2,\n    ],\n    \"b\": {},\n}",
+		},
+		{
+			name:  "scalar",
+			input: ` "foo" `,
+			want:  `"foo"`,
+		},
+		{
+			name:    "empty input",
+			input:   "",
+			wantErr: "reading JSON: unexpected end of input",
+		},
+		{
+			name:    "invalid nested value",
+			input:   `{"a": [1, 2, x]}`,
+			wantErr: `key "a": element 2: reading JSON: invalid character 'x' looking for beginning of value`,
+		},
+		{
+			name:    "trailing data",
+			input:   `{} []`,
+			wantErr: "reading JSON: unexpected data after the top-level value",
+		},
+		{
+			name:    "number out of range",
+			input:   `1e999`,
+			wantErr: `converting JSON number 1e999: strconv.ParseFloat: parsing "1e999": value out of range`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			err := WriteJSON(&sb, strings.NewReader(tt.input), tt.opts...)
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				if gotErr := err.Error(); gotErr != tt.wantErr {
+					t.Fatalf("expected error %q, got %q", tt.wantErr, gotErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got := sb.String(); got != tt.want {
+				t.Errorf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "scalars",
+			source: `["a<b>\n", 1, -2, 0x10, 12345678901234567890, -12345678901234567890, 1.5, -0.25, True, False, None]`,
+			want:   `["a<b>\n",1,-2,16,12345678901234567890,-12345678901234567890,1.5,-0.25,true,false,null]`,
+		},
+		{
+			name:   "key order preserved",
+			source: `{"z": (1, 2), "a": {"y": [], "b": {}}}`,
+			want:   `{"z":[1,2],"a":{"y":[],"b":{}}}`,
+		},
+		{
+			name:    "call",
+			source:  `{"a": [1, foo(2)]}`,
+			wantErr: `test.star:1:11: a: element 1: unsupported expression *syntax.CallExpr, only literals, lists, tuples and dicts are allowed`,
+		},
+		{
+			name:    "identifier",
+			source:  `[x]`,
+			wantErr: `test.star:1:2: element 0: unsupported identifier "x", only True, False and None are allowed`,
+		},
+		{
+			name:    "comprehension",
+			source:  `[x for x in y]`,
+			wantErr: `test.star:1:1: unsupported expression *syntax.Comprehension, only literals, lists, tuples and dicts are allowed`,
+		},
+		{
+			name:    "non-string key",
+			source:  `{"a": {1: 2}}`,
+			wantErr: `test.star:1:8: a: key 0: unsupported dict key int literal, only string keys are allowed`,
+		},
+		{
+			name:    "duplicate key",
+			source:  `{"a": 1, "a": 2}`,
+			wantErr: `test.star:1:10: duplicate key "a"`,
+		},
+		{
+			name:    "negated string",
+			source:  `-"a"`,
+			wantErr: `test.star:1:1: unsupported unary expression, only numeric literals with sign are allowed`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x, err := syntax.ParseExpr("test.star", tt.source, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ToJSON(x)
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				var ue *UnmarshalError
+				if !errors.As(err, &ue) {
+					t.Fatalf("expected *UnmarshalError, got %T", err)
+				}
+				if gotErr := err.Error(); gotErr != tt.wantErr {
+					t.Fatalf("expected error %q, got %q", tt.wantErr, gotErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("want %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFileToJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "assignments",
+			source: "\"\"\"Data file.\"\"\"\n\nVERSION = \"1.2.3\"\nDEPS = [\"a\", \"b\"]\nCONFIG = {\"debug\": False}\n",
+			want:   `{"VERSION":"1.2.3","DEPS":["a","b"],"CONFIG":{"debug":false}}`,
+		},
+		{
+			name:   "empty",
+			source: "",
+			want:   `{}`,
+		},
+		{
+			name:    "not an assignment",
+			source:  "A = 1\nprint(A)\n",
+			wantErr: "test.star:2:1: statement index 1: expected NAME = <literal> assignment, got *syntax.ExprStmt",
+		},
+		{
+			name:    "duplicate assignment",
+			source:  "A = 1\nA = 2\n",
+			wantErr: "test.star:2:1: duplicate assignment to A",
+		},
+		{
+			name:    "non-literal value",
+			source:  "A = 1\nB = [A]\n",
+			wantErr: `test.star:2:6: B: element 0: unsupported identifier "A", only True, False and None are allowed`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := syntax.Parse("test.star", tt.source, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := FileToJSON(f)
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				if gotErr := err.Error(); gotErr != tt.wantErr {
+					t.Fatalf("expected error %q, got %q", tt.wantErr, gotErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("want %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestToJSON_roundTrip(t *testing.T) {
+	const input = `{"b":[1,-2.5,12345678901234567890,null],"a":{"x":true,"y":"z"}}`
+	x, err := FromJSON(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ToJSON(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != input {
+		t.Errorf("want %s, got %s", input, got)
+	}
+}
@@ -0,0 +1,113 @@
+package starlarkgen
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"go.starlark.net/syntax"
+)
+
+// placeholderPrefix starts the message of the placeholders written for
+// the nodes failed to render, see WithKeepGoing.
+const placeholderPrefix = "starlarkgen: error: "
+
+// WithKeepGoing makes WriteStmt, WriteFile and WriteExpr, and the respective
+// Starlark* functions, keep rendering after a node fails: the node is replaced
+// with the placeholder call
+//   fail("starlarkgen: error: <message>")
+// and the errors of all the failed nodes are returned as RenderErrors, along
+// with the output. The dict entries are not replaced themselves, the dict
+// containing them is.
+// The output is buffered until the rendering completes. EditFile stops at
+// the first error regardless.
+func WithKeepGoing(value bool) Option {
+	return func(o *outputOpts) (*outputOpts, error) {
+		c := o.copy()
+		c.keepGoing = value
+		return c, nil
+	}
+}
+
+// RenderErrors are the errors of the nodes failed to render with
+// WithKeepGoing set, in the output order. The message of each error starts with
+// the path of the node, e.g. "Stmts[1].Body[0].X: ...".
+type RenderErrors []*RenderError
+
+func (e RenderErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, re := range e {
+		msgs[i] = re.Error()
+	}
+	return strings.Join(msgs, newline)
+}
+
+// checkpoint is the state of the tracking writer to roll back to when
+// the node fails to render.
+type checkpoint struct {
+	buf, updates, entries int
+	pos                   outputPos
+}
+
+// keepGoing returns the tracking writer if the errors are recovered.
+func keepGoing(out io.StringWriter) *trackingWriter {
+	if w, ok := out.(*trackingWriter); ok && w.buf != nil {
+		return w
+	}
+	return nil
+}
+
+func (w *trackingWriter) checkpoint() checkpoint {
+	cp := checkpoint{buf: w.buf.Len(), updates: len(w.updates), pos: *w.pos}
+	if w.m != nil {
+		cp.entries = len(w.m.Entries)
+	}
+	return cp
+}
+
+// recover replaces the output of the node failed to render with
+// the placeholder, prefixed with the indent and followed by the suffix, and
+// records the error.
+func (w *trackingWriter) recover(cp checkpoint, node syntax.Node, indent, suffix string, errp *error) {
+	if *errp == nil {
+		return
+	}
+	if _, ok := node.(*syntax.DictEntry); ok {
+		return
+	}
+	var re *RenderError
+	if !errors.As(nodeError(node, *errp), &re) {
+		return
+	}
+
+	w.buf.Truncate(cp.buf)
+	*w.pos = cp.pos
+	w.updates = w.updates[:cp.updates]
+	if w.m != nil {
+		w.m.Entries = w.m.Entries[:cp.entries]
+	}
+	// the buffer does not fail
+	_, _ = w.WriteString(indent + "fail(" + strconv.Quote(placeholderPrefix+re.Err.Error()) + ")" + suffix)
+	w.errs = append(w.errs, re)
+	*errp = nil
+}
+
+// keptOutput returns the output along with the errors recovered, or
+// the empty output in case of any other error.
+func keptOutput(sb *strings.Builder, err error) (string, error) {
+	var errs RenderErrors
+	if err != nil && !errors.As(err, &errs) {
+		return "", err
+	}
+	return sb.String(), err
+}
+
+// formatPath returns the path of the node, e.g. "Stmts[1].Body[0].X".
+func formatPath(path []PathElem) string {
+	elems := make([]string, len(path))
+	for i, p := range path {
+		elems[i] = p.String()
+	}
+	return strings.Join(elems, ".")
+}
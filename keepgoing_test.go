@@ -0,0 +1,96 @@
+package starlarkgen
+
+import (
+	"errors"
+	"testing"
+
+	"go.starlark.net/syntax"
+)
+
+func TestWithKeepGoing(t *testing.T) {
+	const src = `x = foo(1, 2)
+y = {"a": 1, "b": 2}
+def f():
+    z = 1
+    return [z, 3]
+`
+	f, err := syntax.Parse("test.star", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// floats are not supported
+	f.Stmts[0].(*syntax.AssignStmt).RHS.(*syntax.CallExpr).Args[1].(*syntax.Literal).Value = 1.5
+	f.Stmts[1].(*syntax.AssignStmt).RHS.(*syntax.DictExpr).List[1].(*syntax.DictEntry).Value.(*syntax.Literal).Value = 2.5
+	def := f.Stmts[2].(*syntax.DefStmt)
+	def.Body[0].(*syntax.AssignStmt).Op = syntax.STAR
+	def.Body[1].(*syntax.ReturnStmt).Result.(*syntax.ListExpr).List[0] = &syntax.LambdaExpr{}
+
+	got, err := StarlarkFile(f, WithKeepGoing(true))
+	const want = `x = foo(1, fail("starlarkgen: error: unsupported literal value type float64, expected string, int, int64, uint, uint64 or *big.Int"))
+
+y = {"a": 1, "b": fail("starlarkgen: error: unsupported literal value type float64, expected string, int, int64, uint, uint64 or *big.Int")}
+
+def f():
+    fail("starlarkgen: error: rendering assign statement: unsupported Op token *, expected one of: =, +=, -=, *=, /=, //=, %=, &=, |=, ^=, <<=, >>=")
+    return [fail("starlarkgen: error: type *syntax.LambdaExpr is not supported"), 3]
+`
+	if got != want {
+		t.Errorf("want\n%s\ngot\n%s", want, got)
+	}
+	// the placeholders keep the output valid
+	if _, err := syntax.Parse("out.star", got, 0); err != nil {
+		t.Errorf("parsing the output: %v", err)
+	}
+
+	var errs RenderErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected RenderErrors, got %v", err)
+	}
+	wantErrs := []struct {
+		path, pos string
+	}{
+		{path: "Stmts[0].RHS.Args[1]", pos: "test.star:1:12"},
+		{path: "Stmts[1].RHS.List[1].Value", pos: "test.star:2:19"},
+		{path: "Stmts[2].Body[0]", pos: "test.star:4:5"},
+		{path: "Stmts[2].Body[1].Result.List[0]", pos: "<invalid>"},
+	}
+	if len(errs) != len(wantErrs) {
+		t.Fatalf("expected %d errors, got %d: %v", len(wantErrs), len(errs), err)
+	}
+	for i, w := range wantErrs {
+		if got := formatPath(errs[i].Path); got != w.path {
+			t.Errorf("error %d: expected path %s, got %s", i, w.path, got)
+		}
+		if got := errs[i].Pos.String(); got != w.pos {
+			t.Errorf("error %d: expected position %s, got %s", i, w.pos, got)
+		}
+	}
+	const wantFirst = "Stmts[0].RHS.Args[1]: unsupported literal value type float64, expected string, int, int64, uint, uint64 or *big.Int"
+	if got := errs[0].Error(); got != wantFirst {
+		t.Errorf("expected error %q, got %q", wantFirst, got)
+	}
+}
+
+func TestWithKeepGoing_sourceMap(t *testing.T) {
+	var m SourceMap
+	x := &syntax.ListExpr{List: []syntax.Expr{
+		&syntax.CallExpr{Fn: &syntax.Ident{Name: "foo"}, Args: []syntax.Expr{&syntax.UnaryExpr{Op: syntax.MINUS}}},
+		&syntax.Ident{Name: "bar"},
+	}}
+	got, err := StarlarkExpr(x, WithKeepGoing(true), WithSourceMap(&m))
+	const want = `[foo(fail("starlarkgen: error: rendering unary expression, nil X value for \"-\" token")), bar]`
+	if got != want {
+		t.Errorf("want\n%s\ngot\n%s", want, got)
+	}
+	if err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+	// the entries follow the placeholder
+	e := m.Lookup(1, len(want)-3)
+	if e == nil || e.Node != x.List[1] {
+		t.Fatalf("expected the bar identifier, got %+v", e)
+	}
+	if e := m.Lookup(1, 6); e == nil || e.Node != x.List[0].(*syntax.CallExpr).Args[0] {
+		t.Fatalf("expected the failed node, got %+v", e)
+	}
+}
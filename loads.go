@@ -0,0 +1,249 @@
+package starlarkgen
+
+import (
+	"fmt"
+	"sort"
+
+	"go.starlark.net/resolve"
+	"go.starlark.net/syntax"
+)
+
+// loadModule returns the module of the load statement, or false if the module
+// is not a string literal.
+func loadModule(input *syntax.LoadStmt) (string, bool) {
+	if input.Module == nil {
+		return "", false
+	}
+	s, ok := input.Module.Value.(string)
+	return s, ok
+}
+
+// loadsStart returns the index of the first statement after the module
+// docstring, if any.
+func loadsStart(f *syntax.File) int {
+	if len(f.Stmts) == 0 {
+		return 0
+	}
+	if es, ok := f.Stmts[0].(*syntax.ExprStmt); ok {
+		if lt, ok := es.X.(*syntax.Literal); ok && lt.Token == syntax.STRING {
+			return 1
+		}
+	}
+	return 0
+}
+
+// AddLoad makes sure the symbol is loaded from the module: the symbol is
+// appended to the first load of the module, or the new load statement is
+// created after the last load, or at the top of the file, after the
+// docstring, if there are no loads.
+// The error is returned if the symbol is already loaded from another module,
+// or the name is bound to another symbol of the module.
+func AddLoad(f *syntax.File, module, symbol string) error {
+	var (
+		target *syntax.LoadStmt
+		last   = -1
+	)
+	for i, st := range f.Stmts {
+		ld, ok := st.(*syntax.LoadStmt)
+		if !ok {
+			continue
+		}
+		last = i
+		m, _ := loadModule(ld)
+		for j, to := range ld.To {
+			if to.Name != symbol {
+				continue
+			}
+			if m != module {
+				return fmt.Errorf("adding load of %s from %q: already loaded from %q", symbol, module, m)
+			}
+			if from := ld.From[j].Name; from != symbol {
+				return fmt.Errorf("adding load of %s from %q: already bound to %s", symbol, module, from)
+			}
+			return nil
+		}
+		if m == module && target == nil {
+			target = ld
+		}
+	}
+
+	if target != nil {
+		target.From = append(target.From, &syntax.Ident{Name: symbol})
+		target.To = append(target.To, &syntax.Ident{Name: symbol})
+		return nil
+	}
+
+	ld := &syntax.LoadStmt{
+		Module: &syntax.Literal{Token: syntax.STRING, Value: module},
+		From:   []*syntax.Ident{{Name: symbol}},
+		To:     []*syntax.Ident{{Name: symbol}},
+	}
+	pos := last + 1
+	if last < 0 {
+		pos = loadsStart(f)
+	}
+	f.Stmts = append(f.Stmts, nil)
+	copy(f.Stmts[pos+1:], f.Stmts[pos:])
+	f.Stmts[pos] = ld
+	return nil
+}
+
+// MergeLoads merges the loads of the same module into the first one,
+// dropping the symbols loaded more than once under the same name.
+func MergeLoads(f *syntax.File) {
+	first := make(map[string]*syntax.LoadStmt)
+	stmts := f.Stmts[:0]
+	for _, st := range f.Stmts {
+		ld, ok := st.(*syntax.LoadStmt)
+		if !ok {
+			stmts = append(stmts, st)
+			continue
+		}
+		m, ok := loadModule(ld)
+		if !ok {
+			stmts = append(stmts, st)
+			continue
+		}
+		from, to := ld.From, ld.To
+		target, seen := first[m]
+		if !seen {
+			// the first load is rebuilt to drop its own duplicates
+			target = ld
+			target.From, target.To = nil, nil
+			first[m] = ld
+			stmts = append(stmts, st)
+		}
+		for i, id := range to {
+			if !loadsName(target, id.Name) {
+				target.From = append(target.From, from[i])
+				target.To = append(target.To, id)
+			}
+		}
+	}
+	f.Stmts = stmts
+}
+
+func loadsName(ld *syntax.LoadStmt, name string) bool {
+	for _, to := range ld.To {
+		if to.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// PruneLoads removes the loaded symbols which are never referenced in
+// the file, and the load statements left without symbols. The references are
+// found with resolve.File, so the identifiers of the file are annotated with
+// the bindings, as resolve.File does. The names not resolved are considered
+// referenced, the resolution errors are ignored.
+func PruneLoads(f *syntax.File) {
+	all := func(string) bool { return true }
+	// the errors, e.g. the top-level reassignment, don't prevent
+	// the resolution of the other identifiers
+	_ = resolve.File(f, all, all)
+
+	// the local and the free variable bindings point to the binding
+	// identifier of the load
+	loaded := make(map[*syntax.Ident]bool)
+	for _, st := range f.Stmts {
+		if ld, ok := st.(*syntax.LoadStmt); ok {
+			for _, to := range ld.To {
+				loaded[to] = false
+			}
+		}
+	}
+
+	// names of the identifiers without the binding, which are kept
+	// conservatively
+	unresolved := make(map[string]bool)
+	for _, st := range f.Stmts {
+		if _, ok := st.(*syntax.LoadStmt); ok {
+			continue
+		}
+		syntax.Walk(st, func(n syntax.Node) bool {
+			id, ok := n.(*syntax.Ident)
+			if !ok {
+				return true
+			}
+			b, ok := id.Binding.(*resolve.Binding)
+			if !ok {
+				unresolved[id.Name] = true
+				return true
+			}
+			if _, isLoad := loaded[b.First]; isLoad {
+				loaded[b.First] = true
+			}
+			return true
+		})
+	}
+
+	stmts := f.Stmts[:0]
+	for _, st := range f.Stmts {
+		ld, ok := st.(*syntax.LoadStmt)
+		if !ok {
+			stmts = append(stmts, st)
+			continue
+		}
+		from, to := ld.From[:0], ld.To[:0]
+		for i, id := range ld.To {
+			if loaded[id] || unresolved[id.Name] {
+				from = append(from, ld.From[i])
+				to = append(to, id)
+			}
+		}
+		ld.From, ld.To = from, to
+		if len(ld.To) > 0 {
+			stmts = append(stmts, st)
+		}
+	}
+	f.Stmts = stmts
+}
+
+// HoistLoads moves the load statements to the top of the file, after
+// the docstring, if any. The loads are sorted by the module with LabelLess,
+// and the symbols are sorted by the local name.
+func HoistLoads(f *syntax.File) {
+	var loads, rest []syntax.Stmt
+	start := loadsStart(f)
+	for _, st := range f.Stmts[start:] {
+		if ld, ok := st.(*syntax.LoadStmt); ok {
+			sortLoadSymbols(ld)
+			loads = append(loads, ld)
+			continue
+		}
+		rest = append(rest, st)
+	}
+	sort.SliceStable(loads, func(i, j int) bool {
+		a, _ := loadModule(loads[i].(*syntax.LoadStmt))
+		b, _ := loadModule(loads[j].(*syntax.LoadStmt))
+		return LabelLess(a, b)
+	})
+
+	stmts := append(f.Stmts[:start:start], loads...)
+	f.Stmts = append(stmts, rest...)
+}
+
+func sortLoadSymbols(ld *syntax.LoadStmt) {
+	idx := make([]int, len(ld.To))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return ld.To[idx[i]].Name < ld.To[idx[j]].Name })
+	from := make([]*syntax.Ident, len(idx))
+	to := make([]*syntax.Ident, len(idx))
+	for i, n := range idx {
+		from[i], to[i] = ld.From[n], ld.To[n]
+	}
+	ld.From, ld.To = from, to
+}
+
+// FixLoads merges the duplicate loads, removes the unused symbols and hoists
+// the loads to the top of the file, see MergeLoads, PruneLoads and
+// HoistLoads. The symbols added with AddLoad must be referenced before
+// FixLoads is called, otherwise they are removed.
+func FixLoads(f *syntax.File) {
+	MergeLoads(f)
+	PruneLoads(f)
+	HoistLoads(f)
+}
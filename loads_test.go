@@ -0,0 +1,149 @@
+package starlarkgen
+
+import (
+	"testing"
+
+	"go.starlark.net/syntax"
+)
+
+func TestLoads(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		fix     func(f *syntax.File) error
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "add to existing load",
+			source: "load(\"@io_bazel_rules_go//go:def.bzl\", \"go_test\")\n\ngo_test(name = \"a\")\n",
+			fix: func(f *syntax.File) error {
+				return AddLoad(f, "@io_bazel_rules_go//go:def.bzl", "go_library")
+			},
+			want: "load(\"@io_bazel_rules_go//go:def.bzl\", \"go_test\", \"go_library\")\n\ngo_test(name=\"a\")\n",
+		},
+		{
+			name:   "add new load after docstring",
+			source: "\"\"\"Doc.\"\"\"\n\nx = 1\n",
+			fix: func(f *syntax.File) error {
+				return AddLoad(f, "//a:b.bzl", "c")
+			},
+			want: "\"\"\"Doc.\"\"\"\n\nload(\"//a:b.bzl\", \"c\")\n\nx = 1\n",
+		},
+		{
+			name:   "add new load after the last one",
+			source: "load(\":a.bzl\", \"a\")\nx = 1\nload(\":b.bzl\", \"b\")\ny = 2\n",
+			fix: func(f *syntax.File) error {
+				return AddLoad(f, ":c.bzl", "c")
+			},
+			want: "load(\":a.bzl\", \"a\")\n\nx = 1\n\nload(\":b.bzl\", \"b\")\n\nload(\":c.bzl\", \"c\")\n\ny = 2\n",
+		},
+		{
+			name:   "already loaded",
+			source: "load(\":a.bzl\", \"b\", c = \"d\")\n",
+			fix: func(f *syntax.File) error {
+				return AddLoad(f, ":a.bzl", "b")
+			},
+			want: "load(\":a.bzl\", \"b\", c=\"d\")\n",
+		},
+		{
+			name:   "loaded under the alias",
+			source: "load(\":a.bzl\", b = \"c\")\n",
+			fix: func(f *syntax.File) error {
+				return AddLoad(f, ":a.bzl", "b")
+			},
+			wantErr: `adding load of b from ":a.bzl": already bound to c`,
+		},
+		{
+			name:   "loaded from another module",
+			source: "load(\":a.bzl\", \"b\")\n",
+			fix: func(f *syntax.File) error {
+				return AddLoad(f, ":c.bzl", "b")
+			},
+			wantErr: `adding load of b from ":c.bzl": already loaded from ":a.bzl"`,
+		},
+		{
+			name:   "merge",
+			source: "load(\":a.bzl\", \"x\", \"x\")\nload(\":b.bzl\", \"y\")\nload(\":a.bzl\", \"z\", \"x\")\n",
+			fix: func(f *syntax.File) error {
+				MergeLoads(f)
+				return nil
+			},
+			want: "load(\":a.bzl\", \"x\", \"z\")\n\nload(\":b.bzl\", \"y\")\n",
+		},
+		{
+			name: "prune",
+			source: `load(":a.bzl", "used", "unused", alias = "aliased")
+load(":b.bzl", "only_unused")
+load(":c.bzl", "in_def", "shadowed")
+
+def f(shadowed):
+    return in_def(shadowed)
+
+used(alias)
+`,
+			fix: func(f *syntax.File) error {
+				PruneLoads(f)
+				return nil
+			},
+			want: `load(":a.bzl", "used", alias="aliased")
+
+load(":c.bzl", "in_def")
+
+def f(shadowed):
+    return in_def(shadowed)
+
+used(alias)
+`,
+		},
+		{
+			name:   "hoist",
+			source: "\"\"\"Doc.\"\"\"\nx = 1\nload(\"@r//:a.bzl\", \"a\")\nload(\"//:b.bzl\", \"d\", \"b\")\nload(\":c.bzl\", \"c\")\n",
+			fix: func(f *syntax.File) error {
+				HoistLoads(f)
+				return nil
+			},
+			want: "\"\"\"Doc.\"\"\"\n\nload(\":c.bzl\", \"c\")\n\nload(\"//:b.bzl\", \"b\", \"d\")\n\nload(\"@r//:a.bzl\", \"a\")\n\nx = 1\n",
+		},
+		{
+			name: "fix",
+			source: `go_library(name = "a")
+load("@io_bazel_rules_go//go:def.bzl", "go_test")
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+`,
+			fix: func(f *syntax.File) error {
+				FixLoads(f)
+				return nil
+			},
+			want: "load(\"@io_bazel_rules_go//go:def.bzl\", \"go_library\")\n\ngo_library(name=\"a\")\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := syntax.Parse("test.star", tt.source, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = tt.fix(f)
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				if gotErr := err.Error(); gotErr != tt.wantErr {
+					t.Fatalf("expected error %q, got %q", tt.wantErr, gotErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			got, err := StarlarkFile(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("want:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}
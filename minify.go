@@ -0,0 +1,102 @@
+package starlarkgen
+
+import (
+	"go.starlark.net/syntax"
+)
+
+// minifyIndent is the indentation sequence of the minified output.
+const minifyIndent = " "
+
+// WithMinify sets the behavior of how the whitespace is rendered. When set to
+// true, the output is the smallest one parsed back to the same tree:
+//   - no spaces around the operators, after the commas and the colons,
+//     except the keywords, e.g. "in" and "and"
+//   - the single space indentation, regardless of WithIndent
+//   - the sequences rendered on a single line without the last comma, see
+//     WithCallOption, WithDictOption, WithListOption and WithTupleOption
+//   - no blank lines between the statements
+//   - the consecutive simple statements joined with ";", e.g.
+//       x=1;y=[1,2];foo(x,y)
+//   - the docstrings rendered as the plain string literals
+//   - the bodies of the simple statements put on the header line, see
+//     WithCompactSuites
+// The default value is false. See WithDropDocstrings to omit the docstrings.
+func WithMinify(value bool) Option {
+	return func(o *outputOpts) (*outputOpts, error) {
+		c := o.copy()
+		c.minify = value
+		return c, nil
+	}
+}
+
+// WithDropDocstrings omits the docstrings of the file and the def
+// statements, i.e. the string literals being their first statements.
+// The def statement having no other statements gets the pass statement.
+func WithDropDocstrings(value bool) Option {
+	return func(o *outputOpts) (*outputOpts, error) {
+		c := o.copy()
+		c.dropDocstrings = value
+		return c, nil
+	}
+}
+
+// simpleStmt reports whether the statement can be joined with the others
+// on the same line.
+func simpleStmt(st syntax.Stmt) bool {
+	switch st.(type) {
+	case *syntax.AssignStmt, *syntax.BranchStmt, *syntax.ExprStmt, *syntax.LoadStmt, *syntax.ReturnStmt:
+		return true
+	}
+	return false
+}
+
+// joinStmt returns the options to render the statement of the list, which is
+// joined with the previous and the next simple statements when minified or
+// put on the header line, see compactSuite.
+func (o *outputOpts) joinStmt(input []syntax.Stmt, i int) *outputOpts {
+	if !o.minify && !o.inline || !simpleStmt(input[i]) {
+		return o
+	}
+	afterPrev := i > 0 && simpleStmt(input[i-1])
+	beforeNext := i < len(input)-1 && simpleStmt(input[i+1])
+	if !afterPrev && !beforeNext {
+		return o
+	}
+	c := o.copy()
+	if afterPrev {
+		// the statement continues the line
+		c.depth = 0
+	}
+	c.semicolon = beforeNext
+	return c
+}
+
+// stmtEnd returns the token ending the simple statement.
+func (o *outputOpts) stmtEnd() string {
+	switch {
+	case o.semicolon && o.minify:
+		return syntax.SEMI.String()
+	case o.semicolon:
+		return syntax.SEMI.String() + space
+	}
+	return newline
+}
+
+// keywordOp reports whether the binary operator is the keyword, which has to
+// be separated from the operands.
+func keywordOp(op syntax.Token) bool {
+	switch op {
+	case syntax.AND, syntax.OR, syntax.IN, syntax.NOT_IN:
+		return true
+	}
+	return false
+}
+
+// dropDocstring returns the statements without the leading docstring, if
+// the docstrings are dropped.
+func (o *outputOpts) dropDocstring(input []syntax.Stmt) []syntax.Stmt {
+	if o.dropDocstrings && len(input) > 0 && docstringStmt(input[0]) {
+		return input[1:]
+	}
+	return input
+}
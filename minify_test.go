@@ -0,0 +1,209 @@
+package starlarkgen
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"go.starlark.net/syntax"
+)
+
+const minifySource = `"""Module docstring."""
+
+load(":a.bzl", "b", c = "d")
+
+def f(a, b = 1, *args, **kwargs):
+    """Function docstring.
+
+    More details.
+    """
+    x = [a, b]
+    if a and not b:
+        return {"k": (a,), "l": -b}
+    for i in x:
+        x += [i * 2 - -1]
+        if i in x:
+            break
+    return x[1:2], f(a, b = 2)
+
+def g():
+    """Docstring only."""
+
+y = f(1)
+z = y if y not in [1, 2] else None
+`
+
+func TestWithMinify(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		want    string
+	}{
+		{
+			name:    "minify",
+			options: []Option{WithMinify(true)},
+			want: `"Module docstring.";load(":a.bzl","b",c="d")
+def f(a,b=1,*args,**kwargs):
+ "Function docstring.\n\n    More details.\n    ";x=[a,b]
+ if a and not b:return {"k":(a,),"l":-b}
+ for i in x:
+  x+=[i*2--1]
+  if i in x:break
+ return x[1:2],f(a,b=2)
+def g():"Docstring only."
+y=f(1);z=y if y not in [1,2] else None
+`,
+		},
+		{
+			name:    "minify, drop docstrings",
+			options: []Option{WithMinify(true), WithDropDocstrings(true)},
+			want: `load(":a.bzl","b",c="d")
+def f(a,b=1,*args,**kwargs):
+ x=[a,b]
+ if a and not b:return {"k":(a,),"l":-b}
+ for i in x:
+  x+=[i*2--1]
+  if i in x:break
+ return x[1:2],f(a,b=2)
+def g():pass
+y=f(1);z=y if y not in [1,2] else None
+`,
+		},
+		{
+			name:    "minify overrides the layout options",
+			options: []Option{WithSpaceEqBinary(true), WithIndent("\t"), WithListOption(ListOptionMultilineComma), WithStyle(StyleBuildifier), WithMinify(true)},
+			want: `"""Module docstring.""";load(":a.bzl","b",c="d")
+def f(a,b=1,*args,**kwargs):
+ """Function docstring.
+
+    More details.
+    """;x=[a,b]
+ if a and not b:return {"k":(a,),"l":-b}
+ for i in x:
+  x+=[i*2--1]
+  if i in x:break
+ return x[1:2],f(a,b=2)
+def g():"""Docstring only."""
+y=f(1);z=y if y not in [1,2] else None
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := syntax.Parse("test.star", minifySource, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := StarlarkFile(f, tt.options...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("want\n%s\ngot\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+// nodeValues returns the type and the fields of the nodes of the tree other
+// than the positions, the raw tokens and the file path, in the walk order.
+func nodeValues(n syntax.Node) []string {
+	var res []string
+	syntax.Walk(n, func(n syntax.Node) bool {
+		if n == nil {
+			return true
+		}
+		v := reflect.ValueOf(n).Elem()
+		s := fmt.Sprintf("%T", n)
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath != "" || f.Name == "Raw" || f.Name == "Path" {
+				continue
+			}
+			switch x := v.Field(i).Interface().(type) {
+			case syntax.Token, string, bool:
+				s += fmt.Sprintf(" %s=%v", f.Name, x)
+			}
+		}
+		if lt, ok := n.(*syntax.Literal); ok {
+			s += fmt.Sprintf(" Value=%v", lt.Value)
+		}
+		res = append(res, s)
+		return true
+	})
+	return res
+}
+
+// TestWithMinify_equivalent checks the minified output is parsed back to
+// the same tree.
+func TestWithMinify_equivalent(t *testing.T) {
+	sources := map[string]string{"source": minifySource}
+	for sf := range testSources {
+		src, err := ioutil.ReadFile(sf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources[sf] = string(src)
+	}
+	for name, src := range sources {
+		t.Run(name, func(t *testing.T) {
+			f, err := syntax.Parse(name, src, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			min, err := StarlarkFile(f, WithMinify(true))
+			if err != nil {
+				t.Fatalf("minify: %v", err)
+			}
+			minFile, err := syntax.Parse("min.star", min, 0)
+			if err != nil {
+				t.Fatalf("parsing the minified output: %v\n%s", err, min)
+			}
+			if want, got := nodeValues(f), nodeValues(minFile); !reflect.DeepEqual(want, got) {
+				t.Errorf("want\n%v\ngot\n%v", want, got)
+			}
+			if len(min) >= len(src) {
+				t.Errorf("expected the output shorter than %d bytes, got %d", len(src), len(min))
+			}
+		})
+	}
+}
+
+func TestWithMinify_stmt(t *testing.T) {
+	tests := []struct {
+		name  string
+		input syntax.Stmt
+		want  string
+	}{
+		{
+			name:  "single element tuple",
+			input: &syntax.AssignStmt{LHS: &syntax.Ident{Name: "x"}, Op: syntax.EQ, RHS: &syntax.TupleExpr{List: []syntax.Expr{&syntax.Literal{Value: 1}}}},
+			want:  "  x=1,\n",
+		},
+		{
+			name: "joined statements keep the depth of the first one",
+			input: &syntax.ForStmt{
+				Vars: &syntax.Ident{Name: "x"},
+				X:    &syntax.Ident{Name: "y"},
+				Body: []syntax.Stmt{
+					&syntax.ExprStmt{X: &syntax.CallExpr{Fn: &syntax.Ident{Name: "f"}, Args: []syntax.Expr{&syntax.Ident{Name: "x"}}}},
+					&syntax.AssignStmt{LHS: &syntax.Ident{Name: "z"}, Op: syntax.EQ, RHS: &syntax.Ident{Name: "x"}},
+					&syntax.IfStmt{Cond: &syntax.Ident{Name: "z"}, True: []syntax.Stmt{&syntax.BranchStmt{Token: syntax.BREAK}}},
+				},
+			},
+			want: "  for x in y:\n   f(x);z=x\n   if z:break\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := StarlarkStmt(tt.input, WithMinify(true), WithDepth(2))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("want\n%q\ngot\n%q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package starlarkgen
+
+import (
+	"fmt"
+
+	"go.starlark.net/resolve"
+	"go.starlark.net/syntax"
+)
+
+// Rename renames the global, defined or loaded in the file, and all the
+// references to it, leaving alone the locals, the parameters, the keyword
+// argument names and the attributes with the same name. For the loaded
+// symbol the local name is changed, e.g.
+//   load(":a.bzl", "foo")
+// is renamed to
+//   load(":a.bzl", bar = "foo")
+//
+// The file is resolved with resolve.File, which annotates the identifiers
+// with the bindings, the resolution errors are returned as is. The rename is
+// refused if the new name is not a valid identifier, or if it is already
+// used in the file: as a global, it would collide, as a local or a built-in,
+// it would be shadowed or would shadow the built-in.
+// No names are changed in case of an error.
+func Rename(file *syntax.File, old, new string) error {
+	if file == nil {
+		return fmt.Errorf("rename %s: nil file", old)
+	}
+	if !IsIdent(new) {
+		return fmt.Errorf("rename %s: invalid identifier %q", old, new)
+	}
+	if old == new {
+		return nil
+	}
+
+	all := func(string) bool { return true }
+	if err := resolve.File(file, all, all); err != nil {
+		return fmt.Errorf("rename %s: %w", old, err)
+	}
+
+	// the bindings of the module point to the first binding identifier,
+	// as do the free variables referring to them
+	targets := make(map[*syntax.Ident]bool)
+	loaded := make(map[*syntax.Ident]bool)
+	for _, st := range file.Stmts {
+		if ld, ok := st.(*syntax.LoadStmt); ok {
+			for _, to := range ld.To {
+				loaded[to] = true
+			}
+		}
+	}
+	if m, ok := file.Module.(*resolve.Module); ok {
+		for _, b := range m.Globals {
+			if b.First != nil && b.First.Name == old {
+				targets[b.First] = true
+			}
+		}
+		// the module locals are the loads and the top-level
+		// comprehension variables
+		for _, b := range m.Locals {
+			if b.First != nil && b.First.Name == old && loaded[b.First] {
+				targets[b.First] = true
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("rename %s: not a global defined or loaded in the file", old)
+	}
+
+	var (
+		refs []*syntax.Ident
+		err  error
+	)
+	syntax.Walk(file, func(n syntax.Node) bool {
+		id, ok := n.(*syntax.Ident)
+		if !ok || err != nil {
+			return err == nil
+		}
+		b, ok := id.Binding.(*resolve.Binding)
+		if !ok {
+			return true
+		}
+		if b.First != nil && targets[b.First] {
+			refs = append(refs, id)
+			return true
+		}
+		if id.Name != new {
+			return true
+		}
+		switch b.Scope {
+		case resolve.Global:
+			err = fmt.Errorf("rename %s: %s: collides with the global %s", old, id.NamePos, new)
+		case resolve.Predeclared, resolve.Universal:
+			err = fmt.Errorf("rename %s: %s: would shadow the built-in %s", old, id.NamePos, new)
+		default:
+			if loaded[b.First] {
+				err = fmt.Errorf("rename %s: %s: collides with the loaded %s", old, id.NamePos, new)
+			} else {
+				err = fmt.Errorf("rename %s: %s: would be shadowed by the local %s", old, id.NamePos, new)
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return err
+	}
+
+	// the parser shares the identifier between From and To of the loads
+	// without the alias, the loaded name must be kept
+	for _, st := range file.Stmts {
+		if ld, ok := st.(*syntax.LoadStmt); ok {
+			for i, to := range ld.To {
+				if targets[to] && ld.From[i] == to {
+					ld.From[i] = &syntax.Ident{NamePos: to.NamePos, Name: to.Name}
+				}
+			}
+		}
+	}
+	for _, id := range refs {
+		id.Name = new
+	}
+	return nil
+}
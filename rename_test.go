@@ -0,0 +1,145 @@
+package starlarkgen
+
+import (
+	"testing"
+
+	"go.starlark.net/syntax"
+)
+
+func TestRename(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		old, new string
+		want     string
+		wantErr  string
+	}{
+		{
+			name: "def and references",
+			source: `def foo(foo_arg):
+    return foo_arg
+
+def bar(x):
+    foo = 1
+    return foo + x
+
+def baz():
+    return foo(1)
+
+X = foo(foo = 2).foo
+`,
+			old: "foo",
+			new: "new_foo",
+			want: `def new_foo(foo_arg):
+    return foo_arg
+
+def bar(x):
+    foo = 1
+    return foo + x
+
+def baz():
+    return new_foo(1)
+
+X = new_foo(foo=2).foo
+`,
+		},
+		{
+			name:   "load alias",
+			source: "load(\":a.bzl\", \"foo\", baz = \"bar\")\n\nfoo(baz)\n",
+			old:    "foo",
+			new:    "qux",
+			want:   "load(\":a.bzl\", qux=\"foo\", baz=\"bar\")\n\nqux(baz)\n",
+		},
+		{
+			name:   "variable",
+			source: "X = 1\n\nY = [X for _ in range(X)]\n\ndef f():\n    return X\n",
+			old:    "X",
+			new:    "Z",
+			want:   "Z = 1\n\nY = [Z for _ in range(Z)]\n\ndef f():\n    return Z\n",
+		},
+		{
+			name:    "not defined",
+			source:  "X = len([])\n",
+			old:     "len",
+			new:     "size",
+			wantErr: "rename len: not a global defined or loaded in the file",
+		},
+		{
+			name:    "local only",
+			source:  "def f(x):\n    return x\n",
+			old:     "x",
+			new:     "y",
+			wantErr: "rename x: not a global defined or loaded in the file",
+		},
+		{
+			name:    "collision with global",
+			source:  "X = 1\nY = 2\n",
+			old:     "X",
+			new:     "Y",
+			wantErr: "rename X: test.star:2:1: collides with the global Y",
+		},
+		{
+			name:    "collision with load",
+			source:  "load(\":a.bzl\", \"Y\")\nX = 1\n",
+			old:     "X",
+			new:     "Y",
+			wantErr: "rename X: test.star:1:17: collides with the loaded Y",
+		},
+		{
+			name:    "shadowed by local",
+			source:  "X = 1\n\ndef f(Y):\n    return X + Y\n",
+			old:     "X",
+			new:     "Y",
+			wantErr: "rename X: test.star:3:7: would be shadowed by the local Y",
+		},
+		{
+			name:    "shadows built-in",
+			source:  "X = 1\nY = len([X])\n",
+			old:     "X",
+			new:     "len",
+			wantErr: "rename X: test.star:2:5: would shadow the built-in len",
+		},
+		{
+			name:    "invalid identifier",
+			source:  "X = 1\n",
+			old:     "X",
+			new:     "def",
+			wantErr: `rename X: invalid identifier "def"`,
+		},
+		{
+			name:    "resolution error",
+			source:  "X = 1\nX = 2\n",
+			old:     "X",
+			new:     "Y",
+			wantErr: "rename X: test.star:2:1: cannot reassign global X declared at test.star:1:1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := syntax.Parse("test.star", tt.source, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = Rename(f, tt.old, tt.new)
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				if gotErr := err.Error(); gotErr != tt.wantErr {
+					t.Fatalf("expected error %q, got %q", tt.wantErr, gotErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			got, err := StarlarkFile(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("want:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}
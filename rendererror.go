@@ -0,0 +1,196 @@
+package starlarkgen
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.starlark.net/syntax"
+)
+
+// RenderError describes the node which failed to render, it is returned by
+// the Write* and Starlark* functions and EditFile, and can be obtained with
+// errors.As.
+type RenderError struct {
+	// Node is the innermost node which failed to render.
+	Node syntax.Node
+	// Pos is the original position of the node, if known.
+	Pos syntax.Position
+	// Path is the location of the node in the rendered input, from
+	// the child of the input down to the node itself, empty if the input
+	// itself failed to render.
+	Path []PathElem
+	// Err is the cause of the failure.
+	Err error
+
+	// the message with the context of the rendering, and the source to quote
+	msg string
+	src string
+}
+
+// PathElem is the step of the RenderError path: the node, and the field of
+// the parent node holding it, e.g. the node at Body[2] of the def statement
+// has Field "Body" and Index 2.
+type PathElem struct {
+	Node  syntax.Node
+	Field string
+	// Index is the index in the field sequence, or -1 if the field is not
+	// a sequence.
+	Index int
+}
+
+func (p PathElem) String() string {
+	if p.Index < 0 {
+		return p.Field
+	}
+	return fmt.Sprintf("%s[%d]", p.Field, p.Index)
+}
+
+// Error returns the message describing the failure along with the context of
+// the rendering, e.g. "statement index 3: rendering call expression: element
+// 2: ...", followed by the node position and the source line with the node
+// marked by the caret, if the source is known, see WithSource.
+func (e *RenderError) Error() string {
+	msg := e.msg
+	if msg == "" {
+		msg = e.Err.Error()
+	}
+	if snippet := sourceSnippet(e.src, e.Pos); snippet != "" {
+		return msg + newline + e.Pos.String() + ":" + newline + snippet
+	}
+	return msg
+}
+
+func (e *RenderError) Unwrap() error {
+	return e.Err
+}
+
+// WithSource sets the source the rendered input was parsed from, the errors
+// quote the line of the node which failed to render.
+func WithSource(src []byte) Option {
+	return func(o *outputOpts) (*outputOpts, error) {
+		c := o.copy()
+		c.source = string(src)
+		return c, nil
+	}
+}
+
+// nodeError makes the error of rendering the node a *RenderError, unless
+// the error of the nested node was made one already.
+func nodeError(node syntax.Node, err error) error {
+	var re *RenderError
+	if errors.As(err, &re) {
+		return err
+	}
+	return &RenderError{Node: node, Err: err}
+}
+
+// renderError completes the *RenderError with the message of the error and
+// the location of the node in the input rendered.
+func renderError(input syntax.Node, err error, src string) error {
+	// the recovered errors have no context but the path
+	if errs, ok := err.(RenderErrors); ok {
+		for _, re := range errs {
+			re.complete(input, src)
+			if len(re.Path) > 0 {
+				re.msg = formatPath(re.Path) + ": " + re.Err.Error()
+			}
+		}
+		return errs
+	}
+	var re *RenderError
+	if !errors.As(err, &re) {
+		return err
+	}
+	re.msg = err.Error()
+	re.complete(input, src)
+	return re
+}
+
+func (e *RenderError) complete(input syntax.Node, src string) {
+	e.Pos = nodeStart(e.Node)
+	e.src = src
+	e.Path, _ = nodePath(input, e.Node)
+}
+
+// nodePath returns the path from the root down to the node, or false if
+// the node is not found.
+func nodePath(root, node syntax.Node) ([]PathElem, bool) {
+	if node == nil || reflect.ValueOf(root).Kind() != reflect.Ptr || reflect.ValueOf(root).IsNil() {
+		return nil, false
+	}
+	if root == node {
+		return nil, true
+	}
+	v := reflect.ValueOf(root).Elem()
+	if v.Kind() != reflect.Struct {
+		return nil, false
+	}
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Slice {
+			for j := 0; j < fv.Len(); j++ {
+				if path, ok := childPath(fv.Index(j), f.Name, j, node); ok {
+					return path, true
+				}
+			}
+			continue
+		}
+		if path, ok := childPath(fv, f.Name, -1, node); ok {
+			return path, true
+		}
+	}
+	return nil, false
+}
+
+func childPath(v reflect.Value, field string, index int, node syntax.Node) ([]PathElem, bool) {
+	if !v.CanInterface() {
+		return nil, false
+	}
+	child, ok := v.Interface().(syntax.Node)
+	if !ok {
+		return nil, false
+	}
+	path, ok := nodePath(child, node)
+	if !ok {
+		return nil, false
+	}
+	return append([]PathElem{{Node: child, Field: field, Index: index}}, path...), true
+}
+
+// sourceSnippet returns the source line of the position with the caret
+// under the column, or the empty string if the line is not in the source.
+func sourceSnippet(src string, pos syntax.Position) string {
+	if src == "" || !pos.IsValid() {
+		return ""
+	}
+	lines := strings.Split(src, newline)
+	if int(pos.Line) > len(lines) {
+		return ""
+	}
+	line := strings.TrimRight(lines[pos.Line-1], "\r")
+
+	// keep the tabs to align the caret with the column
+	var sb strings.Builder
+	sb.WriteString(line)
+	sb.WriteString(newline)
+	col := int32(1)
+	for _, r := range line {
+		if col >= pos.Col {
+			break
+		}
+		if r == '\t' {
+			sb.WriteByte('\t')
+		} else {
+			sb.WriteByte(' ')
+		}
+		col++
+	}
+	sb.WriteByte('^')
+	return sb.String()
+}
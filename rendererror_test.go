@@ -0,0 +1,102 @@
+package starlarkgen
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go.starlark.net/syntax"
+)
+
+func TestRenderError(t *testing.T) {
+	const src = "x = 1\n\ndef f():\n\tpass\n\tfoo(1, 2, 3)\n"
+	parse := func() (*syntax.File, *syntax.Literal) {
+		f, err := syntax.Parse("test.star", src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// floats are not supported
+		lt := f.Stmts[1].(*syntax.DefStmt).Body[1].(*syntax.ExprStmt).X.(*syntax.CallExpr).Args[2].(*syntax.Literal)
+		lt.Value = 1.5
+		return f, lt
+	}
+	const msg = "statement index 1: rendering def statement Body: statement index 1: rendering expression statement X: " +
+		"rendering call expression: element 2: unsupported literal value type float64, expected string, int, int64, uint, uint64 or *big.Int"
+
+	tests := []struct {
+		name     string
+		options  []Option
+		wantErr  string
+		wantPath string
+	}{
+		{
+			name:     "without source",
+			wantErr:  msg,
+			wantPath: "Stmts[1] Body[1] X Args[2]",
+		},
+		{
+			name:     "with source",
+			options:  []Option{WithSource([]byte(src))},
+			wantErr:  msg + "\ntest.star:5:12:\n\tfoo(1, 2, 3)\n\t          ^",
+			wantPath: "Stmts[1] Body[1] X Args[2]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, lt := parse()
+			_, err := StarlarkFile(f, tt.options...)
+			if err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+			if got := err.Error(); got != tt.wantErr {
+				t.Fatalf("expected error %q, got %q", tt.wantErr, got)
+			}
+			var re *RenderError
+			if !errors.As(err, &re) {
+				t.Fatalf("expected *RenderError, got %T", err)
+			}
+			if re.Node != lt {
+				t.Errorf("expected the literal node, got %T", re.Node)
+			}
+			if got := re.Pos.String(); got != "test.star:5:12" {
+				t.Errorf("expected position test.star:5:12, got %s", got)
+			}
+			var path []string
+			for _, p := range re.Path {
+				path = append(path, p.String())
+			}
+			if got := strings.Join(path, " "); got != tt.wantPath {
+				t.Errorf("expected path %q, got %q", tt.wantPath, got)
+			}
+			if def, ok := re.Path[0].Node.(*syntax.DefStmt); !ok || def.Name.Name != "f" {
+				t.Errorf("expected def f at the path start, got %T", re.Path[0].Node)
+			}
+			if got := re.Err.Error(); !strings.HasPrefix(got, "unsupported literal value type float64") {
+				t.Errorf("expected the cause, got %q", got)
+			}
+		})
+	}
+}
+
+func TestRenderError_synthetic(t *testing.T) {
+	x := &syntax.UnaryExpr{Op: syntax.MINUS}
+	_, err := StarlarkExpr(x, WithSource([]byte("-1\n")))
+	var re *RenderError
+	if !errors.As(err, &re) {
+		t.Fatalf("expected *RenderError, got %v", err)
+	}
+	if re.Node != x || len(re.Path) != 0 || re.Pos.IsValid() {
+		t.Errorf("expected the input node without the path and the position, got %+v", re)
+	}
+	// no position, no source line
+	const want = "rendering unary expression, nil X value for \"-\" token"
+	if got := err.Error(); got != want {
+		t.Errorf("expected error %q, got %q", want, got)
+	}
+
+	// the errors not related to the nodes are returned as is
+	_, err = StarlarkExpr(x, WithDepth(-1))
+	if errors.As(err, &re) {
+		t.Errorf("expected no *RenderError, got %v", err)
+	}
+}
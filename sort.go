@@ -0,0 +1,252 @@
+package starlarkgen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.starlark.net/syntax"
+)
+
+// SortScope selects the lists and dicts sorted when rendering, see WithSort.
+type SortScope uint8
+
+const (
+	// SortNone is the default, the elements are rendered in the original order.
+	SortNone SortScope = iota
+	// SortAll sorts all the lists and dicts.
+	SortAll
+	// SortKwargs sorts the lists and dicts which are the values of the named
+	// keyword arguments, e.g. deps = [...] in a call.
+	SortKwargs
+	// SortKeepSorted sorts the lists and dicts marked with the "# keep sorted"
+	// comment, placed before or after the assignment, the keyword argument,
+	// the opening bracket, or before the first element. The comments are only
+	// available if the source was parsed with syntax.RetainComments.
+	SortKeepSorted
+
+	sortScopeMax
+)
+
+// defaultSortKwargs are the keyword arguments sorted with SortKwargs if none
+// are provided.
+var defaultSortKwargs = []string{"deps", "srcs"}
+
+// WithSort sets the sorting rewrite applied when rendering: the lists of
+// string literals are sorted and deduplicated, and the dicts with literal keys
+// of the same type are sorted by the key. The strings are compared with
+// LabelLess. The lists and dicts with other elements are rendered as is.
+//
+// The kwargs are the names of the keyword arguments sorted with SortKwargs,
+// deps and srcs by default, they are ignored for the other scopes.
+//
+// The input syntax tree is not modified, the sorted copy of the elements is
+// rendered.
+func WithSort(scope SortScope, kwargs ...string) Option {
+	return func(o *outputOpts) (*outputOpts, error) {
+		if scope >= sortScopeMax {
+			return nil, fmt.Errorf("invalid sort scope value %v", scope)
+		}
+		c := o.copy()
+		c.sortScope = scope
+		c.sortKwargs = nil
+		if scope == SortKwargs {
+			if len(kwargs) == 0 {
+				kwargs = defaultSortKwargs
+			}
+			c.sortKwargs = make(map[string]bool, len(kwargs))
+			for _, k := range kwargs {
+				c.sortKwargs[k] = true
+			}
+		}
+		return c, nil
+	}
+}
+
+// LabelLess reports whether the string a sorts before the string b, comparing
+// them as Bazel labels: the labels in the same package, e.g. ":foo", go first,
+// then the relative ones, e.g. "foo.go", then the labels in the same
+// repository, e.g. "//foo", and then the labels in other repositories,
+// e.g. "@foo//bar". The labels in the same group are compared as strings.
+func LabelLess(a, b string) bool {
+	if pa, pb := labelPhase(a), labelPhase(b); pa != pb {
+		return pa < pb
+	}
+	return a < b
+}
+
+func labelPhase(label string) int {
+	switch {
+	case strings.HasPrefix(label, ":"):
+		return 0
+	case strings.HasPrefix(label, "//"):
+		return 2
+	case strings.HasPrefix(label, "@"):
+		return 3
+	default:
+		return 1
+	}
+}
+
+// keepSortedComment reports whether the node has the "# keep sorted" comment
+// attached.
+func keepSortedComment(node syntax.Node) bool {
+	if node == nil {
+		return false
+	}
+	c := node.Comments()
+	if c == nil {
+		return false
+	}
+	for _, list := range [][]syntax.Comment{c.Before, c.Suffix} {
+		for _, cm := range list {
+			text := strings.TrimSpace(strings.TrimPrefix(cm.Text, "#"))
+			if strings.HasPrefix(strings.ToLower(text), "keep sorted") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sortValue returns the options to render the value of the keyword argument
+// or the assignment with, marking the value to be sorted if it is selected
+// by the sort scope.
+func (o *outputOpts) sortValue(name syntax.Expr, markers ...syntax.Node) *outputOpts {
+	var selected bool
+	switch o.sortScope {
+	case SortKwargs:
+		id, ok := name.(*syntax.Ident)
+		selected = ok && o.sortKwargs[id.Name]
+	case SortKeepSorted:
+		for _, m := range markers {
+			if keepSortedComment(m) {
+				selected = true
+			}
+		}
+	}
+	if selected == o.sortNext {
+		return o
+	}
+	c := o.copy()
+	c.sortNext = selected
+	return c
+}
+
+// sortPassThrough reports whether the expression passes the sort mark to its
+// operands, e.g. the list concatenation.
+func sortPassThrough(input syntax.Expr) bool {
+	switch t := input.(type) {
+	case *syntax.ListExpr, *syntax.DictExpr:
+		return true
+	case *syntax.BinaryExpr:
+		return t.Op == syntax.PLUS || t.Op == syntax.PIPE
+	case *syntax.ParenExpr:
+		return true
+	}
+	return false
+}
+
+// sortElems returns the elements of the list or the dict to render,
+// and the options to render them with.
+func (o *outputOpts) sortElems(elems []syntax.Expr) ([]syntax.Expr, *outputOpts) {
+	selected := o.sortScope == SortAll || o.sortNext
+	if !selected && o.sortScope == SortKeepSorted && len(elems) > 0 {
+		selected = keepSortedComment(elems[0])
+	}
+	if o.sortNext {
+		o = o.copy()
+		o.sortNext = false
+	}
+	if !selected || len(elems) < 2 {
+		return elems, o
+	}
+	if sorted, ok := sortedStrings(elems); ok {
+		return sorted, o
+	}
+	if sorted, ok := sortedEntries(elems); ok {
+		return sorted, o
+	}
+	return elems, o
+}
+
+// sortedStrings returns the sorted and deduplicated copy of the string
+// literals.
+func sortedStrings(elems []syntax.Expr) ([]syntax.Expr, bool) {
+	values := make([]string, len(elems))
+	for i, x := range elems {
+		lt, ok := x.(*syntax.Literal)
+		if !ok {
+			return nil, false
+		}
+		s, ok := lt.Value.(string)
+		if !ok {
+			return nil, false
+		}
+		values[i] = s
+	}
+
+	idx := make([]int, len(elems))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return LabelLess(values[idx[i]], values[idx[j]]) })
+
+	res := make([]syntax.Expr, 0, len(elems))
+	for i, n := range idx {
+		if i > 0 && values[n] == values[idx[i-1]] {
+			continue
+		}
+		res = append(res, elems[n])
+	}
+	return res, true
+}
+
+// sortedEntries returns the copy of the dict entries sorted by the key,
+// the keys must be the literals of the same type.
+func sortedEntries(elems []syntax.Expr) ([]syntax.Expr, bool) {
+	keys := make([]interface{}, len(elems))
+	for i, x := range elems {
+		e, ok := x.(*syntax.DictEntry)
+		if !ok {
+			return nil, false
+		}
+		lt, ok := e.Key.(*syntax.Literal)
+		if !ok {
+			return nil, false
+		}
+		switch lt.Value.(type) {
+		case string, int64:
+		default:
+			return nil, false
+		}
+		keys[i] = lt.Value
+		if i > 0 && isStringKey(keys[i]) != isStringKey(keys[0]) {
+			return nil, false
+		}
+	}
+
+	idx := make([]int, len(elems))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		switch a := keys[idx[i]].(type) {
+		case string:
+			return LabelLess(a, keys[idx[j]].(string))
+		default:
+			return a.(int64) < keys[idx[j]].(int64)
+		}
+	})
+
+	res := make([]syntax.Expr, len(elems))
+	for i, n := range idx {
+		res[i] = elems[n]
+	}
+	return res, true
+}
+
+func isStringKey(key interface{}) bool {
+	_, ok := key.(string)
+	return ok
+}
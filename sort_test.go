@@ -0,0 +1,135 @@
+package starlarkgen
+
+import (
+	"testing"
+
+	"go.starlark.net/syntax"
+)
+
+func TestWithSort(t *testing.T) {
+	const source = `X = ["b", "@r//:a", "//p:c", ":d", "b"]
+# keep sorted
+Y = {"b": 1, "a": 2}
+Z = [  # keep sorted
+    "b",
+    "a",
+]
+W = [
+    # Keep sorted.
+    "b",
+    "a",
+]
+foo(
+    deps = ["b", "a"] + ["d", "c"] + glob(["f", "e"]),
+    srcs = ["b", "a"],  # keep sorted
+    data = ["b", "a"],
+    tags = [["b", "a"], "c"],
+    values = {2: "x", 1: "y"},
+    mixed = {"b": 1, 2: 3},
+)
+`
+	tests := []struct {
+		name    string
+		options []Option
+		want    string
+		wantErr string
+	}{
+		{
+			name: "none",
+			want: `X = ["b", "@r//:a", "//p:c", ":d", "b"]
+Y = {"b": 1, "a": 2}
+Z = ["b", "a"]
+W = ["b", "a"]
+foo(deps=["b", "a"] + ["d", "c"] + glob(["f", "e"]), srcs=["b", "a"], data=["b", "a"], tags=[["b", "a"], "c"], values={2: "x", 1: "y"}, mixed={"b": 1, 2: 3})
+`,
+		},
+		{
+			name:    "all",
+			options: []Option{WithSort(SortAll)},
+			want: `X = [":d", "b", "//p:c", "@r//:a"]
+Y = {"a": 2, "b": 1}
+Z = ["a", "b"]
+W = ["a", "b"]
+foo(deps=["a", "b"] + ["c", "d"] + glob(["e", "f"]), srcs=["a", "b"], data=["a", "b"], tags=[["a", "b"], "c"], values={1: "y", 2: "x"}, mixed={"b": 1, 2: 3})
+`,
+		},
+		{
+			name:    "default kwargs",
+			options: []Option{WithSort(SortKwargs)},
+			want: `X = ["b", "@r//:a", "//p:c", ":d", "b"]
+Y = {"b": 1, "a": 2}
+Z = ["b", "a"]
+W = ["b", "a"]
+foo(deps=["a", "b"] + ["c", "d"] + glob(["f", "e"]), srcs=["a", "b"], data=["b", "a"], tags=[["b", "a"], "c"], values={2: "x", 1: "y"}, mixed={"b": 1, 2: 3})
+`,
+		},
+		{
+			name:    "named kwargs",
+			options: []Option{WithSort(SortKwargs, "data", "values")},
+			want: `X = ["b", "@r//:a", "//p:c", ":d", "b"]
+Y = {"b": 1, "a": 2}
+Z = ["b", "a"]
+W = ["b", "a"]
+foo(deps=["b", "a"] + ["d", "c"] + glob(["f", "e"]), srcs=["b", "a"], data=["a", "b"], tags=[["b", "a"], "c"], values={1: "y", 2: "x"}, mixed={"b": 1, 2: 3})
+`,
+		},
+		{
+			name:    "keep sorted",
+			options: []Option{WithSort(SortKeepSorted)},
+			want: `X = ["b", "@r//:a", "//p:c", ":d", "b"]
+Y = {"a": 2, "b": 1}
+Z = ["a", "b"]
+W = ["a", "b"]
+foo(deps=["b", "a"] + ["d", "c"] + glob(["f", "e"]), srcs=["a", "b"], data=["b", "a"], tags=[["b", "a"], "c"], values={2: "x", 1: "y"}, mixed={"b": 1, 2: 3})
+`,
+		},
+		{
+			name:    "invalid scope",
+			options: []Option{WithSort(sortScopeMax)},
+			wantErr: "invalid sort scope value 4",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := syntax.Parse("test.star", source, syntax.RetainComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			for _, st := range f.Stmts {
+				s, err := StarlarkStmt(st, tt.options...)
+				if tt.wantErr != "" {
+					if err == nil {
+						t.Fatal("expected error, got nil")
+					}
+					if gotErr := err.Error(); gotErr != tt.wantErr {
+						t.Fatalf("expected error %q, got %q", tt.wantErr, gotErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				got += s
+			}
+			if got != tt.want {
+				t.Errorf("want:\n%s\ngot:\n%s", tt.want, got)
+			}
+			// the input is not modified
+			if again, err := StarlarkStmt(f.Stmts[0]); err != nil || again != "X = [\"b\", \"@r//:a\", \"//p:c\", \":d\", \"b\"]\n" {
+				t.Errorf("input modified: %q, %v", again, err)
+			}
+		})
+	}
+}
+
+func TestLabelLess(t *testing.T) {
+	labels := []string{":a", ":b", "a.go", "b/c.go", "//a", "//a:b", "//b", "@a//b", "@b"}
+	for i, a := range labels {
+		for j, b := range labels {
+			if got, want := LabelLess(a, b), i < j; got != want {
+				t.Errorf("LabelLess(%q, %q) = %v, want %v", a, b, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package starlarkgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"go.starlark.net/syntax"
+)
+
+// SourceMap records the nodes rendered at the output positions, see
+// WithSourceMap.
+type SourceMap struct {
+	// Entries are the rendered nodes in the order the rendering started,
+	// i.e. the parent nodes go before their children.
+	Entries []SourceMapEntry
+
+	// the position of the end of the output written so far
+	pos outputPos
+}
+
+// SourceMapEntry is the node rendered at the output range.
+type SourceMapEntry struct {
+	// Start and End are the byte offsets of the node in the output, End is
+	// exclusive and includes the newline ending the statements.
+	Start, End int
+	// Line and Col are the output position of the node start, EndLine and
+	// EndCol of the node end, 1-based, the columns count runes the same way
+	// syntax.Position does.
+	Line, Col, EndLine, EndCol int
+	// Node is the node rendered.
+	Node syntax.Node
+	// Pos is the original position of the node, if it has one.
+	Pos syntax.Position
+}
+
+// WithSourceMap makes WriteStmt, WriteFile and WriteExpr, and the respective
+// Starlark* functions, record the nodes rendered to the source map.
+// The output positions continue from the previous rendering with the same map,
+// so the output written between the rendering calls, e.g. separators, is not
+// counted. EditFile does not record the nodes.
+func WithSourceMap(m *SourceMap) Option {
+	return func(o *outputOpts) (*outputOpts, error) {
+		c := o.copy()
+		c.sourceMap = m
+		return c, nil
+	}
+}
+
+// WithPositions makes WriteStmt, WriteFile and WriteExpr, and the respective
+// Starlark* functions, set the position fields of the nodes rendered, e.g.
+// Literal.TokenPos or CallExpr.Lparen and Rparen, to their location in
+// the output, in the file with the name provided. The literals also get
+// the Raw token rendered, and the docstrings the Value as parsed from
+// the output, so the tree matches the one obtained by parsing the output.
+// The positions are counted from the start of the output of each call,
+// unless the source map is set with WithSourceMap, then they continue
+// from the previous rendering with the same map.
+// EditFile does not update the positions.
+func WithPositions(filename string) Option {
+	return func(o *outputOpts) (*outputOpts, error) {
+		c := o.copy()
+		c.posFile = &filename
+		return c, nil
+	}
+}
+
+// outputPos is the position in the output.
+type outputPos struct {
+	offset, line, col int
+}
+
+func (p *outputPos) advance(s string) {
+	p.offset += len(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		p.line += strings.Count(s, "\n")
+		p.col = 1
+		s = s[i+1:]
+	}
+	p.col += utf8.RuneCountInString(s)
+}
+
+// trackingWriter tracks the output position to record the source map and
+// to update the node positions.
+type trackingWriter struct {
+	out  io.StringWriter
+	pos  *outputPos
+	m    *SourceMap
+	file *string
+	// the node updates, applied once the rendering succeeds, as the layout
+	// depends on the original positions
+	updates []func()
+	// the output buffered to roll back the nodes failed to render, and
+	// their errors, see WithKeepGoing
+	buf  *bytes.Buffer
+	errs RenderErrors
+}
+
+// track renders to the output tracking the position, if the source map,
+// the positions update, the errors recovery or the compact suites width is
+// set. The positions are updated only if the rendering succeeds, or all
+// the errors are recovered.
+func (o *outputOpts) track(out io.StringWriter, render func(io.StringWriter) error) error {
+	if o.sourceMap == nil && o.posFile == nil && !o.keepGoing && (!o.compactSuites || o.compactWidth == 0) {
+		return render(out)
+	}
+	w := &trackingWriter{out: out, pos: &outputPos{}, m: o.sourceMap, file: o.posFile}
+	if o.sourceMap != nil {
+		w.pos = &o.sourceMap.pos
+	}
+	if w.pos.line == 0 {
+		w.pos.line, w.pos.col = 1, 1
+	}
+	if o.keepGoing {
+		w.buf = &bytes.Buffer{}
+	}
+	if err := render(w); err != nil {
+		return err
+	}
+	if w.buf != nil {
+		if _, err := out.WriteString(w.buf.String()); err != nil {
+			return err
+		}
+	}
+	for _, u := range w.updates {
+		u()
+	}
+	if len(w.errs) > 0 {
+		return w.errs
+	}
+	return nil
+}
+
+func (w *trackingWriter) WriteString(s string) (int, error) {
+	out := w.out
+	if w.buf != nil {
+		out = w.buf
+	}
+	n, err := out.WriteString(s)
+	w.pos.advance(s[:n])
+	return n, err
+}
+
+// begin records the start of the node rendering, the prefix, e.g.
+// the indentation, is written before the node itself.
+func (w *trackingWriter) begin(node syntax.Node, prefix string) int {
+	start, _ := nodeSpan(node)
+	w.m.Entries = append(w.m.Entries, SourceMapEntry{
+		Start: w.pos.offset + len(prefix),
+		Line:  w.pos.line,
+		Col:   w.pos.col + utf8.RuneCountInString(prefix),
+		Node:  node,
+		Pos:   start,
+	})
+	return len(w.m.Entries) - 1
+}
+
+// end records the end of the node rendering.
+func (w *trackingWriter) end(i int) {
+	e := &w.m.Entries[i]
+	e.End, e.EndLine, e.EndCol = w.pos.offset, w.pos.line, w.pos.col
+}
+
+// sourceMap returns the tracking writer if the source map is recorded.
+func sourceMap(out io.StringWriter) *trackingWriter {
+	if w, ok := out.(*trackingWriter); ok && w.m != nil {
+		return w
+	}
+	return nil
+}
+
+// positions returns the tracking writer if the positions are updated.
+func positions(out io.StringWriter) *trackingWriter {
+	if w, ok := out.(*trackingWriter); ok && w.file != nil {
+		return w
+	}
+	return nil
+}
+
+// setPos sets the position to the current output position, if the positions
+// are updated.
+func setPos(out io.StringWriter, pos *syntax.Position) {
+	if w := positions(out); w != nil {
+		p := syntax.MakePosition(w.file, int32(w.pos.line), int32(w.pos.col))
+		w.updates = append(w.updates, func() { *pos = p })
+	}
+}
+
+// teeWriter writes to the output and to the buffer, to capture the raw tokens
+// rendered.
+type teeWriter struct {
+	out io.StringWriter
+	buf *strings.Builder
+}
+
+func (w *teeWriter) WriteString(s string) (int, error) {
+	n, err := w.out.WriteString(s)
+	w.buf.WriteString(s[:n])
+	return n, err
+}
+
+// LookupOffset returns the innermost node rendered at the output byte offset,
+// or nil if there is none.
+func (m *SourceMap) LookupOffset(offset int) *SourceMapEntry {
+	for i := len(m.Entries) - 1; i >= 0; i-- {
+		if e := &m.Entries[i]; e.Start <= offset && offset < e.End {
+			return e
+		}
+	}
+	return nil
+}
+
+// Lookup returns the innermost node rendered at the output line and column,
+// or nil if there is none.
+func (m *SourceMap) Lookup(line, col int) *SourceMapEntry {
+	before := func(l1, c1, l2, c2 int) bool {
+		return l1 < l2 || l1 == l2 && c1 < c2
+	}
+	for i := len(m.Entries) - 1; i >= 0; i-- {
+		e := &m.Entries[i]
+		if !before(line, col, e.Line, e.Col) && before(line, col, e.EndLine, e.EndCol) {
+			return e
+		}
+	}
+	return nil
+}
+
+type jsonSourceMap struct {
+	Version int                  `json:"version"`
+	Entries []jsonSourceMapEntry `json:"entries"`
+}
+
+type jsonSourceMapEntry struct {
+	Start   int                 `json:"start"`
+	End     int                 `json:"end"`
+	Line    int                 `json:"line"`
+	Col     int                 `json:"col"`
+	EndLine int                 `json:"endLine"`
+	EndCol  int                 `json:"endCol"`
+	Node    string              `json:"node"`
+	Source  *jsonSourcePosition `json:"source,omitempty"`
+}
+
+type jsonSourcePosition struct {
+	File string `json:"file,omitempty"`
+	Line int32  `json:"line"`
+	Col  int32  `json:"col"`
+}
+
+// MarshalJSON encodes the source map as
+//   {"version": 1, "entries": [
+//     {"start": 0, "end": 20, "line": 1, "col": 1, "endLine": 2, "endCol": 1,
+//      "node": "ExprStmt", "source": {"file": "BUILD", "line": 3, "col": 1}},
+//     ...
+//   ]}
+// where node is the node type name, and source is the original position of
+// the node, omitted if the node has none.
+func (m *SourceMap) MarshalJSON() ([]byte, error) {
+	res := jsonSourceMap{Version: 1, Entries: make([]jsonSourceMapEntry, len(m.Entries))}
+	for i, e := range m.Entries {
+		je := jsonSourceMapEntry{
+			Start:   e.Start,
+			End:     e.End,
+			Line:    e.Line,
+			Col:     e.Col,
+			EndLine: e.EndLine,
+			EndCol:  e.EndCol,
+			Node:    strings.TrimPrefix(fmt.Sprintf("%T", e.Node), "*syntax."),
+		}
+		if e.Pos.IsValid() {
+			je.Source = &jsonSourcePosition{Line: e.Pos.Line, Col: e.Pos.Col}
+			// the positions without the file report it as "<invalid>"
+			if f := e.Pos.Filename(); f != "<invalid>" {
+				je.Source.File = f
+			}
+		}
+		res.Entries[i] = je
+	}
+	return json.Marshal(res)
+}
@@ -0,0 +1,237 @@
+package starlarkgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.starlark.net/syntax"
+)
+
+func TestWithSourceMap(t *testing.T) {
+	f, err := syntax.Parse("test.star", "x=1\ndef f():\n  return foo(x)\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the synthetic node has no original position
+	f.Stmts = append(f.Stmts, &syntax.ExprStmt{X: &syntax.CallExpr{Fn: &syntax.Ident{Name: "bar"}}})
+
+	var (
+		m  SourceMap
+		sb strings.Builder
+	)
+	if err := WriteFile(&sb, f, WithSourceMap(&m)); err != nil {
+		t.Fatal(err)
+	}
+	const want = "x = 1\n\ndef f():\n    return foo(x)\n\nbar()\n"
+	if got := sb.String(); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+
+	tests := []struct {
+		name      string
+		line, col int
+		wantNode  string
+		wantText  string
+		wantPos   string
+	}{
+		{name: "assignment", line: 1, col: 3, wantNode: "*syntax.AssignStmt", wantText: "x = 1\n", wantPos: "test.star:1:1"},
+		{name: "literal", line: 1, col: 5, wantNode: "*syntax.Literal", wantText: "1", wantPos: "test.star:1:3"},
+		{name: "nested statement", line: 4, col: 5, wantNode: "*syntax.ReturnStmt", wantText: "return foo(x)\n", wantPos: "test.star:3:3"},
+		{name: "call argument", line: 4, col: 16, wantNode: "*syntax.Ident", wantText: "x", wantPos: "test.star:3:14"},
+		{name: "synthetic node", line: 6, col: 1, wantNode: "*syntax.Ident", wantText: "bar"},
+		{name: "blank line", line: 2, col: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := m.Lookup(tt.line, tt.col)
+			if tt.wantNode == "" {
+				if e != nil {
+					t.Fatalf("expected no entry, got %+v", e)
+				}
+				return
+			}
+			if e == nil {
+				t.Fatal("expected entry, got nil")
+			}
+			if got := fmt.Sprintf("%T", e.Node); got != tt.wantNode {
+				t.Errorf("expected node %s, got %s", tt.wantNode, got)
+			}
+			if got := want[e.Start:e.End]; got != tt.wantText {
+				t.Errorf("expected text %q, got %q", tt.wantText, got)
+			}
+			// the innermost node at the start offset is the entry or its child
+			if got := m.LookupOffset(e.Start); got == nil || got.Start < e.Start || got.End > e.End {
+				t.Errorf("expected the entry or its child by offset, got %+v", got)
+			}
+			gotPos := ""
+			if e.Pos.IsValid() {
+				gotPos = e.Pos.String()
+			}
+			if gotPos != tt.wantPos {
+				t.Errorf("expected position %q, got %q", tt.wantPos, gotPos)
+			}
+		})
+	}
+}
+
+func TestSourceMap_MarshalJSON(t *testing.T) {
+	x, err := syntax.ParseExpr("test.star", "[a]", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m SourceMap
+	if _, err := StarlarkExpr(&syntax.CallExpr{Fn: &syntax.Ident{Name: "f"}, Args: []syntax.Expr{x}}, WithSourceMap(&m)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(&m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"version":1,"entries":[` +
+		`{"start":0,"end":6,"line":1,"col":1,"endLine":1,"endCol":7,"node":"CallExpr"},` +
+		`{"start":0,"end":1,"line":1,"col":1,"endLine":1,"endCol":2,"node":"Ident"},` +
+		`{"start":2,"end":5,"line":1,"col":3,"endLine":1,"endCol":6,"node":"ListExpr","source":{"file":"test.star","line":1,"col":1}},` +
+		`{"start":3,"end":4,"line":1,"col":4,"endLine":1,"endCol":5,"node":"Ident","source":{"file":"test.star","line":1,"col":2}}]}`
+	if string(got) != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
+
+// nodePositions returns the type, the position fields and the raw tokens of
+// the nodes of the tree, in the walk order.
+func nodePositions(n syntax.Node) []string {
+	var res []string
+	syntax.Walk(n, func(n syntax.Node) bool {
+		if n == nil {
+			return true
+		}
+		v := reflect.ValueOf(n).Elem()
+		s := fmt.Sprintf("%T", n)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			if f, ok := v.Field(i).Interface().(syntax.Position); ok {
+				s += fmt.Sprintf(" %s=%s", v.Type().Field(i).Name, f)
+			}
+		}
+		if lt, ok := n.(*syntax.Literal); ok {
+			s += fmt.Sprintf(" Raw=%s Value=%v", lt.Raw, lt.Value)
+		}
+		if ld, ok := n.(*syntax.LoadStmt); ok {
+			for i := range ld.From {
+				s += fmt.Sprintf(" From=%s To=%s", ld.From[i].NamePos, ld.To[i].NamePos)
+			}
+		}
+		res = append(res, s)
+		return true
+	})
+	return res
+}
+
+func TestWithPositions(t *testing.T) {
+	const src = `"""Module.
+
+  More.
+  """
+load("//a:b.bzl",   "c", d="e")
+load(
+    "//x:y.bzl",
+    "z",
+    aa = "bb",
+)
+load("//f:g.bzl", "h", "i", j = "k", l = "m", n = "o", p = "q", r = "s", t = "u", v = "w")
+X = {'a': [1, 2][0:1], "b": (), "c": (3, 4)}
+def f(a, *args, b=1, **kwargs):
+  """Doc.
+
+    Indented.
+  """
+  for x in [y for y in a if y]:
+    if not x:
+      pass
+    elif x > 1:
+      continue
+    else:
+      break
+  a -= 1
+  return a.b if a else -a
+`
+	tests := []struct {
+		name    string
+		options []Option
+	}{
+		{name: "default"},
+		{name: "buildifier", options: []Option{WithStyle(StyleBuildifierBzl)}},
+		{name: "sorted", options: []Option{WithSort(SortAll)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := syntax.Parse("test.bzl", src, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sb strings.Builder
+			if err := WriteFile(&sb, f, append(tt.options, WithPositions("out.bzl"))...); err != nil {
+				t.Fatal(err)
+			}
+			want, err := syntax.Parse("out.bzl", sb.String(), 0)
+			if err != nil {
+				t.Fatalf("parsing the output: %v\n%s", err, sb.String())
+			}
+			wantPos, gotPos := nodePositions(want), nodePositions(f)
+			if len(wantPos) != len(gotPos) {
+				t.Fatalf("expected %d nodes, got %d\n%s", len(wantPos), len(gotPos), sb.String())
+			}
+			for i := range wantPos {
+				if wantPos[i] != gotPos[i] {
+					t.Errorf("node %d: want\n%s\ngot\n%s", i, wantPos[i], gotPos[i])
+				}
+			}
+
+			// the updated tree renders the same output
+			var again strings.Builder
+			if err := WriteFile(&again, f, tt.options...); err != nil {
+				t.Fatal(err)
+			}
+			if again.String() != sb.String() {
+				t.Errorf("want\n%s\ngot\n%s", sb.String(), again.String())
+			}
+		})
+	}
+}
+
+func TestWithPositions_synthetic(t *testing.T) {
+	x := &syntax.CallExpr{
+		Fn:   &syntax.Ident{Name: "foo"},
+		Args: []syntax.Expr{&syntax.Literal{Token: syntax.INT, Value: 1}},
+	}
+	if err := WriteExpr(&strings.Builder{}, x, WithPositions("gen.star")); err != nil {
+		t.Fatal(err)
+	}
+	start, end := x.Span()
+	if got := start.String(); got != "gen.star:1:1" {
+		t.Errorf("expected start gen.star:1:1, got %s", got)
+	}
+	if got := end.String(); got != "gen.star:1:7" {
+		t.Errorf("expected end gen.star:1:7, got %s", got)
+	}
+	if lt := x.Args[0].(*syntax.Literal); lt.Raw != "1" || lt.TokenPos.Col != 5 {
+		t.Errorf("expected literal 1 at 1:5, got %s at %s", lt.Raw, lt.TokenPos)
+	}
+
+	// the positions are not changed if the rendering fails
+	bad := &syntax.CallExpr{
+		Fn:   &syntax.Ident{Name: "foo"},
+		Args: []syntax.Expr{&syntax.Literal{Token: syntax.FLOAT, Value: 1.5}},
+	}
+	if err := WriteExpr(&strings.Builder{}, bad, WithPositions("gen.star")); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if bad.Lparen.IsValid() {
+		t.Errorf("expected no position, got %s", bad.Lparen)
+	}
+}
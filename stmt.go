@@ -15,10 +15,34 @@ var (
 	tripleQuote = strings.Repeat(quote, 3)
 )
 
-func stmtSequence(out io.StringWriter, input []syntax.Stmt, opts *outputOpts) error {
+// stmtSequence writes the nested block of the statements. The empty block
+// is an error, unless the pass statement is written for it, see WithAutoPass.
+func stmtSequence(out io.StringWriter, block string, input []syntax.Stmt, opts *outputOpts) error {
 	stOpts := opts.addDepth(1)
+	stOpts.level++
+	stOpts.block = block
+	if len(input) == 0 {
+		if !opts.autoPass {
+			return fmt.Errorf("empty %s block", block)
+		}
+		return branchStmt(out, &syntax.BranchStmt{Token: syntax.PASS}, stOpts)
+	}
+	return stmtList(out, input, stOpts)
+}
+
+// stmtList writes the statements of the same level, separated with
+// the blank lines as needed.
+func stmtList(out io.StringWriter, input []syntax.Stmt, opts *outputOpts) error {
+	if opts.comments != nil {
+		return commentStmtList(out, input, opts)
+	}
 	for ii, st := range input {
-		if err := stmt(out, st, stOpts); err != nil {
+		if ii > 0 {
+			if err := writeRepeat(out, newline, opts.blankLines(input, ii)); err != nil {
+				return fmt.Errorf("statement index %d: blank line: %w", ii, err)
+			}
+		}
+		if err := stmt(out, st, opts.joinStmt(input, ii)); err != nil {
 			return fmt.Errorf("statement index %d: %w", ii, err)
 		}
 	}
@@ -41,17 +65,53 @@ func hasSpacePrefix(buf []byte, l int) bool {
 	return true
 }
 
+// assignOps are the assignment operators, = and the augmented ones.
+var assignOps = []syntax.Token{
+	syntax.EQ,
+	syntax.PLUS_EQ, syntax.MINUS_EQ, syntax.STAR_EQ, syntax.SLASH_EQ, syntax.SLASHSLASH_EQ, syntax.PERCENT_EQ,
+	syntax.AMP_EQ, syntax.PIPE_EQ, syntax.CIRCUMFLEX_EQ, syntax.LTLT_EQ, syntax.GTGT_EQ,
+}
+
+// assignOp reports whether the token is the assignment operator supported.
+func assignOp(op syntax.Token) bool {
+	for _, tok := range assignOps {
+		if op == tok {
+			return true
+		}
+	}
+	return false
+}
+
+// augmentedTarget returns the error if the LHS can't be the target of
+// the augmented assignment, i.e. it is a tuple or a list.
+func augmentedTarget(lhs syntax.Expr) error {
+	switch lhs.(type) {
+	case *syntax.TupleExpr:
+		return errors.New("can't use tuple expression in augmented assignment")
+	case *syntax.ListExpr:
+		return errors.New("can't use list expression in augmented assignment")
+	}
+	return nil
+}
+
 func assignStmt(out io.StringWriter, input *syntax.AssignStmt, opts *outputOpts) error {
 	if input == nil {
 		return errors.New("rendering assign statement: nil input")
 	}
 
 	// check if Op token is supported for assignment
-	// valid tokens are: EQ | {PLUS,MINUS,STAR,PERCENT}_EQ
-	switch input.Op {
-	case syntax.EQ, syntax.PLUS_EQ, syntax.MINUS_EQ, syntax.STAR_EQ, syntax.PERCENT_EQ:
-	default:
-		return fmt.Errorf("rendering assign statement: unsupported Op token %v, expected one of: %v, %v, %v, %v, %v", input.Op, syntax.EQ, syntax.PLUS_EQ, syntax.MINUS_EQ, syntax.STAR_EQ, syntax.PERCENT_EQ)
+	// valid tokens are: EQ | {PLUS,MINUS,STAR,SLASH,SLASHSLASH,PERCENT,AMP,PIPE,CIRCUMFLEX,LTLT,GTGT}_EQ
+	if !assignOp(input.Op) {
+		ops := make([]string, len(assignOps))
+		for i, tok := range assignOps {
+			ops[i] = tok.String()
+		}
+		return fmt.Errorf("rendering assign statement: unsupported Op token %v, expected one of: %s", input.Op, strings.Join(ops, ", "))
+	}
+	if input.Op != syntax.EQ {
+		if err := augmentedTarget(input.LHS); err != nil {
+			return fmt.Errorf("rendering assign statement LHS: %w", err)
+		}
 	}
 
 	if err := writeRepeat(out, opts.indent, opts.depth); err != nil {
@@ -60,19 +120,28 @@ func assignStmt(out io.StringWriter, input *syntax.AssignStmt, opts *outputOpts)
 	if err := expr(out, input.LHS, opts); err != nil {
 		return fmt.Errorf("rendering assignment statement LHS: %w", err)
 	}
-	if _, err := out.WriteString(space); err != nil {
-		return fmt.Errorf("rendering assignment statement space: %w", err)
+	if !opts.minify {
+		if _, err := out.WriteString(space); err != nil {
+			return fmt.Errorf("rendering assignment statement space: %w", err)
+		}
 	}
+	setPos(out, &input.OpPos)
 	if _, err := out.WriteString(input.Op.String()); err != nil {
 		return fmt.Errorf("rendering assignment statement Op token: %w", err)
 	}
-	if _, err := out.WriteString(space); err != nil {
-		return fmt.Errorf("rendering assignment statement space: %w", err)
+	if !opts.minify {
+		if _, err := out.WriteString(space); err != nil {
+			return fmt.Errorf("rendering assignment statement space: %w", err)
+		}
+	}
+	rhsOpts := opts
+	if opts.sortScope == SortKeepSorted {
+		rhsOpts = opts.sortValue(nil, input, input.LHS)
 	}
-	if err := expr(out, input.RHS, opts); err != nil {
+	if err := expr(out, input.RHS, rhsOpts); err != nil {
 		return fmt.Errorf("rendering assignment statement RHS: %w", err)
 	}
-	if _, err := out.WriteString(newline); err != nil {
+	if err := opts.endStmt(out, input); err != nil {
 		return fmt.Errorf("rendering assignment statement NEWLINE token: %w", err)
 	}
 
@@ -94,10 +163,11 @@ func branchStmt(out io.StringWriter, input *syntax.BranchStmt, opts *outputOpts)
 	if err := writeRepeat(out, opts.indent, opts.depth); err != nil {
 		return fmt.Errorf("rendering branch statement indent: %w", err)
 	}
+	setPos(out, &input.TokenPos)
 	if _, err := out.WriteString(input.Token.String()); err != nil {
 		return fmt.Errorf("rendering branch statement Token token: %w", err)
 	}
-	if _, err := out.WriteString(newline); err != nil {
+	if err := opts.endStmt(out, input); err != nil {
 		return fmt.Errorf("rendering branch statement NEWLINE token: %w", err)
 	}
 	return nil
@@ -111,6 +181,7 @@ func defStmt(out io.StringWriter, input *syntax.DefStmt, opts *outputOpts) error
 	if err := writeRepeat(out, opts.indent, opts.depth); err != nil {
 		return fmt.Errorf("rendering def statement indent: %w", err)
 	}
+	setPos(out, &input.Def)
 	if _, err := out.WriteString(syntax.DEF.String()); err != nil {
 		return fmt.Errorf("rendering def statement DEF token: %w", err)
 	}
@@ -124,7 +195,15 @@ func defStmt(out io.StringWriter, input *syntax.DefStmt, opts *outputOpts) error
 		return fmt.Errorf("rendering def statement LPAREN token: %w", err)
 	}
 	// TODO: add def rendering options
-	if err := exprSequence(out, input.Params, renderOption(0), opts); err != nil {
+	ro := renderOption(0)
+	if opts.style != StyleDefault {
+		ro = opts.styleSequence(seqDef, input, input.Def, input.Params, syntax.Position{})
+	}
+	if ro.multiLineType() == multiLine && !opts.minify {
+		if err := defParams(out, input, input.Params, opts); err != nil {
+			return fmt.Errorf("rendering def statement Params: %w", err)
+		}
+	} else if err := exprSequence(out, input, input.Params, ro, opts); err != nil {
 		return fmt.Errorf("rendering def statement Params: %w", err)
 	}
 	if _, err := out.WriteString(syntax.RPAREN.String()); err != nil {
@@ -133,12 +212,80 @@ func defStmt(out io.StringWriter, input *syntax.DefStmt, opts *outputOpts) error
 	if _, err := out.WriteString(syntax.COLON.String()); err != nil {
 		return fmt.Errorf("rendering def statement COLON token: %w", err)
 	}
-	if _, err := out.WriteString(newline); err != nil {
-		return fmt.Errorf("rendering def statement NEWLINE token: %w", err)
+	body := opts.dropDocstring(input.Body)
+	if len(body) == 0 && len(input.Body) > 0 {
+		// the body was the docstring only
+		body = []syntax.Stmt{&syntax.BranchStmt{Token: syntax.PASS}}
 	}
-	if err := stmtSequence(out, input.Body, opts); err != nil {
+	compact, err := compactSuite(out, body, opts)
+	if err != nil {
 		return fmt.Errorf("rendering def statement Body: %w", err)
 	}
+	if !compact {
+		if err := opts.newline(out); err != nil {
+			return fmt.Errorf("rendering def statement NEWLINE token: %w", err)
+		}
+		if err := stmtSequence(out, "def", body, opts); err != nil {
+			return fmt.Errorf("rendering def statement Body: %w", err)
+		}
+	}
+	return nil
+}
+
+// defParams writes the multiline parameters with the double indentation,
+// keeping the closing parenthesis on the line of the last parameter, unless
+// there are comments before it, e.g.
+//   def foo(
+//           bar,
+//           baz):
+func defParams(out io.StringWriter, node syntax.Node, input []syntax.Expr, opts *outputOpts) error {
+	pOpts := opts.addDepth(2)
+	cm := opts.comments
+	for i, param := range input {
+		if i > 0 {
+			if _, err := out.WriteString(syntax.COMMA.String()); err != nil {
+				return fmt.Errorf("COMMA token: %w", err)
+			}
+			if err := pOpts.newline(out); err != nil {
+				return fmt.Errorf("NEWLINE token: %w", err)
+			}
+		} else {
+			if _, err := out.WriteString(newline); err != nil {
+				return fmt.Errorf("NEWLINE token: %w", err)
+			}
+			if err := pOpts.pendingLines(out); err != nil {
+				return err
+			}
+		}
+		if cm != nil {
+			if err := pOpts.commentLines(out, cm.before[param]); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+		if err := writeRepeat(out, pOpts.indent, pOpts.depth); err != nil {
+			return fmt.Errorf("indent: %w", err)
+		}
+		if err := expr(out, param, pOpts); err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+	if cm == nil || len(cm.end[node]) == 0 {
+		return nil
+	}
+	if len(input) == 0 {
+		if _, err := out.WriteString(newline); err != nil {
+			return fmt.Errorf("NEWLINE token: %w", err)
+		}
+	}
+	if err := pOpts.endComments(out, cm.end[node], len(input) > 0); err != nil {
+		return err
+	}
+	if err := opts.newline(out); err != nil {
+		return fmt.Errorf("NEWLINE token: %w", err)
+	}
+	if err := writeRepeat(out, opts.indent, opts.depth); err != nil {
+		return fmt.Errorf("indent: %w", err)
+	}
 	return nil
 }
 
@@ -150,6 +297,13 @@ func docstring(out io.StringWriter, input *syntax.Literal, strValue string, opts
 	if err := writeRepeat(out, opts.indent, opts.depth); err != nil {
 		return fmt.Errorf("rendering docstring expression statement indent: %w", err)
 	}
+	// the docstring gets the raw token and the value of the output
+	w := positions(out)
+	var raw strings.Builder
+	if w != nil {
+		setPos(out, &input.TokenPos)
+		out = &teeWriter{out: out, buf: &raw}
+	}
 	if _, err := out.WriteString(tripleQuote); err != nil {
 		return fmt.Errorf("rendering docstring expression statement TRIPLE QUOTE token: %w", err)
 	}
@@ -228,7 +382,18 @@ func docstring(out io.StringWriter, input *syntax.Literal, strValue string, opts
 	if _, err := out.WriteString(tripleQuote); err != nil {
 		return fmt.Errorf("rendering docstring expression statement TRIPLE QUOTE token: %w", err)
 	}
-	if _, err := out.WriteString(newline); err != nil {
+	if w != nil {
+		token := raw.String()
+		w.updates = append(w.updates, func() {
+			input.Raw = token
+			if x, err := syntax.ParseExpr("", token, 0); err == nil {
+				if lt, ok := x.(*syntax.Literal); ok {
+					input.Value = lt.Value
+				}
+			}
+		})
+	}
+	if err := opts.newline(out); err != nil {
 		return fmt.Errorf("rendering docstring expression statement NEWLINE token: %w", err)
 	}
 
@@ -248,7 +413,12 @@ func exprStmt(out io.StringWriter, input *syntax.ExprStmt, opts *outputOpts) err
 	//     """
 	if lt, ok := input.X.(*syntax.Literal); ok {
 		if strValue, ok := lt.Value.(string); ok {
-			return docstring(out, lt, strValue, opts)
+			// styles keep the parsed token as is, the minified output has
+			// the plain string literal
+			if _, ok := rawLiteral(lt); (!ok || opts.style == StyleDefault) && !opts.minify {
+				opts.comments.queue(input)
+				return docstring(out, lt, strValue, opts)
+			}
 		}
 	}
 
@@ -258,7 +428,7 @@ func exprStmt(out io.StringWriter, input *syntax.ExprStmt, opts *outputOpts) err
 	if err := expr(out, input.X, opts); err != nil {
 		return fmt.Errorf("rendering expression statement X: %w", err)
 	}
-	if _, err := out.WriteString(newline); err != nil {
+	if err := opts.endStmt(out, input); err != nil {
 		return fmt.Errorf("rendering expression statement NEWLINE token: %w", err)
 	}
 
@@ -273,6 +443,7 @@ func forStmt(out io.StringWriter, input *syntax.ForStmt, opts *outputOpts) error
 	if err := writeRepeat(out, opts.indent, opts.depth); err != nil {
 		return fmt.Errorf("rendering for statement indent: %w", err)
 	}
+	setPos(out, &input.For)
 	if _, err := out.WriteString(syntax.FOR.String()); err != nil {
 		return fmt.Errorf("rendering for statement FOR token: %w", err)
 	}
@@ -297,12 +468,18 @@ func forStmt(out io.StringWriter, input *syntax.ForStmt, opts *outputOpts) error
 	if _, err := out.WriteString(syntax.COLON.String()); err != nil {
 		return fmt.Errorf("rendering for statement COLON token: %w", err)
 	}
-	if _, err := out.WriteString(newline); err != nil {
-		return fmt.Errorf("rendering for statement NEWLINE token: %w", err)
-	}
-	if err := stmtSequence(out, input.Body, opts); err != nil {
+	compact, err := compactSuite(out, input.Body, opts)
+	if err != nil {
 		return fmt.Errorf("rendering for statement Body: %w", err)
 	}
+	if !compact {
+		if err := opts.newline(out); err != nil {
+			return fmt.Errorf("rendering for statement NEWLINE token: %w", err)
+		}
+		if err := stmtSequence(out, "for", input.Body, opts); err != nil {
+			return fmt.Errorf("rendering for statement Body: %w", err)
+		}
+	}
 
 	return nil
 }
@@ -312,44 +489,96 @@ func ifStmt(out io.StringWriter, input *syntax.IfStmt, opts *outputOpts) error {
 		return errors.New("rendering if statement: nil input")
 	}
 
-	if err := writeRepeat(out, opts.indent, opts.depth); err != nil {
-		return fmt.Errorf("rendering if statement indent: %w", err)
-	}
-	if _, err := out.WriteString(syntax.IF.String()); err != nil {
-		return fmt.Errorf("rendering if statement IF token: %w", err)
-	}
-	if _, err := out.WriteString(space); err != nil {
-		return fmt.Errorf("rendering if statement space: %w", err)
-	}
-	if err := expr(out, input.Cond, opts); err != nil {
-		return fmt.Errorf("rendering if statement Cond: %w", err)
-	}
-	if _, err := out.WriteString(syntax.COLON.String()); err != nil {
-		return fmt.Errorf("rendering if statement COLON token: %w", err)
-	}
-	if _, err := out.WriteString(newline); err != nil {
-		return fmt.Errorf("rendering if statement NEWLINE token: %w", err)
-	}
-	if err := stmtSequence(out, input.True, opts); err != nil {
-		return fmt.Errorf("rendering if statement True: %w", err)
+	var (
+		keyword   = syntax.IF
+		elsePos   *syntax.Position
+		blankElse bool
+	)
+	for {
+		if blankElse {
+			if _, err := out.WriteString(newline); err != nil {
+				return fmt.Errorf("rendering if statement blank line: %w", err)
+			}
+		}
+		if err := writeRepeat(out, opts.indent, opts.depth); err != nil {
+			return fmt.Errorf("rendering if statement indent: %w", err)
+		}
+		if elsePos != nil {
+			setPos(out, elsePos)
+		}
+		setPos(out, &input.If)
+		if _, err := out.WriteString(keyword.String()); err != nil {
+			return fmt.Errorf("rendering if statement %s token: %w", strings.ToUpper(keyword.String()), err)
+		}
+		if _, err := out.WriteString(space); err != nil {
+			return fmt.Errorf("rendering if statement space: %w", err)
+		}
+		if err := expr(out, input.Cond, opts); err != nil {
+			return fmt.Errorf("rendering if statement Cond: %w", err)
+		}
+		if _, err := out.WriteString(syntax.COLON.String()); err != nil {
+			return fmt.Errorf("rendering if statement COLON token: %w", err)
+		}
+		compact, err := compactSuite(out, input.True, opts)
+		if err != nil {
+			return fmt.Errorf("rendering if statement True: %w", err)
+		}
+		if !compact {
+			if err := opts.newline(out); err != nil {
+				return fmt.Errorf("rendering if statement NEWLINE token: %w", err)
+			}
+			if err := stmtSequence(out, keyword.String(), input.True, opts); err != nil {
+				return fmt.Errorf("rendering if statement True: %w", err)
+			}
+		}
+
+		blankElse = opts.style != StyleDefault && elseBlankLine(input)
+
+		// styles render the single if statement in else block as elif,
+		// unless the comments of the else clause or before the statement
+		// would be lost
+		if opts.style == StyleDefault || len(input.False) != 1 {
+			break
+		}
+		elif, ok := input.False[0].(*syntax.IfStmt)
+		if !ok || elif == nil || opts.comments.keepElse(input, elif) {
+			break
+		}
+		// the ELIF token is both the else of the statement and the if of
+		// the nested one
+		elsePos = &input.ElsePos
+		input, keyword = elif, syntax.ELIF
 	}
 
 	if len(input.False) > 0 {
+		if blankElse {
+			if _, err := out.WriteString(newline); err != nil {
+				return fmt.Errorf("rendering if statement blank line: %w", err)
+			}
+		}
 		if err := writeRepeat(out, opts.indent, opts.depth); err != nil {
 			return fmt.Errorf("rendering if statement indent: %w", err)
 		}
+		setPos(out, &input.ElsePos)
 		if _, err := out.WriteString(syntax.ELSE.String()); err != nil {
 			return fmt.Errorf("rendering if statement ELSE token: %w", err)
 		}
 		if _, err := out.WriteString(syntax.COLON.String()); err != nil {
 			return fmt.Errorf("rendering if statement COLON token: %w", err)
 		}
-		if _, err := out.WriteString(newline); err != nil {
-			return fmt.Errorf("rendering if statement NEWLINE token: %w", err)
-		}
-		if err := stmtSequence(out, input.False, opts); err != nil {
+		opts.comments.queueElse(input)
+		compact, err := compactSuite(out, input.False, opts)
+		if err != nil {
 			return fmt.Errorf("rendering if statement False: %w", err)
 		}
+		if !compact {
+			if err := opts.newline(out); err != nil {
+				return fmt.Errorf("rendering if statement NEWLINE token: %w", err)
+			}
+			if err := stmtSequence(out, "else", input.False, opts); err != nil {
+				return fmt.Errorf("rendering if statement False: %w", err)
+			}
+		}
 	}
 
 	return nil
@@ -362,10 +591,14 @@ func loadStmt(out io.StringWriter, input *syntax.LoadStmt, opts *outputOpts) err
 	if len(input.From) != len(input.To) {
 		return fmt.Errorf("rendering load statement, lengths mismatch, From: %d, To: %d", len(input.From), len(input.To))
 	}
+	if opts.style != StyleDefault {
+		return styleLoadStmt(out, input, opts)
+	}
 
 	if err := writeRepeat(out, opts.indent, opts.depth); err != nil {
 		return fmt.Errorf("rendering load statement indent: %w", err)
 	}
+	setPos(out, &input.Load)
 	if _, err := out.WriteString(syntax.LOAD.String()); err != nil {
 		return fmt.Errorf("rendering load statement LOAD token: %w", err)
 	}
@@ -381,8 +614,10 @@ func loadStmt(out io.StringWriter, input *syntax.LoadStmt, opts *outputOpts) err
 		if _, err := out.WriteString(syntax.COMMA.String()); err != nil {
 			return fmt.Errorf("rendering load statement COMMA token: %w", err)
 		}
-		if _, err := out.WriteString(space); err != nil {
-			return fmt.Errorf("rendering load statement space: %w", err)
+		if !opts.minify {
+			if _, err := out.WriteString(space); err != nil {
+				return fmt.Errorf("rendering load statement space: %w", err)
+			}
 		}
 		if input.To[i] != nil && input.To[i].Name != elem.Name {
 			if err := expr(out, input.To[i], opts); err != nil {
@@ -416,16 +651,88 @@ func loadStmt(out io.StringWriter, input *syntax.LoadStmt, opts *outputOpts) err
 		}
 	}
 
+	setPos(out, &input.Rparen)
 	if _, err := out.WriteString(syntax.RPAREN.String()); err != nil {
 		return fmt.Errorf("rendering load statement RPAREN token: %w", err)
 	}
-	if _, err := out.WriteString(newline); err != nil {
+	if err := opts.endStmt(out, input); err != nil {
 		return fmt.Errorf("rendering load statement NEWLINE token: %w", err)
 	}
 
 	return nil
 }
 
+// loadArgs returns the load statement arguments as the call arguments, e.g.
+// "module", "symbol", alias = "symbol", the symbols in the order given.
+func loadArgs(input *syntax.LoadStmt, order []int) []syntax.Expr {
+	args := make([]syntax.Expr, 0, len(order)+1)
+	args = append(args, input.Module)
+	for _, i := range order {
+		from := input.From[i]
+		var name syntax.Expr = &syntax.Literal{Token: syntax.STRING, Value: from.Name}
+		if to := input.To[i]; to != nil && to.Name != from.Name {
+			name = &syntax.BinaryExpr{X: to, Op: syntax.EQ, Y: name}
+		}
+		args = append(args, name)
+	}
+	return args
+}
+
+// loadArgsPos sets the positions of the loaded names to the ones of
+// the string literals rendered for them, after the opening quote, the way
+// the parser does.
+func loadArgsPos(out io.StringWriter, input *syntax.LoadStmt, order []int, args []syntax.Expr) {
+	w := positions(out)
+	if w == nil {
+		return
+	}
+	for k, i := range order {
+		from := input.From[i]
+		lt, ok := args[k+1].(*syntax.Literal)
+		if bx, isAlias := args[k+1].(*syntax.BinaryExpr); isAlias {
+			lt, ok = bx.Y.(*syntax.Literal)
+		}
+		if !ok {
+			continue
+		}
+		// the literal positions are set by the preceding updates
+		w.updates = append(w.updates, func() {
+			from.NamePos = syntax.MakePosition(w.file, lt.TokenPos.Line, lt.TokenPos.Col+1)
+		})
+	}
+}
+
+// styleLoadStmt writes the load statement as the call with the buildifier
+// layout, the loaded symbols are sorted and their comments are kept.
+func styleLoadStmt(out io.StringWriter, input *syntax.LoadStmt, opts *outputOpts) error {
+	order := opts.rewrites.loadOrder(input)
+	args := loadArgs(input, order)
+	opts.comments.loadArgs(input, order, args)
+	ro := opts.styleSequence(seqLoad, input, input.Load, args, input.Rparen)
+	if err := writeRepeat(out, opts.indent, opts.depth); err != nil {
+		return fmt.Errorf("rendering load statement indent: %w", err)
+	}
+	setPos(out, &input.Load)
+	if _, err := out.WriteString(syntax.LOAD.String()); err != nil {
+		return fmt.Errorf("rendering load statement LOAD token: %w", err)
+	}
+	if _, err := out.WriteString(syntax.LPAREN.String()); err != nil {
+		return fmt.Errorf("rendering load statement LPAREN token: %w", err)
+	}
+	if err := exprSequence(out, input, args, ro, opts); err != nil {
+		return fmt.Errorf("rendering load statement: %w", err)
+	}
+	loadArgsPos(out, input, order, args)
+	setPos(out, &input.Rparen)
+	if _, err := out.WriteString(syntax.RPAREN.String()); err != nil {
+		return fmt.Errorf("rendering load statement RPAREN token: %w", err)
+	}
+	if err := opts.endStmt(out, input); err != nil {
+		return fmt.Errorf("rendering load statement NEWLINE token: %w", err)
+	}
+	return nil
+}
+
 func returnStmt(out io.StringWriter, input *syntax.ReturnStmt, opts *outputOpts) error {
 	if input == nil {
 		return errors.New("rendering return statement: nil input")
@@ -434,6 +741,7 @@ func returnStmt(out io.StringWriter, input *syntax.ReturnStmt, opts *outputOpts)
 	if err := writeRepeat(out, opts.indent, opts.depth); err != nil {
 		return fmt.Errorf("rendering return statement indent: %w", err)
 	}
+	setPos(out, &input.Return)
 	if _, err := out.WriteString(syntax.RETURN.String()); err != nil {
 		return fmt.Errorf("rendering return statement RETURN token: %w", err)
 	}
@@ -447,7 +755,7 @@ func returnStmt(out io.StringWriter, input *syntax.ReturnStmt, opts *outputOpts)
 		}
 	}
 
-	if _, err := out.WriteString(newline); err != nil {
+	if err := opts.endStmt(out, input); err != nil {
 		return fmt.Errorf("rendering return statement NEWLINE token: %w", err)
 	}
 
@@ -462,6 +770,7 @@ func whileStmt(out io.StringWriter, input *syntax.WhileStmt, opts *outputOpts) e
 	if err := writeRepeat(out, opts.indent, opts.depth); err != nil {
 		return fmt.Errorf("rendering while statement indent: %w", err)
 	}
+	setPos(out, &input.While)
 	if _, err := out.WriteString(syntax.WHILE.String()); err != nil {
 		return fmt.Errorf("rendering while statement WHILE token: %w", err)
 	}
@@ -474,17 +783,35 @@ func whileStmt(out io.StringWriter, input *syntax.WhileStmt, opts *outputOpts) e
 	if _, err := out.WriteString(syntax.COLON.String()); err != nil {
 		return fmt.Errorf("rendering while statement COLON token: %w", err)
 	}
-	if _, err := out.WriteString(newline); err != nil {
-		return fmt.Errorf("rendering while statement NEWLINE token: %w", err)
-	}
-	if err := stmtSequence(out, input.Body, opts); err != nil {
+	compact, err := compactSuite(out, input.Body, opts)
+	if err != nil {
 		return fmt.Errorf("rendering while statement Body: %w", err)
 	}
+	if !compact {
+		if err := opts.newline(out); err != nil {
+			return fmt.Errorf("rendering while statement NEWLINE token: %w", err)
+		}
+		if err := stmtSequence(out, "while", input.Body, opts); err != nil {
+			return fmt.Errorf("rendering while statement Body: %w", err)
+		}
+	}
 
 	return nil
 }
 
-func stmt(out io.StringWriter, input syntax.Stmt, opts *outputOpts) error {
+func stmt(out io.StringWriter, input syntax.Stmt, opts *outputOpts) (err error) {
+	defer func() {
+		if err != nil {
+			err = nodeError(input, err)
+		}
+	}()
+	if sm := sourceMap(out); sm != nil {
+		// the statements start with the indentation
+		defer sm.end(sm.begin(input, strings.Repeat(opts.indent, opts.depth)))
+	}
+	if kg := keepGoing(out); kg != nil {
+		defer kg.recover(kg.checkpoint(), input, strings.Repeat(opts.indent, opts.depth), opts.stmtEnd(), &err)
+	}
 	switch t := input.(type) {
 	case *syntax.AssignStmt:
 		return assignStmt(out, t, opts)
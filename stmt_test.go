@@ -37,10 +37,54 @@ func Test_stmt(t *testing.T) {
 			inputAssignStmt: &syntax.AssignStmt{LHS: &syntax.Ident{Name: "foo"}, Op: syntax.PLUS_EQ, RHS: &syntax.Literal{Value: 2}},
 			want:            "foo += 2\n",
 		},
+		{
+			name:            "assign statement, SLASHSLASH_EQ",
+			inputAssignStmt: &syntax.AssignStmt{LHS: &syntax.Ident{Name: "foo"}, Op: syntax.SLASHSLASH_EQ, RHS: &syntax.Literal{Value: 2}},
+			want:            "foo //= 2\n",
+		},
+		{
+			name:            "assign statement, GTGT_EQ",
+			inputAssignStmt: &syntax.AssignStmt{LHS: &syntax.Ident{Name: "foo"}, Op: syntax.GTGT_EQ, RHS: &syntax.Literal{Value: 2}},
+			want:            "foo >>= 2\n",
+		},
+		{
+			name:            "assign statement, SLASH_EQ",
+			inputAssignStmt: &syntax.AssignStmt{LHS: &syntax.Ident{Name: "foo"}, Op: syntax.SLASH_EQ, RHS: &syntax.Literal{Value: 2}},
+			want:            "foo /= 2\n",
+		},
+		{
+			name:            "assign statement, LTLT_EQ",
+			inputAssignStmt: &syntax.AssignStmt{LHS: &syntax.Ident{Name: "foo"}, Op: syntax.LTLT_EQ, RHS: &syntax.Literal{Value: 2}},
+			want:            "foo <<= 2\n",
+		},
+		{
+			name:            "assign statement, AMP_EQ",
+			inputAssignStmt: &syntax.AssignStmt{LHS: &syntax.Ident{Name: "foo"}, Op: syntax.AMP_EQ, RHS: &syntax.Literal{Value: 2}},
+			want:            "foo &= 2\n",
+		},
+		{
+			name:            "assign statement, PIPE_EQ",
+			inputAssignStmt: &syntax.AssignStmt{LHS: &syntax.Ident{Name: "foo"}, Op: syntax.PIPE_EQ, RHS: &syntax.Literal{Value: 2}},
+			want:            "foo |= 2\n",
+		},
+		{
+			name:            "assign statement, CIRCUMFLEX_EQ",
+			inputAssignStmt: &syntax.AssignStmt{LHS: &syntax.Ident{Name: "foo"}, Op: syntax.CIRCUMFLEX_EQ, RHS: &syntax.Literal{Value: 2}},
+			want:            "foo ^= 2\n",
+		},
+		{
+			name: "assign statement, augmented tuple",
+			inputAssignStmt: &syntax.AssignStmt{
+				LHS: &syntax.TupleExpr{List: []syntax.Expr{&syntax.Ident{Name: "a"}, &syntax.Ident{Name: "b"}}},
+				Op:  syntax.PLUS_EQ,
+				RHS: &syntax.Literal{Value: 2},
+			},
+			wantErr: "rendering assign statement LHS: can't use tuple expression in augmented assignment",
+		},
 		{
 			name:            "assign statement, invalid token",
 			inputAssignStmt: &syntax.AssignStmt{LHS: &syntax.Ident{Name: "foo"}, Op: syntax.STAR, RHS: &syntax.Literal{Value: 2}},
-			wantErr:         "rendering assign statement: unsupported Op token *, expected one of: =, +=, -=, *=, %=",
+			wantErr:         "rendering assign statement: unsupported Op token *, expected one of: =, +=, -=, *=, /=, //=, %=, &=, |=, ^=, <<=, >>=",
 		},
 		{
 			name:            "branch statement, supported token PASS",
@@ -281,6 +325,50 @@ func Test_stmt(t *testing.T) {
 			},
 			want: "while a > b:\n    b += 1\n",
 		},
+		{
+			name:         "empty def body",
+			inputDefStmt: &syntax.DefStmt{Name: &syntax.Ident{Name: "foo"}},
+			wantErr:      "rendering def statement Body: empty def block",
+		},
+		{
+			name:         "empty def body, auto pass",
+			opts:         []Option{WithAutoPass(true)},
+			inputDefStmt: &syntax.DefStmt{Name: &syntax.Ident{Name: "foo"}},
+			want:         "def foo():\n    pass\n",
+		},
+		{
+			name:         "empty for body",
+			inputForStmt: &syntax.ForStmt{Vars: &syntax.Ident{Name: "a"}, X: &syntax.Ident{Name: "b"}},
+			wantErr:      "rendering for statement Body: empty for block",
+		},
+		{
+			name: "empty if body with ELSE clause",
+			inputIfStmt: &syntax.IfStmt{
+				Cond:  &syntax.Ident{Name: "a"},
+				False: []syntax.Stmt{&syntax.BranchStmt{Token: syntax.PASS}},
+			},
+			wantErr: "rendering if statement True: empty if block",
+		},
+		{
+			name: "empty if body with ELSE clause, auto pass",
+			opts: []Option{WithAutoPass(true), WithDepth(1)},
+			inputIfStmt: &syntax.IfStmt{
+				Cond:  &syntax.Ident{Name: "a"},
+				False: []syntax.Stmt{&syntax.ReturnStmt{}},
+			},
+			want: "    if a:\n        pass\n    else:\n        return\n",
+		},
+		{
+			name:           "empty while body",
+			inputWhileStmt: &syntax.WhileStmt{Cond: &syntax.Ident{Name: "a"}},
+			wantErr:        "rendering while statement Body: empty while block",
+		},
+		{
+			name:           "empty while body, auto pass",
+			opts:           []Option{WithAutoPass(true)},
+			inputWhileStmt: &syntax.WhileStmt{Cond: &syntax.Ident{Name: "a"}},
+			want:           "while a:\n    pass\n",
+		},
 	}
 
 	for _, tt := range tests {
@@ -350,6 +438,36 @@ func Test_stmt(t *testing.T) {
 	}
 }
 
+// Test_augmentedAssign checks the augmented assignments are parsed back.
+func Test_augmentedAssign(t *testing.T) {
+	const src = `def flags(n):
+    x = n
+    x += 1
+    x -= 1
+    x *= 2
+    x //= 2
+    x /= 1
+    x %= 7
+    x <<= 3
+    x >>= 1
+    x &= 255
+    x |= 1
+    x ^= 16
+    return x
+`
+	f, err := syntax.Parse("test.star", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := StarlarkFile(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != src {
+		t.Errorf("want\n%s\ngot\n%s", src, got)
+	}
+}
+
 func Test_nilStmt(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -0,0 +1,380 @@
+package starlarkgen
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"go.starlark.net/syntax"
+)
+
+// Style selects the set of layout rules reproducing an external formatter,
+// which take precedence over the call, dict, list and tuple options.
+type Style uint8
+
+const (
+	// StyleDefault is the default, render using the other options.
+	StyleDefault Style = iota
+	// StyleBuildifier reproduces the buildifier formatting of BUILD files:
+	// the top-level calls, lists, dicts and tuples with two and more elements
+	// are multiline with comma after last element, and the top-level
+	// statements are separated with blank lines, except for the loads.
+	// The code inside def, for and if bodies is formatted as with
+	// StyleBuildifierBzl.
+	StyleBuildifier
+	// StyleBuildifierBzl reproduces the buildifier formatting of .bzl files:
+	// the sequences are multiline if they were multiline in the parsed
+	// source and single line otherwise, defs are separated with blank lines
+	// and the other blank lines are kept from the parsed source.
+	StyleBuildifierBzl
+
+	styleMax
+)
+
+// WithStyle sets the layout rules, the buildifier styles also set the 4
+// spaces indentation and the spaces around the equality sign in arguments.
+//
+// The buildifier styles use the node positions, if present, to keep the
+// layout decisions buildifier keeps from the source, and the raw tokens of
+// the literals, e.g. hex numbers or single quoted strings with double
+// quotes inside. The comments, available if the source was parsed with
+// syntax.RetainComments, are rendered where buildifier puts them.
+//
+// The buildifier sorting rewrites are applied to the output, the input
+// syntax tree is not modified: the named arguments of the calls in BUILD
+// files are ordered by the attribute priority and name, e.g. name first and
+// deps last, the lists of strings of the attributes such as srcs and deps,
+// of the visibility calls and the ones marked with the "# keep sorted"
+// comment are sorted and deduplicated, and the loaded symbols are sorted.
+// The "# do not sort" and "# buildifier: leave-alone" comments are honored.
+// The other rewrites, e.g. moving, merging and sorting the load statements,
+// moving the positional arguments before the named ones or canonicalizing
+// the labels, are not applied, see FixLoads.
+func WithStyle(value Style) Option {
+	return func(o *outputOpts) (*outputOpts, error) {
+		if value >= styleMax {
+			return nil, fmt.Errorf("invalid style value %v", value)
+		}
+		c := o.copy()
+		c.style = value
+		if value != StyleDefault {
+			c.indent = defaultIndent
+			c.spaceEqBinary = true
+		}
+		return c, nil
+	}
+}
+
+// seqMode is the kind of bracketed sequence the layout is selected for.
+type seqMode uint8
+
+const (
+	seqCall seqMode = iota
+	seqList
+	seqDict
+	seqTuple
+	seqBareTuple
+	seqDef
+	seqLoad
+	seqParen
+)
+
+// styleSequence returns the render option for the sequence node of elements
+// between the brackets at the start and end positions, following the
+// buildifier rules.
+func (o *outputOpts) styleSequence(mode seqMode, node syntax.Node, start syntax.Position, elems []syntax.Expr, end syntax.Position) renderOption {
+	compact := !o.comments.multiline(mode, node, elems) && o.styleCompact(mode, start, elems, end)
+	if compact {
+		// single element tuples require the comma
+		if (mode == seqTuple || mode == seqBareTuple) && len(elems) == 1 {
+			return makeRenderOption(singleLine, alwaysLastComma)
+		}
+		return makeRenderOption(singleLine, noLastComma)
+	}
+	if mode == seqDef || mode == seqParen {
+		return makeRenderOption(multiLine, noLastComma)
+	}
+	if mode == seqCall && len(elems) > 0 {
+		// no comma is allowed after *args and **kwargs
+		if u, ok := elems[len(elems)-1].(*syntax.UnaryExpr); ok && (u.Op == syntax.STAR || u.Op == syntax.STARSTAR) {
+			return makeRenderOption(multiLine, noLastComma)
+		}
+	}
+	return makeRenderOption(multiLine, alwaysLastComma)
+}
+
+func (o *outputOpts) styleCompact(mode seqMode, start syntax.Position, elems []syntax.Expr, end syntax.Position) bool {
+	switch {
+	case mode == seqBareTuple:
+		return true
+	case mode == seqCall && len(elems) == 0:
+		return true
+	case mode == seqLoad:
+		// loads are kept single line, unless they were multiline
+		return !start.IsValid() || !end.IsValid() || start.Line == end.Line || len(elems) <= 1
+	case o.style == StyleBuildifierBzl || o.level > 0 || mode == seqDef:
+		return !multilineInSource(mode, start, elems, end)
+	}
+
+	// top-level BUILD file sequences
+	if len(elems) <= 1 {
+		return !multilineInSource(mode, start, elems, end)
+	}
+	if mode == seqCall || mode == seqTuple {
+		return compactInSource(start, elems, end)
+	}
+	return false
+}
+
+// multilineInSource reports whether any of the elements or the closing
+// bracket starts on the different line than the previous element ends.
+func multilineInSource(mode seqMode, start syntax.Position, elems []syntax.Expr, end syntax.Position) bool {
+	prev := start
+	for _, x := range elems {
+		s, e := nodeSpan(x)
+		if differentLines(s, prev) {
+			return true
+		}
+		if e.IsValid() {
+			prev = e
+		}
+	}
+	// the closing parenthesis of def is kept on the line of the last parameter
+	return mode != seqDef && differentLines(prev, end)
+}
+
+// multilineComprehension reports whether the body, the clauses or
+// the closing bracket of the comprehension start on the different line than
+// the previous one ends, which buildifier keeps.
+func multilineComprehension(input *syntax.Comprehension) bool {
+	prev := input.Lbrack
+	for _, n := range append([]syntax.Node{input.Body}, input.Clauses...) {
+		s, e := nodeSpan(n)
+		if differentLines(s, prev) {
+			return true
+		}
+		if e.IsValid() {
+			prev = e
+		}
+	}
+	return differentLines(prev, input.Rbrack)
+}
+
+// compactInSource reports whether the sequence of simple elements was
+// written on a single line, which buildifier keeps for calls and tuples.
+func compactInSource(start syntax.Position, elems []syntax.Expr, end syntax.Position) bool {
+	if !start.IsValid() || !end.IsValid() {
+		return false
+	}
+	line := start.Line
+	for _, x := range elems {
+		if !isSimpleExpr(x) {
+			return false
+		}
+		s, e := nodeSpan(x)
+		if s.Line != line {
+			return false
+		}
+		line = e.Line
+	}
+	return end.Line == line
+}
+
+func isSimpleExpr(x syntax.Expr) bool {
+	switch t := x.(type) {
+	case *syntax.Literal, *syntax.Ident:
+		return true
+	case *syntax.UnaryExpr:
+		switch t.X.(type) {
+		case *syntax.Literal, *syntax.Ident:
+			return true
+		}
+		return false
+	case *syntax.ListExpr:
+		return len(t.List) == 0
+	case *syntax.DictExpr:
+		return len(t.List) == 0
+	case *syntax.ParenExpr:
+		tuple, ok := t.X.(*syntax.TupleExpr)
+		return ok && len(tuple.List) == 0
+	default:
+		return false
+	}
+}
+
+func differentLines(a, b syntax.Position) bool {
+	return a.Line != 0 && b.Line != 0 && a.Line != b.Line
+}
+
+// blankLines returns the number of blank lines to put before the statement
+// of the list at the index i > 0.
+func (o *outputOpts) blankLines(input []syntax.Stmt, i int) int {
+	if o.minify || o.inline {
+		return 0
+	}
+	if o.preserveBlankLines {
+		if n, ok := originalBlankLines(input[i-1], input[i]); ok {
+			if n > o.maxBlankLines {
+				n = o.maxBlankLines
+			}
+			return n
+		}
+	}
+	return o.styleBlankLines(input, i)
+}
+
+// styleBlankLines returns the number of blank lines the policy or the style
+// puts before the statement of the list at the index i > 0.
+func (o *outputOpts) styleBlankLines(input []syntax.Stmt, i int) int {
+	if o.blankLinePolicy != nil {
+		return o.blankLinePolicy.lines(input, i, o.level, o.block)
+	}
+	prev, next := input[i-1], input[i]
+	if o.style == StyleDefault {
+		if o.level == 0 {
+			return 1
+		}
+		return 0
+	}
+
+	_, prevLoad := prev.(*syntax.LoadStmt)
+	_, nextLoad := next.(*syntax.LoadStmt)
+	_, prevDef := prev.(*syntax.DefStmt)
+	_, nextDef := next.(*syntax.DefStmt)
+	switch {
+	case prevLoad && nextLoad:
+		return 0
+	case prevLoad || nextLoad:
+		return 1
+	case o.style == StyleBuildifier && o.level == 0:
+		return 1
+	case prevDef || nextDef:
+		return 1
+	}
+	_, end := nodeSpan(prev)
+	start, _ := nodeSpan(next)
+	if start.Line-end.Line > 1 {
+		return 1
+	}
+	return 0
+}
+
+// elseBlankLine reports whether there was the blank line before the else
+// or elif clause of the if statement, which buildifier keeps.
+func elseBlankLine(input *syntax.IfStmt) bool {
+	if len(input.True) == 0 || !input.ElsePos.IsValid() {
+		return false
+	}
+	_, end := nodeSpan(input.True[len(input.True)-1])
+	return end.IsValid() && input.ElsePos.Line-end.Line > 1
+}
+
+// rawLiteral returns the raw token of the literal if it represents the same
+// value, i.e. the literal was not modified after parsing.
+func rawLiteral(input *syntax.Literal) (string, bool) {
+	if input.Raw == "" || input.Value == nil {
+		return "", false
+	}
+	x, err := syntax.ParseExpr("", input.Raw, 0)
+	if err != nil {
+		return "", false
+	}
+	lt, ok := x.(*syntax.Literal)
+	if !ok {
+		return "", false
+	}
+	want, err := literalValue(input)
+	if err != nil {
+		return "", false
+	}
+	got, err := literalValue(lt)
+	if err != nil {
+		return "", false
+	}
+	switch w := want.(type) {
+	case *big.Int:
+		switch g := got.(type) {
+		case *big.Int:
+			return input.Raw, w.Cmp(g) == 0
+		case int64:
+			return input.Raw, w.IsInt64() && w.Int64() == g
+		}
+		return "", false
+	case int64:
+		if g, ok := got.(*big.Int); ok {
+			return input.Raw, g.IsInt64() && g.Int64() == w
+		}
+	}
+	return input.Raw, want == got
+}
+
+// styleLiteral writes the literal the way buildifier does: the raw token is
+// kept if it is double quoted, or the value contains the double quotes, or
+// it is a number, otherwise the value is quoted with the double quotes.
+func styleLiteral(out io.StringWriter, input *syntax.Literal) (bool, error) {
+	s, isString := input.Value.(string)
+	raw, ok := rawLiteral(input)
+	switch {
+	case ok && !isString:
+	case ok && strings.HasPrefix(raw, "r"):
+		if strings.HasSuffix(raw, "'") && !strings.Contains(s, `"`) {
+			if strings.HasSuffix(raw, "'''") {
+				raw = `r"""` + raw[4:len(raw)-3] + `"""`
+			} else {
+				raw = `r"` + raw[2:len(raw)-1] + `"`
+			}
+		}
+	case ok && (strings.HasPrefix(raw, `"`) || strings.Contains(s, `"`)):
+	case isString:
+		raw = buildifierQuote(s)
+	default:
+		return false, nil
+	}
+	if _, err := out.WriteString(raw); err != nil {
+		return true, fmt.Errorf("rendering literal raw value: %w", err)
+	}
+	return true, nil
+}
+
+// buildifierQuote quotes the string the same way buildifier does, escaping
+// all the control and non-ASCII bytes.
+func buildifierQuote(s string) string {
+	const octal = "01234567"
+	var sb strings.Builder
+	sb.WriteString(quote)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '\a':
+			sb.WriteString(`\a`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\v':
+			sb.WriteString(`\v`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '"':
+			sb.WriteString(`\"`)
+		default:
+			if c < 0x20 || c >= 0x80 {
+				sb.WriteByte('\\')
+				sb.WriteByte(octal[c>>6])
+				sb.WriteByte(octal[(c>>3)&7])
+				sb.WriteByte(octal[c&7])
+				continue
+			}
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteString(quote)
+	return sb.String()
+}
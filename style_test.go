@@ -0,0 +1,140 @@
+package starlarkgen
+
+import (
+	"strings"
+	"testing"
+
+	"go.starlark.net/syntax"
+)
+
+func TestWithStyle(t *testing.T) {
+	var (
+		str = func(s string) *syntax.Literal { return &syntax.Literal{Token: syntax.STRING, Value: s} }
+		kw  = func(name string, value syntax.Expr) *syntax.BinaryExpr {
+			return &syntax.BinaryExpr{X: &syntax.Ident{Name: name}, Op: syntax.EQ, Y: value}
+		}
+		call = func(fn string, args ...syntax.Expr) *syntax.ExprStmt {
+			// the dotted names are the chains of the dot expressions
+			parts := strings.Split(fn, ".")
+			var x syntax.Expr = &syntax.Ident{Name: parts[0]}
+			for _, p := range parts[1:] {
+				x = &syntax.DotExpr{X: x, Name: &syntax.Ident{Name: p}}
+			}
+			return &syntax.ExprStmt{X: &syntax.CallExpr{Fn: x, Args: args}}
+		}
+		list = func(elems ...syntax.Expr) *syntax.ListExpr { return &syntax.ListExpr{List: elems} }
+		def  = &syntax.DefStmt{
+			Name:   &syntax.Ident{Name: "foo"},
+			Params: []syntax.Expr{&syntax.Ident{Name: "name"}, &syntax.UnaryExpr{Op: syntax.STARSTAR, X: &syntax.Ident{Name: "kwargs"}}},
+			Body: []syntax.Stmt{
+				call("native.filegroup", kw("name", &syntax.Ident{Name: "name"}), kw("srcs", list(str("a"), str("b")))),
+				&syntax.ReturnStmt{},
+			},
+		}
+	)
+
+	tests := []struct {
+		name  string
+		style Style
+		input *syntax.File
+		want  string
+	}{
+		{
+			name:  "BUILD file",
+			style: StyleBuildifier,
+			input: &syntax.File{Stmts: []syntax.Stmt{
+				&syntax.LoadStmt{Module: str("//a:b.bzl"), From: []*syntax.Ident{{Name: "x"}, {Name: "y"}}, To: []*syntax.Ident{{Name: "x"}, {Name: "z"}}},
+				&syntax.LoadStmt{Module: str("//c:d.bzl"), From: []*syntax.Ident{{Name: "w"}}, To: []*syntax.Ident{{Name: "w"}}},
+				call("filegroup", kw("name", str("empty"))),
+				call("genrule", kw("name", str("gen")), kw("outs", list(str("é.h"))), kw("cmd", str("echo \"\\n\" > $@"))),
+				call("foo", &syntax.UnaryExpr{Op: syntax.STAR, X: &syntax.Ident{Name: "args"}}, &syntax.UnaryExpr{Op: syntax.STARSTAR, X: &syntax.Ident{Name: "kwargs"}}),
+				&syntax.AssignStmt{LHS: &syntax.Ident{Name: "X"}, Op: syntax.EQ, RHS: &syntax.ParenExpr{X: &syntax.TupleExpr{List: []syntax.Expr{str("a")}}}},
+				&syntax.AssignStmt{LHS: &syntax.Ident{Name: "Y"}, Op: syntax.EQ, RHS: list(str("a"), str("b"))},
+			}},
+			want: `load("//a:b.bzl", "x", z = "y")
+load("//c:d.bzl", "w")
+
+filegroup(name = "empty")
+
+genrule(
+    name = "gen",
+    outs = ["\303\251.h"],
+    cmd = "echo \"\\n\" > $@",
+)
+
+foo(
+    *args,
+    **kwargs
+)
+
+X = ("a",)
+
+Y = [
+    "a",
+    "b",
+]
+`,
+		},
+		{
+			name:  "bzl file",
+			style: StyleBuildifierBzl,
+			input: &syntax.File{Stmts: []syntax.Stmt{
+				&syntax.ExprStmt{X: str("Docstring.")},
+				&syntax.AssignStmt{LHS: &syntax.Ident{Name: "X"}, Op: syntax.EQ, RHS: list(str("a"), str("b"))},
+				&syntax.AssignStmt{LHS: &syntax.Ident{Name: "Y"}, Op: syntax.EQ, RHS: &syntax.Literal{Token: syntax.INT, Value: int64(16), Raw: "0x10"}},
+				&syntax.AssignStmt{LHS: &syntax.Ident{Name: "Z"}, Op: syntax.EQ, RHS: &syntax.Literal{Token: syntax.STRING, Value: "b", Raw: `'a'`}},
+				def,
+			}},
+			want: `"""Docstring."""
+X = ["a", "b"]
+Y = 0x10
+Z = "b"
+
+def foo(name, **kwargs):
+    native.filegroup(name = name, srcs = ["a", "b"])
+    return
+`,
+		},
+		{
+			name:  "def body in BUILD file",
+			style: StyleBuildifier,
+			input: &syntax.File{Stmts: []syntax.Stmt{def}},
+			want: `def foo(name, **kwargs):
+    native.filegroup(name = name, srcs = ["a", "b"])
+    return
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := StarlarkFile(tt.input, WithStyle(tt.style))
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("want:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWithStyle_invalid(t *testing.T) {
+	const want = "invalid style value 3"
+	if _, err := StarlarkExpr(&syntax.Ident{Name: "foo"}, WithStyle(styleMax)); err == nil || err.Error() != want {
+		t.Errorf("expected error %q, got %v", want, err)
+	}
+}
+
+func TestStarlarkFile(t *testing.T) {
+	f, err := syntax.Parse("test.star", "load(\"a\", \"b\")\nx = 1\ndef foo():\n    y = 2\n    return y\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "load(\"a\", \"b\")\n\nx = 1\n\ndef foo():\n    y = 2\n    return y\n"
+	if got, err := StarlarkFile(f); err != nil || got != want {
+		t.Errorf("expected nil error and %q, got %v and %q", want, err, got)
+	}
+	if _, err := StarlarkFile(nil); err == nil || err.Error() != "rendering file: nil input" {
+		t.Errorf("expected nil input error, got %v", err)
+	}
+}
@@ -0,0 +1,500 @@
+package starlarkgen
+
+import (
+	"sort"
+	"strings"
+
+	"go.starlark.net/syntax"
+)
+
+// styleRewrites holds the buildifier sorting rewrites applied when rendering
+// with the buildifier styles: the named arguments of the calls in BUILD
+// files (callsort), the label lists (listsort) and the load arguments
+// (loadsort). The input syntax tree is not modified.
+type styleRewrites struct {
+	args  map[*syntax.CallExpr][]syntax.Expr
+	lists map[*syntax.ListExpr][]syntax.Expr
+	loads map[*syntax.LoadStmt][]int
+}
+
+// sortableArgs are the named arguments of the calls in BUILD files which
+// values are the sorted lists of strings.
+var sortableArgs = map[string]bool{
+	"cc_deps": true, "common_deps": true, "compile_deps": true, "configs": true,
+	"constraints": true, "data": true, "default_visibility": true, "deps": true,
+	"deps_java": true, "exported_deps": true, "exports": true, "filegroups": true,
+	"files": true, "hdrs": true, "implementation_deps": true, "imports": true,
+	"includes": true, "inherits": true, "javadeps": true, "lib_deps": true,
+	"module_deps": true, "outs": true, "packages": true, "plugin_modules": true,
+	"private_deps": true, "proto_deps": true, "protos": true, "pubs": true,
+	"resources": true, "runtime_deps": true, "shared_deps": true, "similar_deps": true,
+	"srcs": true, "swigdeps": true, "swig_includes": true, "tags": true,
+	"test_data": true, "test_deps": true, "test_srcs": true, "test_tags": true,
+	"tests": true, "tools": true, "to_start_extensions": true, "visibility": true,
+}
+
+// unsortableArgs are the sortable named arguments of the specific rules,
+// which order matters.
+var unsortableArgs = map[string]bool{
+	"genrule.outs":       true,
+	"genrule.srcs":       true,
+	"cc_embed_data.srcs": true,
+}
+
+// argPriority orders the named arguments of the calls in BUILD files, the
+// arguments not listed have the zero priority.
+var argPriority = map[string]int{
+	"name":              -99,
+	"gwt_name":          -98,
+	"package_name":      -97,
+	"visible_node_name": -96,
+	"size":              -95,
+	"timeout":           -94,
+	"testonly":          -93,
+	"src":               -92,
+	"srcdir":            -91,
+	"srcs":              -90,
+	"out":               -89,
+	"outs":              -88,
+	"hdrs":              -87,
+	"has_services":      -86,
+	"include":           -85,
+	"of":                -84,
+	"baseline":          -83,
+	"destdir":           1,
+	"exports":           2,
+	"runtime_deps":      3,
+	"deps":              4,
+	"implementation":    5,
+	"implements":        6,
+	"alwayslink":        7,
+}
+
+// newStyleRewrites computes the rewrites of the syntax tree, the comments
+// of the sorted lists are moved in the comment map the way buildifier does.
+func newStyleRewrites(root syntax.Node, style Style, cm *commentMap) *styleRewrites {
+	r := &styleRewrites{
+		args:  make(map[*syntax.CallExpr][]syntax.Expr),
+		lists: make(map[*syntax.ListExpr][]syntax.Expr),
+		loads: make(map[*syntax.LoadStmt][]int),
+	}
+	var stack []syntax.Node
+	syntax.Walk(root, func(n syntax.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		stack = append(stack, n)
+		switch t := n.(type) {
+		case *syntax.CallExpr:
+			if leaveAlone(cm, stack) {
+				break
+			}
+			if style == StyleBuildifier {
+				r.sortCallArgs(t)
+				r.sortArgLists(t, cm)
+			} else if id, ok := t.Fn.(*syntax.Ident); ok && id.Name == "visibility" {
+				for _, arg := range t.Args {
+					r.sortStrings(arg, cm)
+				}
+			}
+		case *syntax.AssignStmt:
+			if t.Op == syntax.EQ && hasComment(cm, t, "keep sorted") {
+				r.sortStrings(t.RHS, cm)
+			}
+		case *syntax.BinaryExpr:
+			if t.Op == syntax.EQ && hasComment(cm, t, "keep sorted") {
+				r.sortStrings(t.Y, cm)
+			}
+		case *syntax.DictEntry:
+			if hasComment(cm, t, "keep sorted") {
+				r.sortStrings(t.Value, cm)
+			}
+		case *syntax.ListExpr:
+			if len(t.List) > 0 && (hasComment(cm, t, "keep sorted") || hasComment(cm, t.List[0], "keep sorted")) {
+				r.sortStrings(t, cm)
+			}
+		case *syntax.LoadStmt:
+			r.sortLoad(t)
+		}
+		return true
+	})
+	return r
+}
+
+// hasComment reports whether any of the comments placed on the node contain
+// the text, case-insensitive.
+func hasComment(cm *commentMap, n syntax.Node, text string) bool {
+	if cm == nil {
+		return false
+	}
+	for _, list := range [][]syntax.Comment{cm.before[n], cm.after[n], cm.suffix[n]} {
+		for _, c := range list {
+			if strings.Contains(strings.ToLower(c.Text), text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// leaveAlone reports whether any of the nodes has the "buildifier:
+// leave-alone" comment, which disables the rewrites.
+func leaveAlone(cm *commentMap, nodes []syntax.Node) bool {
+	for _, n := range nodes {
+		if hasComment(cm, n, "buildifier: leave-alone") {
+			return true
+		}
+	}
+	return false
+}
+
+// callName returns the dotted name of the function called, or "".
+func callName(call *syntax.CallExpr) string {
+	var names []string
+	x := call.Fn
+	for {
+		dot, ok := x.(*syntax.DotExpr)
+		if !ok {
+			break
+		}
+		names = append([]string{dot.Name.Name}, names...)
+		x = dot.X
+	}
+	id, ok := x.(*syntax.Ident)
+	if !ok {
+		return ""
+	}
+	return strings.Join(append([]string{id.Name}, names...), ".")
+}
+
+// argName returns the name of the named argument, or "".
+func argName(x syntax.Expr) string {
+	if bx, ok := x.(*syntax.BinaryExpr); ok && bx.Op == syntax.EQ {
+		if id, ok := bx.X.(*syntax.Ident); ok {
+			return id.Name
+		}
+	}
+	return ""
+}
+
+// sortCallArgs sorts the trailing named arguments of the call by
+// the priority and the name.
+func (r *styleRewrites) sortCallArgs(call *syntax.CallExpr) {
+	rule := callName(call)
+	if rule == "" {
+		rule = "<complex rule kind>"
+	}
+	start := len(call.Args)
+	for start > 0 && argName(call.Args[start-1]) != "" {
+		start--
+	}
+	named := call.Args[start:]
+	priority := func(x syntax.Expr) int {
+		name := argName(x)
+		if p, ok := argPriority[rule+"."+name]; ok {
+			return p
+		}
+		return argPriority[name]
+	}
+	less := func(a, b syntax.Expr) bool {
+		if pa, pb := priority(a), priority(b); pa != pb {
+			return pa < pb
+		}
+		return argName(a) < argName(b)
+	}
+	if sort.SliceIsSorted(named, func(i, j int) bool { return less(named[i], named[j]) }) {
+		return
+	}
+	args := append([]syntax.Expr(nil), call.Args...)
+	sorted := args[start:]
+	sort.SliceStable(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+	r.args[call] = args
+}
+
+// sortArgLists sorts the lists of strings of the sortable named arguments
+// of the call, or deduplicates them if they have the "do not sort" comment.
+func (r *styleRewrites) sortArgLists(call *syntax.CallExpr, cm *commentMap) {
+	rule := callName(call)
+	for _, arg := range call.Args {
+		name := argName(arg)
+		if name == "" || !sortableArgs[name] || unsortableArgs[rule+"."+name] {
+			continue
+		}
+		kw := arg.(*syntax.BinaryExpr)
+		if hasComment(cm, kw, "buildifier: leave-alone") {
+			continue
+		}
+		if hasComment(cm, kw, "do not sort") {
+			if list, ok := kw.Y.(*syntax.ListExpr); ok {
+				r.lists[list] = dedupStrings(r.elems(list), cm)
+			}
+			continue
+		}
+		r.sortStrings(kw.Y, cm)
+	}
+}
+
+// elems returns the list elements, rewritten if already sorted.
+func (r *styleRewrites) elems(list *syntax.ListExpr) []syntax.Expr {
+	if elems, ok := r.lists[list]; ok {
+		return elems
+	}
+	return list.List
+}
+
+// sortStrings sorts the lists of strings of the expression: the list
+// itself, the operands of the concatenation and the values of the select
+// dict.
+func (r *styleRewrites) sortStrings(x syntax.Expr, cm *commentMap) {
+	switch t := x.(type) {
+	case *syntax.ListExpr:
+		r.sortList(t, cm)
+	case *syntax.BinaryExpr:
+		if t.Op == syntax.PLUS {
+			r.sortStrings(t.X, cm)
+			r.sortStrings(t.Y, cm)
+		}
+	case *syntax.CallExpr:
+		if id, ok := t.Fn.(*syntax.Ident); !ok || id.Name != "select" || len(t.Args) == 0 {
+			return
+		}
+		if dict, ok := t.Args[0].(*syntax.DictExpr); ok {
+			for _, e := range dict.List {
+				if entry, ok := e.(*syntax.DictEntry); ok {
+					r.sortStrings(entry.Value, cm)
+				}
+			}
+		}
+	}
+}
+
+// sortList sorts the list of strings, unless it has the "do not sort"
+// comment on the first element, or the line comments without the "keep
+// sorted" one, which only deduplicate it.
+func (r *styleRewrites) sortList(list *syntax.ListExpr, cm *commentMap) {
+	elems := r.elems(list)
+	if len(elems) < 2 {
+		return
+	}
+	if hasComment(cm, elems[0], "do not sort") {
+		r.lists[list] = dedupStrings(elems, cm)
+		return
+	}
+	if !hasComment(cm, list, "keep sorted") && !hasComment(cm, elems[0], "keep sorted") && lineComments(cm, list, elems) {
+		r.lists[list] = dedupStrings(elems, cm)
+		return
+	}
+	r.lists[list] = sortStringChunks(elems, cm)
+}
+
+// lineComments reports whether the list or its elements have the line
+// comments.
+func lineComments(cm *commentMap, list *syntax.ListExpr, elems []syntax.Expr) bool {
+	if cm == nil {
+		return false
+	}
+	if len(cm.before[list])+len(cm.after[list])+len(cm.end[list]) > 0 {
+		return true
+	}
+	for _, x := range elems {
+		if len(cm.before[x]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func stringValue(x syntax.Expr) (string, bool) {
+	lt, ok := x.(*syntax.Literal)
+	if !ok {
+		return "", false
+	}
+	s, ok := lt.Value.(string)
+	return s, ok
+}
+
+// dedupStrings removes the repeated strings keeping the order, the line
+// comments of the removed ones are moved to the next string.
+func dedupStrings(elems []syntax.Expr, cm *commentMap) []syntax.Expr {
+	var (
+		res      []syntax.Expr
+		comments []syntax.Comment
+		seen     = make(map[string]bool)
+	)
+	for _, x := range elems {
+		s, ok := stringValue(x)
+		if !ok {
+			res = append(res, x)
+			continue
+		}
+		if seen[s] {
+			if cm != nil {
+				comments = append(comments, cm.before[x]...)
+				comments = append(comments, cm.after[x]...)
+			}
+			continue
+		}
+		seen[s] = true
+		if len(comments) > 0 {
+			cm.before[x] = append(comments, cm.before[x]...)
+			comments = nil
+		}
+		res = append(res, x)
+	}
+	return res
+}
+
+// stringKey is the buildifier sort key of the string: the strings are
+// grouped as the relative labels, the labels starting with ":", "//" and
+// "@", then compared by the parts split at "." and ":", then by the value.
+type stringKey struct {
+	phase int
+	split []string
+	value string
+}
+
+func makeStringKey(s string) stringKey {
+	key := stringKey{value: s}
+	switch {
+	case strings.HasPrefix(s, ":"):
+		key.phase = 1
+	case strings.HasPrefix(s, "//"):
+		key.phase = 2
+	case strings.HasPrefix(s, "@"):
+		key.phase = 3
+	}
+	key.split = strings.Split(strings.Replace(s, ":", ".", -1), ".")
+	return key
+}
+
+func (a stringKey) less(b stringKey) bool {
+	if a.phase != b.phase {
+		return a.phase < b.phase
+	}
+	for k := 0; k < len(a.split) && k < len(b.split); k++ {
+		if a.split[k] != b.split[k] {
+			return a.split[k] < b.split[k]
+		}
+	}
+	if len(a.split) != len(b.split) {
+		return len(a.split) < len(b.split)
+	}
+	return a.value < b.value
+}
+
+// sortStringChunks sorts and deduplicates the chunks of the strings split
+// by the other elements and the elements with the line comments before,
+// the comments before the chunk are kept before it.
+func sortStringChunks(elems []syntax.Expr, cm *commentMap) []syntax.Expr {
+	var res []syntax.Expr
+	for i := 0; i < len(elems); {
+		if _, ok := stringValue(elems[i]); !ok {
+			res = append(res, elems[i])
+			i++
+			continue
+		}
+		j := i + 1
+		for ; j < len(elems); j++ {
+			if _, ok := stringValue(elems[j]); !ok || cm.commented(elems[j], false) {
+				break
+			}
+		}
+		chunk := append([]syntax.Expr(nil), elems[i:j]...)
+		keys := make(map[syntax.Expr]stringKey, len(chunk))
+		for _, x := range chunk {
+			s, _ := stringValue(x)
+			keys[x] = makeStringKey(s)
+		}
+		less := func(a, b int) bool { return keys[chunk[a]].less(keys[chunk[b]]) }
+		sort.SliceStable(chunk, less)
+		uniq := chunk[:1]
+		for _, x := range chunk[1:] {
+			if keys[x].value != keys[uniq[len(uniq)-1]].value {
+				uniq = append(uniq, x)
+			}
+		}
+		if first := elems[i]; uniq[0] != first && cm != nil {
+			cm.before[uniq[0]], cm.before[first] = cm.before[first], nil
+		}
+		res = append(res, uniq...)
+		i = j
+	}
+	return res
+}
+
+// sortLoad sorts the load arguments by the local name, the ones loaded
+// under the original name first, and removes the repeated names, the last
+// one is kept.
+func (r *styleRewrites) sortLoad(ld *syntax.LoadStmt) {
+	if len(ld.From) != len(ld.To) {
+		return
+	}
+	order := make([]int, len(ld.From))
+	for i := range order {
+		order[i] = i
+	}
+	name := func(i int) string {
+		if ld.To[i] == nil {
+			return ld.From[i].Name
+		}
+		return ld.To[i].Name
+	}
+	less := func(a, b int) bool {
+		i, j := order[a], order[b]
+		plainI, plainJ := name(i) == ld.From[i].Name, name(j) == ld.From[j].Name
+		if plainI != plainJ {
+			return plainI
+		}
+		return name(i) < name(j)
+	}
+	sort.SliceStable(order, less)
+	res := order[:0]
+	for _, i := range order {
+		if len(res) > 0 && name(res[len(res)-1]) == name(i) {
+			res[len(res)-1] = i
+			continue
+		}
+		res = append(res, i)
+	}
+	for k, i := range res {
+		if k != i || len(res) != len(ld.From) {
+			r.loads[ld] = res
+			return
+		}
+	}
+}
+
+// callArgs returns the arguments of the call to render.
+func (r *styleRewrites) callArgs(call *syntax.CallExpr) []syntax.Expr {
+	if r != nil {
+		if args, ok := r.args[call]; ok {
+			return args
+		}
+	}
+	return call.Args
+}
+
+// listElems returns the elements of the list to render.
+func (r *styleRewrites) listElems(list *syntax.ListExpr) []syntax.Expr {
+	if r != nil {
+		if elems, ok := r.lists[list]; ok {
+			return elems
+		}
+	}
+	return list.List
+}
+
+// loadOrder returns the indices of the load arguments to render.
+func (r *styleRewrites) loadOrder(ld *syntax.LoadStmt) []int {
+	if r != nil {
+		if order, ok := r.loads[ld]; ok {
+			return order
+		}
+	}
+	order := make([]int, len(ld.From))
+	for i := range order {
+		order[i] = i
+	}
+	return order
+}
@@ -0,0 +1,169 @@
+package starlarkgen
+
+import (
+	"testing"
+
+	"go.starlark.net/syntax"
+)
+
+func TestStyleRewrites(t *testing.T) {
+	tests := []struct {
+		name  string
+		style Style
+		src   string
+		want  string
+	}{
+		{
+			name:  "call arguments",
+			style: StyleBuildifier,
+			src:   `java_library("pos", deps = [], exports = [], name = "x", licenses = [], testonly = True)`,
+			want: `java_library(
+    "pos",
+    name = "x",
+    testonly = True,
+    licenses = [],
+    exports = [],
+    deps = [],
+)
+`,
+		},
+		{
+			name:  "sortable lists",
+			style: StyleBuildifier,
+			src:   `x(name = "x", srcs = ["b", "a", "b"], deps = ["@r//a", "//b", ":c", "d", "b.c"], copts = ["-z", "-a"])`,
+			want: `x(
+    name = "x",
+    srcs = [
+        "a",
+        "b",
+    ],
+    copts = [
+        "-z",
+        "-a",
+    ],
+    deps = [
+        "b.c",
+        "d",
+        ":c",
+        "//b",
+        "@r//a",
+    ],
+)
+`,
+		},
+		{
+			name:  "denylisted arguments",
+			style: StyleBuildifier,
+			src:   `genrule(name = "g", srcs = ["b", "a"], outs = ["d", "c"], tools = ["f", "e"])`,
+			want: `genrule(
+    name = "g",
+    srcs = [
+        "b",
+        "a",
+    ],
+    outs = [
+        "d",
+        "c",
+    ],
+    tools = [
+        "e",
+        "f",
+    ],
+)
+`,
+		},
+		{
+			name:  "bzl files",
+			style: StyleBuildifierBzl,
+			src: `x(deps = ["b", "a"], name = "x")
+visibility(["//b", "//a"])
+`,
+			want: `x(deps = ["b", "a"], name = "x")
+visibility(["//a", "//b"])
+`,
+		},
+		{
+			name:  "keep sorted",
+			style: StyleBuildifierBzl,
+			src: `X = ["b", "a"]  # keep sorted
+`,
+			want: `X = ["a", "b"]  # keep sorted
+`,
+		},
+		{
+			name:  "do not sort",
+			style: StyleBuildifier,
+			src: `x(
+    name = "x",
+    deps = ["b", "a", "b"],  # do not sort
+)
+`,
+			want: `x(
+    name = "x",
+    deps = [
+        "b",
+        "a",
+    ],  # do not sort
+)
+`,
+		},
+		{
+			name:  "leave alone",
+			style: StyleBuildifier,
+			src: `x(deps = ["b", "a"], name = "x")  # buildifier: leave-alone
+`,
+			want: `x(
+    deps = [
+        "b",
+        "a",
+    ],
+    name = "x",
+)  # buildifier: leave-alone
+`,
+		},
+		{
+			name:  "load symbols",
+			style: StyleBuildifierBzl,
+			src: `load(":a.bzl", "z", y = "w", "a", a = "b")
+`,
+			want: `load(":a.bzl", "a", "z", a = "b", y = "w")
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := syntax.Parse("test", tt.src, syntax.RetainComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := StarlarkFile(f, WithStyle(tt.style))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("want\n%s\ngot\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStyleRewrites_input(t *testing.T) {
+	list := &syntax.ListExpr{List: []syntax.Expr{
+		&syntax.Literal{Token: syntax.STRING, Value: "b"},
+		&syntax.Literal{Token: syntax.STRING, Value: "a"},
+	}}
+	call := &syntax.CallExpr{Fn: &syntax.Ident{Name: "x"}, Args: []syntax.Expr{
+		&syntax.BinaryExpr{X: &syntax.Ident{Name: "deps"}, Op: syntax.EQ, Y: list},
+		&syntax.BinaryExpr{X: &syntax.Ident{Name: "name"}, Op: syntax.EQ, Y: &syntax.Literal{Token: syntax.STRING, Value: "x"}},
+	}}
+	if _, err := StarlarkExpr(call, WithStyle(StyleBuildifier)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the rewrites are applied to the output only
+	if got := list.List[0].(*syntax.Literal).Value; got != "b" {
+		t.Errorf("the list was modified, first element %q", got)
+	}
+	if got := call.Args[0].(*syntax.BinaryExpr).X.(*syntax.Ident).Name; got != "deps" {
+		t.Errorf("the call was modified, first argument %q", got)
+	}
+}
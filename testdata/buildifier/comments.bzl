@@ -0,0 +1,64 @@
+"""Module docstring."""
+
+load(":a.bzl", "x")
+load(":b.bzl", "a", "z")  # sorted loads
+
+# Constant block.
+FOO = [
+    # keep sorted
+    "a",
+    "b",
+    "c",
+]
+
+BAR = {
+    # the dict
+    "a": 1,  # one
+    # two
+    "b": 2,
+}
+
+def f(
+        x,  # the x
+        # the y
+        y):
+    """Doc."""
+
+    # leading comment
+    if x:  # if x
+        return y  # return
+        # end of if body
+
+    elif y:
+        pass
+    else:  # else comment
+        pass
+
+    # comment after if
+
+    for i in x:
+        # inside for
+        y += i
+
+    # dedented after for
+    return [
+        e
+        for e in x  # each
+        # trailing in comprehension
+    ]
+
+def g(a, b):  # g comment
+    # first
+    a()
+
+    # second
+    b()
+    # after b
+
+visibility(["//a/...", "//z/..."])
+
+X = foo(
+    a,  # a
+    b,
+)
+# file end
@@ -0,0 +1,36 @@
+load("//a:b.bzl", "x", "y", "z")
+load(
+    "//c:d.bzl",
+    "w",
+)
+
+X = (1, 2)
+Y = (1,)
+Z = {k: v for k, v in [("a", 1)]}
+
+def f(x, *, y = 1):
+    if x:
+        return 1
+    elif y:
+        return 2
+    elif x and y:
+        pass
+    for i in range(10):
+        if i % 2 == 0:
+            continue
+        break
+    a = x[1:2]
+    b = x[::2]
+    c = "é\t"
+    d = -x + ~y
+    e = [i for i in x if i]
+    f = x if y else None
+    a += 1
+    return a, b
+
+def g():
+    """Single line doc."""
+    return {"a": [
+        1,
+        2,
+    ]}
@@ -0,0 +1,56 @@
+"""Macros for the tests."""
+
+load("@rules_cc//cc:defs.bzl", "cc_test")
+load(":providers.bzl", "InfoProvider", _impl = "implementation")
+
+_DEFAULT_COPTS = ["-Wall", "-Werror"]
+
+_PLATFORMS = {
+    "linux": "@platforms//os:linux",
+    "macos": "@platforms//os:macos",
+}
+
+def _join(parts, sep = "/"):
+    return sep.join([p for p in parts if p])
+
+def cc_tests(name, srcs, deps = [], copts = None, **kwargs):
+    """Declares a test per source file.
+
+    Args:
+        name: the test suite name.
+        srcs: the test sources.
+    """
+    if copts == None:
+        copts = _DEFAULT_COPTS
+    elif type(copts) != "list":
+        fail("copts must be a list, got %s" % type(copts))
+    else:
+        copts = copts + _DEFAULT_COPTS
+    tests = []
+    for src in srcs:
+        test_name = src[:-3]
+        cc_test(
+            name = test_name,
+            srcs = [src],
+            deps = deps,
+            copts = copts,
+            **kwargs
+        )
+
+        tests.append(":" + test_name)
+    native.test_suite(name = name, tests = tests)
+
+def _impl_helper(ctx):
+    x, y = 1, 2
+    t = (x,)
+    if not ctx.attr.enabled and x < y:
+        return None
+    return struct(files = depset(ctx.files.srcs), value = -x, ratio = 0x10, s = 'it\'s "quoted"')
+
+def long_signature(
+        first,
+        second,
+        *args,
+        third = True,
+        **kwargs):
+    pass
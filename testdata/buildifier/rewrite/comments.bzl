@@ -0,0 +1,85 @@
+load("@a//:a.bzl", "a")
+load(":c.bzl", "c")
+
+# keep sorted
+NAMES = ["c", "a", "b"]
+
+EMPTY = [
+    # nothing here
+]
+
+CALL = foo(
+    # first arg
+
+    x,
+
+    # between
+
+    y = [1, 2],  # kw
+    # end of call
+)
+
+def h(
+        a,
+        b,
+        # trailing params
+):
+    for a in b:  # loop
+        a -= 1
+
+        # spaced
+        b += 1
+    if a:
+        pass
+    # before else
+    else:
+        pass
+
+    if b:
+        pass
+
+    elif a:  # elif cond
+        pass
+    return {
+        "k": [  # list open
+            1,
+        ],
+    }
+
+def empty():
+    pass
+    # end of empty
+
+# between defs
+def last():
+    x = (  # paren
+        1 + 2
+    )
+    return x
+
+def f(x):
+    return [
+        y  # y
+        for y in x
+        if y
+    ]
+
+def g():
+    return foo(
+        a,
+        # before b
+        b,
+    )  # closing
+
+def k(
+        a,  # a
+        b):
+    pass
+
+V = [1, 2]  # v
+
+# keep sorted
+W = [
+    "b",
+    "a",
+]
@@ -0,0 +1,39 @@
+# before docstring
+"""Doc."""
+
+def outer():
+    def inner():  # inner
+        pass
+
+        # end of inner
+
+    # after inner
+    if a:
+        if b:
+            pass
+            # deep
+        # mid
+    # shallow
+    elif c:
+        pass
+    else:
+        # in else
+        pass
+    t = (
+        1,  # one
+        2,
+    )
+    d = {"a": 1}  # d
+    return t
+
+X = [  # open
+    "a",
+]
+
+Y = [
+    "a",  # a
+
+
+    # spaced twice
+    "b",
+]
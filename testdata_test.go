@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -35,24 +37,90 @@ func Test_testdata(t *testing.T) {
 				t.Fatal("error parsing test file", err)
 			}
 
-			var sb strings.Builder
-			sep := ""
-			for _, s := range f.Stmts {
-				sb.WriteString(sep)
-				d, err := StarlarkStmt(s, opts...)
-				if err != nil {
-					t.Fatal("error processing statement", err)
-				}
-				sb.WriteString(d)
-				sep = "\n"
+			got, err := StarlarkFile(f, opts...)
+			if err != nil {
+				t.Fatal("error processing file", err)
 			}
-			if got := sb.String(); want != got {
+			if want != got {
 				t.Errorf("output mismatch, want %q, got %q", want, got)
 			}
 		})
 	}
 }
 
+// Test_buildifierGolden re-renders the buildifier formatted files, which
+// have to be reproduced exactly.
+func Test_buildifierGolden(t *testing.T) {
+	files, err := filepath.Glob("testdata/buildifier/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no golden files found")
+	}
+	for _, sf := range files {
+		if fi, err := os.Stat(sf); err != nil || fi.IsDir() {
+			continue
+		}
+		t.Run(sf, func(t *testing.T) {
+			tf, err := ioutil.ReadFile(sf)
+			if err != nil {
+				t.Fatal("error reading test file", err)
+			}
+			if got, want := buildifierRender(t, sf, tf), string(tf); want != got {
+				t.Errorf("output mismatch, want:\n%s\ngot:\n%s", want, got)
+			}
+		})
+	}
+}
+
+// Test_buildifierRewrite renders the files with the buildifier styles,
+// which have to produce the buildifier output of them, the .golden files.
+func Test_buildifierRewrite(t *testing.T) {
+	files, err := filepath.Glob("testdata/buildifier/rewrite/*.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no golden files found")
+	}
+	for _, gf := range files {
+		sf := strings.TrimSuffix(gf, ".golden")
+		t.Run(sf, func(t *testing.T) {
+			tf, err := ioutil.ReadFile(sf)
+			if err != nil {
+				t.Fatal("error reading test file", err)
+			}
+			want, err := ioutil.ReadFile(gf)
+			if err != nil {
+				t.Fatal("error reading golden file", err)
+			}
+			if got := buildifierRender(t, sf, tf); string(want) != got {
+				t.Errorf("output mismatch, want:\n%s\ngot:\n%s", want, got)
+			}
+		})
+	}
+}
+
+// buildifierRender renders the source with the comments in the buildifier
+// style of the file, StyleBuildifierBzl for .bzl files.
+func buildifierRender(t *testing.T, name string, src []byte) string {
+	t.Helper()
+	style := StyleBuildifier
+	if strings.HasSuffix(name, ".bzl") {
+		style = StyleBuildifierBzl
+	}
+	f, err := syntax.Parse(name, src, syntax.RetainComments)
+	if err != nil {
+		t.Fatal("error parsing test file", err)
+	}
+	got, err := StarlarkFile(f, WithStyle(style))
+	if err != nil {
+		t.Fatal("error rendering file", err)
+	}
+	return got
+}
+
 func Benchmark_testData(b *testing.B) {
 	var (
 		sourceMap = make(map[string]*syntax.File, len(testSources))
@@ -0,0 +1,645 @@
+package starlarkgen
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"go.starlark.net/syntax"
+)
+
+// Unmarshaler is implemented by the types which decode their Starlark
+// representation themselves, it is the counterpart of Marshaler.
+type Unmarshaler interface {
+	UnmarshalStarlark(syntax.Expr) error
+}
+
+// UnmarshalError describes an expression which cannot be decoded by Unmarshal
+// or converted by ToJSON.
+type UnmarshalError struct {
+	// Node is the expression which failed to decode.
+	Node syntax.Node
+	// Pos is the position of the expression, if known.
+	Pos syntax.Position
+	// Path describes the location of the expression in the decoded value,
+	// e.g. "deps: element 2".
+	Path string
+	// Msg describes the problem.
+	Msg string
+	// Err is the underlying error, if any, e.g. the one returned by
+	// UnmarshalStarlark.
+	Err error
+}
+
+func (e *UnmarshalError) Error() string {
+	var sb strings.Builder
+	if e.Pos.IsValid() {
+		sb.WriteString(e.Pos.String())
+		sb.WriteString(": ")
+	}
+	if e.Path != "" {
+		sb.WriteString(e.Path)
+		sb.WriteString(": ")
+	}
+	sb.WriteString(e.Msg)
+	return sb.String()
+}
+
+// Unwrap returns the underlying error.
+func (e *UnmarshalError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	ifaceType       = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+func unmarshalErrorf(node syntax.Node, format string, args ...interface{}) *UnmarshalError {
+	err := fmt.Errorf(format, args...)
+	return &UnmarshalError{Node: node, Pos: nodeStart(node), Msg: err.Error(), Err: errors.Unwrap(err)}
+}
+
+// nodeStart returns the start position of the node, or the zero position if
+// it is not known.
+func nodeStart(node syntax.Node) syntax.Position {
+	start, _ := nodeSpan(node)
+	return start
+}
+
+// nodeSpan returns the start and end positions of the node, or the zero
+// positions if they are not known. Span implementations of the nodes built
+// by hand might panic on the nil fields, those nodes have no positions anyway.
+func nodeSpan(node syntax.Node) (start, end syntax.Position) {
+	if node == nil {
+		return
+	}
+	if rv := reflect.ValueOf(node); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return
+	}
+	defer func() {
+		if recover() != nil {
+			start, end = syntax.Position{}, syntax.Position{}
+		}
+	}()
+	return node.Span()
+}
+
+// withPath prepends the path element to the path of the decoding error.
+func withPath(err error, elem string) error {
+	var ue *UnmarshalError
+	if errors.As(err, &ue) {
+		if ue.Path == "" {
+			ue.Path = elem
+		} else {
+			ue.Path = elem + ": " + ue.Path
+		}
+	}
+	return err
+}
+
+// Unmarshal decodes the pure-literal Starlark expression into the value
+// pointed to by v, without evaluating it. It is the inverse of FromGo:
+//   - string literals are decoded into strings
+//   - int and float literals, optionally negated, are decoded into
+//     the numeric types and *big.Int
+//   - True and False are decoded into bools, None sets the zero value
+//   - lists and tuples are decoded into slices and arrays
+//   - dicts are decoded into maps, or structs if the keys are strings
+//   - calls with keyword arguments only are decoded into structs or maps
+//     with string keys, the callee is checked against StarlarkCallee if
+//     the struct implements Callee
+//
+// Struct fields are matched using the same field tags FromGo uses.
+// An interface{} value receives string, int64, *big.Int, float64, bool,
+// []interface{}, Tuple, map[string]interface{} or map[interface{}]interface{}
+// values. The targets implementing Unmarshaler and syntax.Expr fields
+// receive the expression as is.
+//
+// Any other expression, such as identifiers, binary expressions or
+// comprehensions, is reported as an *UnmarshalError with the expression
+// position.
+func Unmarshal(input syntax.Expr, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("unmarshal target must be a non-nil pointer, got %T", v)
+	}
+	return decodeValue(input, rv.Elem())
+}
+
+// UnmarshalFile decodes the file made of pure-literal statements into
+// the value pointed to by v, see Unmarshal for the expression decoding rules:
+//   - if v points to a struct or a map with string keys, each statement
+//     must be a NAME = <literal> assignment, which is decoded into the field
+//     or the map key NAME
+//   - if v points to a slice, each statement must be an expression
+//     statement, e.g. a call, which is decoded into the next element
+//
+// Docstrings, i.e. string literal statements, are skipped in both cases.
+func UnmarshalFile(f *syntax.File, v interface{}) error {
+	if f == nil {
+		return errors.New("unmarshal: nil file")
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("unmarshal target must be a non-nil pointer, got %T", v)
+	}
+	target := rv.Elem()
+
+	switch target.Kind() {
+	case reflect.Slice:
+		res := reflect.MakeSlice(target.Type(), 0, len(f.Stmts))
+		for i, st := range f.Stmts {
+			es, ok := st.(*syntax.ExprStmt)
+			if !ok {
+				return withPath(unmarshalErrorf(st, "expected expression statement, got %T", st), fmt.Sprintf("statement index %d", i))
+			}
+			if isDocstring(es) {
+				continue
+			}
+			elem := reflect.New(target.Type().Elem()).Elem()
+			if err := decodeValue(es.X, elem); err != nil {
+				return withPath(err, fmt.Sprintf("statement index %d", i))
+			}
+			res = reflect.Append(res, elem)
+		}
+		target.Set(res)
+		return nil
+	case reflect.Struct, reflect.Map:
+		entries := make([]kwEntry, 0, len(f.Stmts))
+		for i, st := range f.Stmts {
+			if es, ok := st.(*syntax.ExprStmt); ok && isDocstring(es) {
+				continue
+			}
+			as, ok := st.(*syntax.AssignStmt)
+			if !ok || as.Op != syntax.EQ {
+				return withPath(unmarshalErrorf(st, "expected NAME = <literal> assignment, got %T", st), fmt.Sprintf("statement index %d", i))
+			}
+			id, ok := as.LHS.(*syntax.Ident)
+			if !ok {
+				return withPath(unmarshalErrorf(as.LHS, "expected identifier on the left side of assignment, got %T", as.LHS), fmt.Sprintf("statement index %d", i))
+			}
+			entries = append(entries, kwEntry{name: id.Name, node: as, value: as.RHS})
+		}
+		return decodeKeywords(f, entries, target)
+	default:
+		return fmt.Errorf("unmarshal file target must point to a struct, a map or a slice, got %T", v)
+	}
+}
+
+func isDocstring(es *syntax.ExprStmt) bool {
+	lt, ok := es.X.(*syntax.Literal)
+	if !ok {
+		return false
+	}
+	_, ok = lt.Value.(string)
+	return ok
+}
+
+// kwEntry is a keyword argument, a string dict key or a top-level assignment.
+type kwEntry struct {
+	name  string
+	node  syntax.Node
+	value syntax.Expr
+}
+
+func decodeValue(input syntax.Expr, v reflect.Value) error {
+	if input == nil {
+		return unmarshalErrorf(nil, "nil expression")
+	}
+
+	// allocate the nil pointers, unless None is decoded
+	if id, ok := input.(*syntax.Ident); ok && id.Name == "None" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	for v.Kind() == reflect.Ptr && v.Type() != bigIntType && !v.Type().Implements(unmarshalerType) && !v.Type().Implements(exprType) {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	if v.Type().Implements(exprType) {
+		if !reflect.TypeOf(input).AssignableTo(v.Type()) {
+			return unmarshalErrorf(input, "cannot decode %T into %s", input, v.Type())
+		}
+		v.Set(reflect.ValueOf(input))
+		return nil
+	}
+	if u, ok := unmarshaler(v); ok {
+		if err := u.UnmarshalStarlark(input); err != nil {
+			return unmarshalErrorf(input, "calling UnmarshalStarlark for %s: %w", v.Type(), err)
+		}
+		return nil
+	}
+
+	switch t := input.(type) {
+	case *syntax.ParenExpr:
+		if tuple, ok := t.X.(*syntax.TupleExpr); ok {
+			return decodeSequence(t, tuple.List, true, v)
+		}
+		return decodeValue(t.X, v)
+	case *syntax.Literal:
+		return decodeLiteral(t, false, v)
+	case *syntax.UnaryExpr:
+		lt, ok := t.X.(*syntax.Literal)
+		if !ok || (t.Op != syntax.MINUS && t.Op != syntax.PLUS) {
+			return unmarshalErrorf(t, "unsupported unary expression, only numeric literals with sign are allowed")
+		}
+		if _, ok := lt.Value.(string); ok {
+			return unmarshalErrorf(t, "unsupported unary expression, only numeric literals with sign are allowed")
+		}
+		return decodeLiteral(lt, t.Op == syntax.MINUS, v)
+	case *syntax.Ident:
+		switch t.Name {
+		case "True", "False":
+			b := reflect.ValueOf(t.Name == "True")
+			switch {
+			case v.Kind() == reflect.Bool:
+				v.SetBool(b.Bool())
+			case v.Type() == ifaceType:
+				v.Set(b)
+			default:
+				return unmarshalErrorf(t, "cannot decode %s into %s", t.Name, v.Type())
+			}
+			return nil
+		}
+		return unmarshalErrorf(t, "unsupported identifier %q, only True, False and None are allowed", t.Name)
+	case *syntax.ListExpr:
+		return decodeSequence(t, t.List, false, v)
+	case *syntax.TupleExpr:
+		return decodeSequence(t, t.List, true, v)
+	case *syntax.DictExpr:
+		return decodeDict(t, v)
+	case *syntax.CallExpr:
+		return decodeCall(t, v)
+	default:
+		return unmarshalErrorf(input, "unsupported expression %T, only literals, lists, tuples, dicts and calls are allowed", input)
+	}
+}
+
+func unmarshaler(v reflect.Value) (Unmarshaler, bool) {
+	if v.Kind() == reflect.Ptr && v.Type().Implements(unmarshalerType) {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return v.Interface().(Unmarshaler), true
+	}
+	if v.CanAddr() && reflect.PtrTo(v.Type()).Implements(unmarshalerType) {
+		return v.Addr().Interface().(Unmarshaler), true
+	}
+	return nil, false
+}
+
+// literalValue returns the Go value of the literal: string, int64, *big.Int
+// or float64.
+func literalValue(input *syntax.Literal) (interface{}, error) {
+	switch t := input.Value.(type) {
+	case string, int64, float64:
+		return t, nil
+	case int:
+		return int64(t), nil
+	case uint:
+		return new(big.Int).SetUint64(uint64(t)), nil
+	case uint64:
+		return new(big.Int).SetUint64(t), nil
+	case *big.Int:
+		if t == nil {
+			return nil, errors.New("nil literal *big.Int value provided")
+		}
+		return t, nil
+	case nil:
+		switch input.Token {
+		case syntax.INT:
+			if b, ok := new(big.Int).SetString(input.Raw, 0); ok {
+				return b, nil
+			}
+		case syntax.FLOAT:
+			if f, err := strconv.ParseFloat(input.Raw, 64); err == nil {
+				return f, nil
+			}
+		}
+		return nil, fmt.Errorf("cannot decode raw literal %q", input.Raw)
+	default:
+		return nil, fmt.Errorf("unsupported literal value type %T", t)
+	}
+}
+
+func decodeLiteral(input *syntax.Literal, negate bool, v reflect.Value) error {
+	val, err := literalValue(input)
+	if err != nil {
+		return unmarshalErrorf(input, "%w", err)
+	}
+
+	// normalize to *big.Int or float64 for the numeric values
+	var (
+		bi *big.Int
+		f  float64
+	)
+	switch t := val.(type) {
+	case string:
+		switch {
+		case v.Kind() == reflect.String:
+			v.SetString(t)
+		case v.Type() == ifaceType:
+			v.Set(reflect.ValueOf(t))
+		default:
+			return unmarshalErrorf(input, "cannot decode string into %s", v.Type())
+		}
+		return nil
+	case int64:
+		bi = big.NewInt(t)
+	case *big.Int:
+		bi = new(big.Int).Set(t)
+	case float64:
+		f = t
+	}
+	if negate {
+		if bi != nil {
+			bi.Neg(bi)
+		} else {
+			f = -f
+		}
+	}
+
+	if bi == nil {
+		switch {
+		case v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64:
+			v.SetFloat(f)
+		case v.Type() == ifaceType:
+			v.Set(reflect.ValueOf(f))
+		default:
+			return unmarshalErrorf(input, "cannot decode float into %s", v.Type())
+		}
+		return nil
+	}
+
+	switch {
+	case v.Type() == bigIntType:
+		v.Set(reflect.ValueOf(bi))
+	case v.Type() == ifaceType:
+		if bi.IsInt64() {
+			v.Set(reflect.ValueOf(bi.Int64()))
+		} else {
+			v.Set(reflect.ValueOf(bi))
+		}
+	case keyKind(v) == reflect.Int:
+		if !bi.IsInt64() || v.OverflowInt(bi.Int64()) {
+			return unmarshalErrorf(input, "value %s overflows %s", bi, v.Type())
+		}
+		v.SetInt(bi.Int64())
+	case keyKind(v) == reflect.Uint:
+		if !bi.IsUint64() || v.OverflowUint(bi.Uint64()) {
+			return unmarshalErrorf(input, "value %s overflows %s", bi, v.Type())
+		}
+		v.SetUint(bi.Uint64())
+	case keyKind(v) == reflect.Float64:
+		bf, _ := new(big.Float).SetInt(bi).Float64()
+		v.SetFloat(bf)
+	default:
+		return unmarshalErrorf(input, "cannot decode int into %s", v.Type())
+	}
+	return nil
+}
+
+func decodeSequence(input syntax.Expr, elems []syntax.Expr, tuple bool, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Slice:
+		res := reflect.MakeSlice(v.Type(), len(elems), len(elems))
+		for i, e := range elems {
+			if err := decodeValue(e, res.Index(i)); err != nil {
+				return withPath(err, fmt.Sprintf("element %d", i))
+			}
+		}
+		v.Set(res)
+		return nil
+	case reflect.Array:
+		if len(elems) != v.Len() {
+			return unmarshalErrorf(input, "cannot decode %d elements into %s", len(elems), v.Type())
+		}
+		for i, e := range elems {
+			if err := decodeValue(e, v.Index(i)); err != nil {
+				return withPath(err, fmt.Sprintf("element %d", i))
+			}
+		}
+		return nil
+	case reflect.Interface:
+		if v.Type() != ifaceType {
+			break
+		}
+		res := make([]interface{}, len(elems))
+		if err := decodeSequence(input, elems, tuple, reflect.ValueOf(&res).Elem()); err != nil {
+			return err
+		}
+		if tuple {
+			v.Set(reflect.ValueOf(Tuple(res)))
+		} else {
+			v.Set(reflect.ValueOf(res))
+		}
+		return nil
+	}
+	return unmarshalErrorf(input, "cannot decode sequence into %s", v.Type())
+}
+
+func decodeDict(input *syntax.DictExpr, v reflect.Value) error {
+	// structs and string-keyed interface{} values require string keys
+	entries := make([]kwEntry, 0, len(input.List))
+	allStrings := true
+	for _, elem := range input.List {
+		de, ok := elem.(*syntax.DictEntry)
+		if !ok {
+			return unmarshalErrorf(elem, "expected *syntax.DictEntry, got %T in dict", elem)
+		}
+		lt, ok := de.Key.(*syntax.Literal)
+		if !ok {
+			allStrings = false
+			break
+		}
+		s, ok := lt.Value.(string)
+		if !ok {
+			allStrings = false
+			break
+		}
+		entries = append(entries, kwEntry{name: s, node: de, value: de.Value})
+	}
+
+	switch {
+	case v.Kind() == reflect.Struct:
+		if !allStrings {
+			return unmarshalErrorf(input, "cannot decode dict with non-string keys into %s", v.Type())
+		}
+		return decodeKeywords(input, entries, v)
+	case v.Type() == ifaceType && allStrings:
+		m := make(map[string]interface{}, len(entries))
+		if err := decodeKeywords(input, entries, reflect.ValueOf(&m).Elem()); err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(m))
+		return nil
+	case v.Type() == ifaceType:
+		m := make(map[interface{}]interface{}, len(input.List))
+		if err := decodeMap(input, reflect.ValueOf(&m).Elem()); err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(m))
+		return nil
+	case v.Kind() == reflect.Map:
+		return decodeMap(input, v)
+	default:
+		return unmarshalErrorf(input, "cannot decode dict into %s", v.Type())
+	}
+}
+
+func decodeMap(input *syntax.DictExpr, v reflect.Value) error {
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(v.Type(), len(input.List)))
+	}
+	for i, elem := range input.List {
+		de, ok := elem.(*syntax.DictEntry)
+		if !ok {
+			return unmarshalErrorf(elem, "expected *syntax.DictEntry, got %T in dict", elem)
+		}
+		key := reflect.New(v.Type().Key()).Elem()
+		if err := decodeValue(de.Key, key); err != nil {
+			return withPath(err, fmt.Sprintf("key %d", i))
+		}
+		if key.Kind() == reflect.Interface && !key.IsNil() && !key.Elem().Type().Comparable() {
+			return withPath(unmarshalErrorf(de.Key, "unhashable key type %s", key.Elem().Type()), fmt.Sprintf("key %d", i))
+		}
+		val := reflect.New(v.Type().Elem()).Elem()
+		if err := decodeValue(de.Value, val); err != nil {
+			return withPath(err, fmt.Sprintf("value %d", i))
+		}
+		v.SetMapIndex(key, val)
+	}
+	return nil
+}
+
+// calleeName returns the dotted name of the callee, e.g. "native.genrule".
+func calleeName(fn syntax.Expr) (string, bool) {
+	switch t := fn.(type) {
+	case *syntax.Ident:
+		return t.Name, true
+	case *syntax.DotExpr:
+		x, ok := calleeName(t.X)
+		if !ok || t.Name == nil {
+			return "", false
+		}
+		return x + "." + t.Name.Name, true
+	default:
+		return "", false
+	}
+}
+
+func decodeCall(input *syntax.CallExpr, v reflect.Value) error {
+	name, ok := calleeName(input.Fn)
+	if !ok {
+		return unmarshalErrorf(input.Fn, "unsupported callee %T, expected a name", input.Fn)
+	}
+
+	entries := make([]kwEntry, 0, len(input.Args))
+	for i, arg := range input.Args {
+		be, ok := arg.(*syntax.BinaryExpr)
+		if !ok || be.Op != syntax.EQ {
+			return withPath(unmarshalErrorf(arg, "only keyword arguments are supported, got %T", arg), fmt.Sprintf("argument %d", i))
+		}
+		id, ok := be.X.(*syntax.Ident)
+		if !ok {
+			return withPath(unmarshalErrorf(be.X, "expected keyword name, got %T", be.X), fmt.Sprintf("argument %d", i))
+		}
+		entries = append(entries, kwEntry{name: id.Name, node: arg, value: be.Y})
+	}
+
+	switch {
+	case v.Kind() == reflect.Struct:
+		want := defaultCallee
+		if c, ok := callee(v); ok {
+			want = c
+		}
+		if want != name {
+			return unmarshalErrorf(input, "cannot decode %s(...) call into %s, expected %s(...)", name, v.Type(), want)
+		}
+		return decodeKeywords(input, entries, v)
+	case v.Type() == ifaceType:
+		m := make(map[string]interface{}, len(entries))
+		if err := decodeKeywords(input, entries, reflect.ValueOf(&m).Elem()); err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(m))
+		return nil
+	case v.Kind() == reflect.Map:
+		return decodeKeywords(input, entries, v)
+	default:
+		return unmarshalErrorf(input, "cannot decode %s(...) call into %s", name, v.Type())
+	}
+}
+
+// callee returns the StarlarkCallee value, if implemented by v or its address.
+func callee(v reflect.Value) (string, bool) {
+	if v.Type().Implements(calleeType) {
+		return v.Interface().(Callee).StarlarkCallee(), true
+	}
+	if v.CanAddr() && reflect.PtrTo(v.Type()).Implements(calleeType) {
+		return v.Addr().Interface().(Callee).StarlarkCallee(), true
+	}
+	return "", false
+}
+
+// decodeKeywords decodes the named values into the struct fields or
+// the string-keyed map.
+func decodeKeywords(node syntax.Node, entries []kwEntry, v reflect.Value) error {
+	if v.Kind() == reflect.Map {
+		if v.Type().Key().Kind() != reflect.String {
+			return unmarshalErrorf(node, "cannot decode named values into %s, string keys required", v.Type())
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(entries)))
+		}
+		for _, e := range entries {
+			val := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeValue(e.value, val); err != nil {
+				return withPath(err, e.name)
+			}
+			v.SetMapIndex(reflect.ValueOf(e.name).Convert(v.Type().Key()), val)
+		}
+		return nil
+	}
+
+	fields := structFields(v.Type())
+	for _, e := range entries {
+		var field *structField
+		for i := range fields {
+			if fields[i].name == e.name {
+				field = &fields[i]
+				break
+			}
+		}
+		if field == nil {
+			return unmarshalErrorf(e.node, "unknown field %q in %s", e.name, v.Type())
+		}
+		fv := v
+		for i, x := range field.index {
+			if i > 0 && fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					if !fv.CanSet() {
+						return unmarshalErrorf(e.node, "cannot set field %q in %s, embedded pointer to unexported struct %s", e.name, v.Type(), fv.Type().Elem())
+					}
+					fv.Set(reflect.New(fv.Type().Elem()))
+				}
+				fv = fv.Elem()
+			}
+			fv = fv.Field(x)
+		}
+		if !fv.CanSet() {
+			return unmarshalErrorf(e.node, "cannot set field %q in %s", e.name, v.Type())
+		}
+		if err := decodeValue(e.value, fv); err != nil {
+			return withPath(err, e.name)
+		}
+	}
+	return nil
+}
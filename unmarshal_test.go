@@ -0,0 +1,315 @@
+package starlarkgen
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"go.starlark.net/syntax"
+)
+
+type testUpperString string
+
+var errIdentExpected = errors.New("identifier expected")
+
+func (s *testUpperString) UnmarshalStarlark(x syntax.Expr) error {
+	id, ok := x.(*syntax.Ident)
+	if !ok {
+		return errIdentExpected
+	}
+	*s = testUpperString(id.Name)
+	return nil
+}
+
+func TestUnmarshal(t *testing.T) {
+	type config struct {
+		Name    string            `starlark:"name"`
+		Size    uint8             `starlark:"size"`
+		Ratio   float32           `starlark:"ratio"`
+		Tags    []string          `starlark:"tags"`
+		Pair    [2]int            `starlark:"pair"`
+		Env     map[string]string `starlark:"env"`
+		Next    *config           `starlark:"next"`
+		Any     interface{}       `starlark:"any"`
+		Raw     syntax.Expr       `starlark:"raw"`
+		Const   testUpperString   `starlark:"const"`
+		Skipped string            `starlark:"-"`
+	}
+	type inner struct{ A int }
+	type outer struct {
+		*inner
+		B int
+	}
+
+	tests := []struct {
+		name    string
+		source  string
+		target  func() interface{}
+		want    interface{}
+		wantErr string
+	}{
+		{
+			name:   "scalars into interface",
+			source: `["a", 1, -2, 1.5, -0.5, True, False, None, 99999999999999999999]`,
+			target: func() interface{} { return new(interface{}) },
+			want: func() interface{} {
+				b, _ := new(big.Int).SetString("99999999999999999999", 10)
+				var v interface{} = []interface{}{"a", int64(1), int64(-2), 1.5, -0.5, true, false, nil, b}
+				return &v
+			}(),
+		},
+		{
+			name:   "tuples and dicts into interface",
+			source: `((1, 2), {"a": 1}, {1: "b"})`,
+			target: func() interface{} { return new(interface{}) },
+			want: func() interface{} {
+				var v interface{} = Tuple{
+					Tuple{int64(1), int64(2)},
+					map[string]interface{}{"a": int64(1)},
+					map[interface{}]interface{}{int64(1): "b"},
+				}
+				return &v
+			}(),
+		},
+		{
+			name:   "call into struct",
+			source: `struct(name = "foo", size = 200, ratio = 2, tags = ["a", "b"], pair = (1, 2), env = {"A": "B"}, next = struct(name = "bar"), any = [1], raw = x + 1, const = FOO)`,
+			target: func() interface{} { return new(config) },
+			want: &config{
+				Name:  "foo",
+				Size:  200,
+				Ratio: 2,
+				Tags:  []string{"a", "b"},
+				Pair:  [2]int{1, 2},
+				Env:   map[string]string{"A": "B"},
+				Next:  &config{Name: "bar"},
+				Any:   []interface{}{int64(1)},
+				Raw: &syntax.BinaryExpr{
+					X:  &syntax.Ident{Name: "x"},
+					Op: syntax.PLUS,
+					Y:  &syntax.Literal{Token: syntax.INT, Raw: "1", Value: int64(1)},
+				},
+				Const: "FOO",
+			},
+		},
+		{
+			name:   "dict into struct, None into pointer",
+			source: `{"name": "foo", "next": None}`,
+			target: func() interface{} { return &config{Next: &config{}} },
+			want:   &config{Name: "foo"},
+		},
+		{
+			name:   "call into map",
+			source: `foo(a = 1, b = 2)`,
+			target: func() interface{} { return new(map[string]int) },
+			want:   &map[string]int{"a": 1, "b": 2},
+		},
+		{
+			name:   "call with callee into struct",
+			source: `http_archive(name = "foo", urls = ["a", "b"])`,
+			target: func() interface{} { return new(testArchive) },
+			want:   &testArchive{Name: "foo", URLs: []string{"a", "b"}},
+		},
+		{
+			name:   "big int",
+			source: `-99999999999999999999`,
+			target: func() interface{} { return new(*big.Int) },
+			want: func() interface{} {
+				b, _ := new(big.Int).SetString("-99999999999999999999", 10)
+				return &b
+			}(),
+		},
+		{
+			name:    "wrong callee",
+			source:  "\n\ngit_repository(name = \"foo\")",
+			target:  func() interface{} { return new(testArchive) },
+			wantErr: "test.star:3:1: cannot decode git_repository(...) call into starlarkgen.testArchive, expected http_archive(...)",
+		},
+		{
+			name:    "non-literal expression",
+			source:  `struct(name = "foo", tags = ["a", "b" + "c"])`,
+			target:  func() interface{} { return new(config) },
+			wantErr: "test.star:1:35: tags: element 1: unsupported expression *syntax.BinaryExpr, only literals, lists, tuples, dicts and calls are allowed",
+		},
+		{
+			name:    "identifier",
+			source:  `[x]`,
+			target:  func() interface{} { return new([]string) },
+			wantErr: `test.star:1:2: element 0: unsupported identifier "x", only True, False and None are allowed`,
+		},
+		{
+			name:    "overflow",
+			source:  `struct(size = 256)`,
+			target:  func() interface{} { return new(config) },
+			wantErr: "test.star:1:15: size: value 256 overflows uint8",
+		},
+		{
+			name:    "unknown field",
+			source:  `struct(foo = 1)`,
+			target:  func() interface{} { return new(config) },
+			wantErr: `test.star:1:8: unknown field "foo" in starlarkgen.config`,
+		},
+		{
+			name:    "embedded pointer to unexported struct",
+			source:  `struct(A = 1)`,
+			target:  func() interface{} { return new(outer) },
+			wantErr: `test.star:1:8: cannot set field "A" in starlarkgen.outer, embedded pointer to unexported struct starlarkgen.inner`,
+		},
+		{
+			name:    "positional argument",
+			source:  `struct(1)`,
+			target:  func() interface{} { return new(config) },
+			wantErr: "test.star:1:8: argument 0: only keyword arguments are supported, got *syntax.Literal",
+		},
+		{
+			name:    "type mismatch",
+			source:  `{"name": 1}`,
+			target:  func() interface{} { return new(config) },
+			wantErr: "test.star:1:10: name: cannot decode int into string",
+		},
+		{
+			name:    "array length mismatch",
+			source:  `[1, 2, 3]`,
+			target:  func() interface{} { return new([2]int) },
+			wantErr: "test.star:1:1: cannot decode 3 elements into [2]int",
+		},
+		{
+			name:    "unmarshaler error",
+			source:  `struct(const = "foo")`,
+			target:  func() interface{} { return new(config) },
+			wantErr: "test.star:1:16: const: calling UnmarshalStarlark for starlarkgen.testUpperString: identifier expected",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x, err := syntax.ParseExpr("test.star", tt.source, 0)
+			if err != nil {
+				t.Fatalf("error parsing source: %v", err)
+			}
+			got := tt.target()
+			err = Unmarshal(x, got)
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				var ue *UnmarshalError
+				if !errors.As(err, &ue) {
+					t.Fatalf("expected *UnmarshalError, got %T", err)
+				}
+				if gotErr := err.Error(); gotErr != tt.wantErr {
+					t.Fatalf("expected error %q, got %q", tt.wantErr, gotErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			// positions are not relevant for the comparison
+			if c, ok := got.(*config); ok && c.Raw != nil {
+				b := c.Raw.(*syntax.BinaryExpr)
+				b.OpPos = syntax.Position{}
+				b.X.(*syntax.Ident).NamePos = syntax.Position{}
+				b.Y.(*syntax.Literal).TokenPos = syntax.Position{}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %#v, got %#v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_wrapped(t *testing.T) {
+	x, err := syntax.ParseExpr("test.star", `struct(const = "foo")`, 0)
+	if err != nil {
+		t.Fatalf("error parsing source: %v", err)
+	}
+	var got struct {
+		Const testUpperString `starlark:"const"`
+	}
+	err = Unmarshal(x, &got)
+	if !errors.Is(err, errIdentExpected) {
+		t.Errorf("expected error wrapping %v, got %v", errIdentExpected, err)
+	}
+}
+
+func TestUnmarshal_invalidDictEntry(t *testing.T) {
+	one := &syntax.Literal{Token: syntax.INT, Value: int64(1)}
+	input := &syntax.DictExpr{List: []syntax.Expr{
+		&syntax.DictEntry{Key: one, Value: one},
+		one,
+	}}
+	var got map[int]int
+	err := Unmarshal(input, &got)
+	const want = "expected *syntax.DictEntry, got *syntax.Literal in dict"
+	if err == nil || err.Error() != want {
+		t.Errorf("expected error %q, got %v", want, err)
+	}
+}
+
+func TestUnmarshal_roundTrip(t *testing.T) {
+	input := testArchive{Name: "foo", URLs: []string{"a", "b"}, Sha256: "abc"}
+	x, err := FromGo(input)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	var got testArchive
+	if err := Unmarshal(x, &got); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(got, input) {
+		t.Errorf("want %#v, got %#v", input, got)
+	}
+}
+
+func TestUnmarshalFile(t *testing.T) {
+	const source = `"""config file"""
+
+name = "foo"
+tags = ["a"]
+`
+	f, err := syntax.Parse("test.star", source, 0)
+	if err != nil {
+		t.Fatalf("error parsing source: %v", err)
+	}
+
+	var gotMap map[string]interface{}
+	if err := UnmarshalFile(f, &gotMap); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if want := map[string]interface{}{"name": "foo", "tags": []interface{}{"a"}}; !reflect.DeepEqual(gotMap, want) {
+		t.Errorf("want %#v, got %#v", want, gotMap)
+	}
+
+	var gotStruct struct {
+		Name string   `starlark:"name"`
+		Tags []string `starlark:"tags"`
+	}
+	if err := UnmarshalFile(f, &gotStruct); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if gotStruct.Name != "foo" || !reflect.DeepEqual(gotStruct.Tags, []string{"a"}) {
+		t.Errorf("unexpected result %#v", gotStruct)
+	}
+
+	f, err = syntax.Parse("WORKSPACE", "http_archive(name = \"a\")\nhttp_archive(name = \"b\")\n", 0)
+	if err != nil {
+		t.Fatalf("error parsing source: %v", err)
+	}
+	var gotSlice []testArchive
+	if err := UnmarshalFile(f, &gotSlice); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if want := []testArchive{{Name: "a"}, {Name: "b"}}; !reflect.DeepEqual(gotSlice, want) {
+		t.Errorf("want %#v, got %#v", want, gotSlice)
+	}
+
+	f, err = syntax.Parse("test.star", "x = 1\ndef foo():\n    pass\n", 0)
+	if err != nil {
+		t.Fatalf("error parsing source: %v", err)
+	}
+	wantErr := "test.star:2:1: statement index 1: expected NAME = <literal> assignment, got *syntax.DefStmt"
+	if err := UnmarshalFile(f, &gotMap); err == nil || err.Error() != wantErr {
+		t.Errorf("expected error %q, got %v", wantErr, err)
+	}
+}
@@ -0,0 +1,520 @@
+package starlarkgen
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"go.starlark.net/syntax"
+)
+
+// WithValidation makes WriteStmt, WriteFile and WriteExpr, and the respective
+// Starlark* functions, validate the input with Validate before rendering,
+// nothing is written if the input is invalid, and the errors are returned as
+// RenderErrors.
+func WithValidation(value bool) Option {
+	return func(o *outputOpts) (*outputOpts, error) {
+		c := o.copy()
+		c.validate = value
+		return c, nil
+	}
+}
+
+// Validate checks the syntax tree renders into the valid Starlark code with
+// the options supplied, reporting all the problems found, such as:
+//   - the nodes not supported or the nil nodes
+//   - the invalid identifiers
+//   - the operator tokens not allowed, e.g. the "=" outside of the call
+//     arguments and the def parameters
+//   - the literal values not supported
+//   - the load module not being a string literal, the private symbols loaded
+//     or the load statements not at the top level
+//   - the empty def, if, for and while blocks, unless WithAutoPass is set
+//   - the positional arguments after the keyword ones, more than one *args or
+//     **kwargs, and the misplaced def parameters
+//   - the targets not assignable
+//   - the break and continue outside of a loop, and the return outside of
+//     a function
+//
+// The errors are *RenderError values, their messages start with the path of
+// the node from the node validated, if any. Validate does not resolve
+// the names, see resolve.File for that.
+func Validate(node syntax.Node, options ...Option) []error {
+	opts, err := getOutputOpts(options...)
+	if err != nil {
+		return []error{err}
+	}
+	errs := validate(node, opts)
+	if len(errs) == 0 {
+		return nil
+	}
+	res := make([]error, len(errs))
+	for i, e := range errs {
+		res[i] = e
+	}
+	return res
+}
+
+// validate returns the errors of the node with the paths and the messages
+// completed.
+func validate(node syntax.Node, opts *outputOpts) RenderErrors {
+	v := &validator{opts: opts}
+	switch t := node.(type) {
+	case *syntax.File:
+		if t == nil {
+			v.errorf(nil, "nil file")
+			break
+		}
+		v.stmts(t, "file", t.Stmts)
+	case syntax.Stmt:
+		v.stmt(t)
+	case syntax.Expr:
+		v.expr(t)
+	default:
+		v.errorf(nil, "unsupported node type %T", t)
+	}
+	if len(v.errs) == 0 {
+		return nil
+	}
+	// the messages are prefixed with the paths
+	return renderError(node, v.errs, opts.source).(RenderErrors)
+}
+
+type validator struct {
+	opts   *outputOpts
+	errs   RenderErrors
+	node   syntax.Node // the node checked
+	level  int         // the statement nesting level
+	loops  int         // the enclosing loops within the function
+	inFunc bool        // within the def statement
+}
+
+// errorf records the error of the node, or of the node checked if nil.
+func (v *validator) errorf(node syntax.Node, format string, args ...interface{}) {
+	if node == nil {
+		node = v.node
+	}
+	v.errs = append(v.errs, &RenderError{Node: node, Err: fmt.Errorf(format, args...)})
+}
+
+// enter makes the node the one checked, returning the function restoring
+// the previous one.
+func (v *validator) enter(node syntax.Node) func() {
+	prev := v.node
+	v.node = node
+	return func() { v.node = prev }
+}
+
+// stmts checks the block of the parent node, which must not be empty, unless
+// it is the file or the pass statement is written for it.
+func (v *validator) stmts(parent syntax.Node, block string, list []syntax.Stmt) {
+	if len(list) == 0 && block != "file" && !v.opts.autoPass {
+		v.errorf(parent, "empty %s block", block)
+	}
+	for _, st := range list {
+		v.stmt(st)
+	}
+}
+
+func (v *validator) nested(parent syntax.Node, block string, list []syntax.Stmt) {
+	v.level++
+	v.stmts(parent, block, list)
+	v.level--
+}
+
+func (v *validator) stmt(input syntax.Stmt) {
+	if isNilNode(input) {
+		v.errorf(nil, "nil statement")
+		return
+	}
+	defer v.enter(input)()
+
+	switch t := input.(type) {
+	case *syntax.AssignStmt:
+		if !assignOp(t.Op) {
+			v.errorf(t, "unsupported assignment Op token %v", t.Op)
+		}
+		v.target(t, t.LHS, t.Op != syntax.EQ)
+		v.expr(t.RHS)
+	case *syntax.BranchStmt:
+		switch t.Token {
+		case syntax.PASS:
+		case syntax.BREAK, syntax.CONTINUE:
+			if v.loops == 0 {
+				v.errorf(t, "%s not in a loop", t.Token)
+			}
+		default:
+			v.errorf(t, "unsupported branch statement token %v", t.Token)
+		}
+	case *syntax.DefStmt:
+		v.ident(t, t.Name)
+		v.params(t, t.Params)
+		loops, inFunc := v.loops, v.inFunc
+		v.loops, v.inFunc = 0, true
+		v.nested(t, "def", t.Body)
+		v.loops, v.inFunc = loops, inFunc
+	case *syntax.ExprStmt:
+		v.expr(t.X)
+	case *syntax.ForStmt:
+		v.target(t, t.Vars, false)
+		v.expr(t.X)
+		v.loops++
+		v.nested(t, "for", t.Body)
+		v.loops--
+	case *syntax.IfStmt:
+		v.expr(t.Cond)
+		v.nested(t, "if", t.True)
+		if len(t.False) > 0 {
+			v.nested(t, "else", t.False)
+		}
+	case *syntax.LoadStmt:
+		v.load(t)
+	case *syntax.ReturnStmt:
+		if !v.inFunc {
+			v.errorf(t, "return statement not within a function")
+		}
+		if t.Result != nil {
+			v.expr(t.Result)
+		}
+	case *syntax.WhileStmt:
+		v.expr(t.Cond)
+		v.loops++
+		v.nested(t, "while", t.Body)
+		v.loops--
+	default:
+		v.errorf(input, "unsupported statement type %T", t)
+	}
+}
+
+func (v *validator) load(input *syntax.LoadStmt) {
+	if v.level > 0 {
+		v.errorf(input, "load statement not at the top level")
+	}
+	if input.Module == nil {
+		v.errorf(input, "load statement without the module")
+	} else if _, ok := input.Module.Value.(string); !ok {
+		v.errorf(input.Module, "load module must be a string literal")
+	}
+	if len(input.From) != len(input.To) {
+		v.errorf(input, "load statement lengths mismatch, From: %d, To: %d", len(input.From), len(input.To))
+		return
+	}
+	if len(input.From) == 0 {
+		v.errorf(input, "load statement without the symbols")
+	}
+	for i, from := range input.From {
+		if from == nil || input.To[i] == nil {
+			v.errorf(input, "nil load symbol %d", i)
+			continue
+		}
+		v.ident(input, input.To[i])
+		v.ident(input, from)
+		if from.Name != "" && from.Name[0] == '_' {
+			v.errorf(from, "load: names with leading underscores are not exported: %s", from.Name)
+		}
+	}
+}
+
+// target checks the assignment target, the tuples and the lists are not
+// allowed in the augmented assignment, the same way resolve does.
+func (v *validator) target(parent syntax.Node, input syntax.Expr, augmented bool) {
+	if isNilNode(input) {
+		v.errorf(parent, "nil assignment target")
+		return
+	}
+	switch t := input.(type) {
+	case *syntax.Ident, *syntax.IndexExpr, *syntax.DotExpr:
+		v.expr(t)
+		return
+	case *syntax.ParenExpr:
+		v.target(t, t.X, augmented)
+		return
+	case *syntax.ListExpr:
+		v.targets(t, t.List, augmented)
+		return
+	case *syntax.TupleExpr:
+		v.targets(t, t.List, augmented)
+		return
+	}
+	v.errorf(input, "cannot assign to %T", input)
+}
+
+// targets checks the elements of the tuple or the list assignment target.
+func (v *validator) targets(input syntax.Expr, list []syntax.Expr, augmented bool) {
+	if augmented {
+		v.errorf(input, "%v", augmentedTarget(input))
+	}
+	for _, x := range list {
+		v.target(input, x, augmented)
+	}
+}
+
+// params checks the def parameters, the same way resolve does.
+func (v *validator) params(def *syntax.DefStmt, params []syntax.Expr) {
+	var seenOptional, seenStar, seenStarStar bool
+	for _, param := range params {
+		if isNilNode(param) {
+			v.errorf(def, "nil def parameter")
+			continue
+		}
+		switch t := param.(type) {
+		case *syntax.Ident:
+			v.ident(def, t)
+			switch {
+			case seenStarStar:
+				v.errorf(t, "required parameter may not follow **kwargs")
+			case seenOptional && !seenStar:
+				v.errorf(t, "required parameter may not follow optional")
+			}
+			continue
+		case *syntax.BinaryExpr:
+			if t.Op == syntax.EQ {
+				v.kwarg(t)
+				if seenStarStar {
+					v.errorf(t, "optional parameter may not follow **kwargs")
+				}
+				seenOptional = true
+				continue
+			}
+		case *syntax.UnaryExpr:
+			if t.Op == syntax.STAR {
+				if t.X != nil {
+					v.ident(t, t.X)
+				}
+				switch {
+				case seenStarStar:
+					v.errorf(t, "* parameter may not follow **kwargs")
+				case seenStar:
+					v.errorf(t, "multiple * parameters not allowed")
+				}
+				seenStar = true
+				continue
+			}
+			if t.Op == syntax.STARSTAR {
+				v.ident(t, t.X)
+				if seenStarStar {
+					v.errorf(t, "multiple ** parameters not allowed")
+				}
+				seenStarStar = true
+				continue
+			}
+		}
+		v.errorf(param, "unsupported def parameter %T", param)
+	}
+}
+
+// args checks the call arguments, the same way resolve does.
+func (v *validator) args(call *syntax.CallExpr) {
+	var seenNamed, seenArgs, seenKwargs bool
+	for _, arg := range call.Args {
+		switch t := arg.(type) {
+		case *syntax.UnaryExpr:
+			if t.Op == syntax.STARSTAR {
+				if seenKwargs {
+					v.errorf(t, "multiple **kwargs not allowed")
+				}
+				seenKwargs = true
+				v.expr(t.X)
+				continue
+			}
+			if t.Op == syntax.STAR {
+				switch {
+				case seenKwargs:
+					v.errorf(t, "*args may not follow **kwargs")
+				case seenArgs:
+					v.errorf(t, "multiple *args not allowed")
+				}
+				seenArgs = true
+				v.expr(t.X)
+				continue
+			}
+		case *syntax.BinaryExpr:
+			if t.Op == syntax.EQ {
+				if seenKwargs {
+					v.errorf(t, "argument may not follow **kwargs")
+				}
+				seenNamed = true
+				v.kwarg(t)
+				continue
+			}
+		}
+		switch {
+		case seenArgs:
+			v.errorf(arg, "argument may not follow *args")
+		case seenKwargs:
+			v.errorf(arg, "argument may not follow **kwargs")
+		case seenNamed:
+			v.errorf(arg, "positional argument may not follow named")
+		}
+		v.expr(arg)
+	}
+}
+
+// kwarg checks the keyword argument or the optional parameter.
+func (v *validator) kwarg(input *syntax.BinaryExpr) {
+	v.ident(input, input.X)
+	v.expr(input.Y)
+}
+
+// ident checks the expression is the valid identifier.
+func (v *validator) ident(parent syntax.Node, input syntax.Expr) {
+	id, ok := input.(*syntax.Ident)
+	switch {
+	case isNilNode(input):
+		v.errorf(parent, "nil identifier")
+	case !ok:
+		v.errorf(input, "expected identifier, got %T", input)
+	case !v.opts.sanitizeIdents && !IsIdent(id.Name):
+		v.errorf(id, "invalid identifier %q", id.Name)
+	}
+}
+
+func (v *validator) exprs(list []syntax.Expr) {
+	for _, x := range list {
+		v.expr(x)
+	}
+}
+
+func (v *validator) expr(input syntax.Expr) {
+	if isNilNode(input) {
+		v.errorf(nil, "nil expression")
+		return
+	}
+	defer v.enter(input)()
+
+	switch t := input.(type) {
+	case *syntax.BinaryExpr:
+		if t.Op == syntax.EQ {
+			v.errorf(t, "unexpected %v outside of the call arguments and the def parameters", t.Op)
+		} else if !binaryOp(t.Op) {
+			v.errorf(t, "unsupported binary Op token %v", t.Op)
+		}
+		v.expr(t.X)
+		v.expr(t.Y)
+	case *syntax.CallExpr:
+		v.expr(t.Fn)
+		v.args(t)
+	case *syntax.Comprehension:
+		if e, ok := t.Body.(*syntax.DictEntry); ok {
+			if !t.Curly {
+				v.errorf(t, "dict entry in the list comprehension")
+			}
+			v.expr(e.Key)
+			v.expr(e.Value)
+		} else {
+			if t.Curly {
+				v.errorf(t, "dict comprehension without the dict entry")
+			}
+			v.expr(t.Body)
+		}
+		for i, cl := range t.Clauses {
+			switch c := cl.(type) {
+			case *syntax.ForClause:
+				v.target(c, c.Vars, false)
+				v.expr(c.X)
+			case *syntax.IfClause:
+				if i == 0 {
+					v.errorf(c, "comprehension must start with the for clause")
+				}
+				v.expr(c.Cond)
+			default:
+				v.errorf(t, "unexpected clause type %T", c)
+			}
+		}
+		if len(t.Clauses) == 0 {
+			v.errorf(t, "comprehension without clauses")
+		}
+	case *syntax.CondExpr:
+		v.expr(t.Cond)
+		v.expr(t.True)
+		v.expr(t.False)
+	case *syntax.DictExpr:
+		for _, elem := range t.List {
+			e, ok := elem.(*syntax.DictEntry)
+			if !ok || e == nil {
+				v.errorf(t, "expected *syntax.DictEntry, got %T", elem)
+				continue
+			}
+			v.expr(e.Key)
+			v.expr(e.Value)
+		}
+	case *syntax.DotExpr:
+		v.expr(t.X)
+		v.ident(t, t.Name)
+	case *syntax.Ident:
+		v.ident(nil, t)
+	case *syntax.IndexExpr:
+		v.expr(t.X)
+		v.expr(t.Y)
+	case *syntax.ListExpr:
+		v.exprs(t.List)
+	case *syntax.Literal:
+		v.literal(t)
+	case *syntax.ParenExpr:
+		v.expr(t.X)
+	case *syntax.SliceExpr:
+		v.expr(t.X)
+		for _, x := range []syntax.Expr{t.Lo, t.Hi, t.Step} {
+			if x != nil {
+				v.expr(x)
+			}
+		}
+	case *syntax.TupleExpr:
+		v.exprs(t.List)
+	case *syntax.UnaryExpr:
+		switch t.Op {
+		case syntax.MINUS, syntax.PLUS, syntax.TILDE, syntax.NOT:
+			v.expr(t.X)
+		case syntax.STAR, syntax.STARSTAR:
+			v.errorf(t, "unexpected %v outside of the call arguments and the def parameters", t.Op)
+		default:
+			v.errorf(t, "unsupported unary Op token %v", t.Op)
+		}
+	default:
+		// e.g. *syntax.LambdaExpr
+		v.errorf(input, "type %T is not supported", t)
+	}
+}
+
+func (v *validator) literal(input *syntax.Literal) {
+	if v.opts.style != StyleDefault {
+		// the styles keep the parsed tokens
+		if _, ok := rawLiteral(input); ok {
+			return
+		}
+	}
+	switch t := input.Value.(type) {
+	case nil:
+		if input.Raw == "" {
+			v.errorf(input, "literal without the value")
+		}
+	case string, int, uint, int64, uint64:
+	case *big.Int:
+		if t == nil {
+			v.errorf(input, "nil literal *big.Int value provided")
+		}
+	default:
+		v.errorf(input, "unsupported literal value type %T, expected string, int, int64, uint, uint64 or *big.Int", t)
+	}
+}
+
+// binaryOp reports whether the token is the binary operator.
+func binaryOp(op syntax.Token) bool {
+	switch op {
+	case syntax.OR, syntax.AND,
+		syntax.EQL, syntax.NEQ, syntax.LT, syntax.GT, syntax.LE, syntax.GE, syntax.IN, syntax.NOT_IN,
+		syntax.PIPE, syntax.CIRCUMFLEX, syntax.AMP, syntax.LTLT, syntax.GTGT,
+		syntax.MINUS, syntax.PLUS, syntax.STAR, syntax.SLASH, syntax.SLASHSLASH, syntax.PERCENT:
+		return true
+	}
+	return false
+}
+
+// isNilNode reports whether the node is nil or the nil pointer.
+func isNilNode(node syntax.Node) bool {
+	if node == nil {
+		return true
+	}
+	rv := reflect.ValueOf(node)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
@@ -0,0 +1,198 @@
+package starlarkgen
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.starlark.net/syntax"
+)
+
+func TestValidate(t *testing.T) {
+	parseFile := func(src string) syntax.Node {
+		f, err := syntax.Parse("test.star", src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	ident := func(name string) *syntax.Ident { return &syntax.Ident{Name: name} }
+	tests := []struct {
+		name    string
+		input   syntax.Node
+		options []Option
+		want    []string
+	}{
+		{
+			name: "valid file",
+			input: parseFile(`load(":a.bzl", "b", c = "d")
+def f(a, b = 1, *args, c, **kwargs):
+    for x in a:
+        if x:
+            continue
+        elif not x:
+            break
+        else:
+            pass
+    return [y for y in b if y] + {k: v for k, v in c.items()}.keys()
+x, (y, z) = f(1, b = 2, *[3], **{})
+x += 1
+x -= 1; x *= 1; x /= 1; x //= 1; x %= 1
+x &= 1; x |= 1; x ^= 1; x <<= 1; x >>= 1
+(x) += 1
+`),
+		},
+		{
+			name:  "invalid identifiers",
+			input: &syntax.CallExpr{Fn: ident("1x"), Args: []syntax.Expr{&syntax.BinaryExpr{X: ident("def"), Op: syntax.EQ, Y: ident("a")}}},
+			want:  []string{`Fn: invalid identifier "1x"`, `Args[0].X: invalid identifier "def"`},
+		},
+		{
+			name:  "loads",
+			input: parseFile("load(\":a.bzl\", \"_b\")\ndef f():\n    pass\n"),
+			want:  []string{"Stmts[0].From[0]: load: names with leading underscores are not exported: _b"},
+		},
+		{
+			name: "load module and the nesting",
+			input: &syntax.DefStmt{Name: ident("f"), Body: []syntax.Stmt{&syntax.LoadStmt{
+				Module: &syntax.Literal{Token: syntax.INT, Value: 1},
+				From:   []*syntax.Ident{ident("a")},
+				To:     []*syntax.Ident{ident("a")},
+			}}},
+			want: []string{"Body[0]: load statement not at the top level", "Body[0].Module: load module must be a string literal"},
+		},
+		{
+			name: "load symbol names",
+			input: &syntax.LoadStmt{
+				Module: &syntax.Literal{Token: syntax.STRING, Value: ":a.bzl"},
+				From:   []*syntax.Ident{ident("a-b"), ident("")},
+				To:     []*syntax.Ident{ident("a"), ident("c")},
+			},
+			want: []string{`From[0]: invalid identifier "a-b"`, `From[1]: invalid identifier ""`},
+		},
+		{
+			name:  "empty blocks",
+			input: &syntax.DefStmt{Name: ident("f"), Body: []syntax.Stmt{&syntax.IfStmt{Cond: ident("a")}}},
+			want:  []string{"Body[0]: empty if block"},
+		},
+		{
+			name:    "empty blocks with auto pass",
+			input:   &syntax.DefStmt{Name: ident("f"), Body: []syntax.Stmt{&syntax.IfStmt{Cond: ident("a")}}},
+			options: []Option{WithAutoPass(true)},
+		},
+		{
+			name:  "call arguments",
+			input: parseFile("foo(a = 1, 2, *b, *c, **d, e = 3)\n"),
+			want: []string{
+				"Stmts[0].X.Args[1]: positional argument may not follow named",
+				"Stmts[0].X.Args[3]: multiple *args not allowed",
+				"Stmts[0].X.Args[5]: argument may not follow **kwargs",
+			},
+		},
+		{
+			name:  "def parameters",
+			input: parseFile("def f(a = 1, b, **c, **d):\n    pass\n"),
+			want: []string{
+				"Stmts[0].Params[1]: required parameter may not follow optional",
+				"Stmts[0].Params[3]: multiple ** parameters not allowed",
+			},
+		},
+		{
+			name: "operators",
+			input: &syntax.ListExpr{List: []syntax.Expr{
+				&syntax.BinaryExpr{X: ident("a"), Op: syntax.EQ, Y: ident("b")},
+				&syntax.BinaryExpr{X: ident("a"), Op: syntax.DOT, Y: ident("b")},
+				&syntax.UnaryExpr{Op: syntax.STAR, X: ident("a")},
+			}},
+			want: []string{
+				"List[0]: unexpected = outside of the call arguments and the def parameters",
+				"List[1]: unsupported binary Op token .",
+				"List[2]: unexpected * outside of the call arguments and the def parameters",
+			},
+		},
+		{
+			name: "assignments",
+			input: &syntax.File{Stmts: []syntax.Stmt{
+				&syntax.AssignStmt{LHS: ident("a"), Op: syntax.STAR, RHS: ident("b")},
+				&syntax.AssignStmt{LHS: &syntax.TupleExpr{List: []syntax.Expr{ident("a"), ident("b")}}, Op: syntax.PLUS_EQ, RHS: ident("c")},
+				&syntax.AssignStmt{LHS: &syntax.CallExpr{Fn: ident("a")}, Op: syntax.EQ, RHS: ident("c")},
+			}},
+			want: []string{
+				"Stmts[0]: unsupported assignment Op token *",
+				"Stmts[1].LHS: can't use tuple expression in augmented assignment",
+				"Stmts[2].LHS: cannot assign to *syntax.CallExpr",
+			},
+		},
+		{
+			name: "control flow",
+			input: &syntax.File{Stmts: []syntax.Stmt{
+				&syntax.BranchStmt{Token: syntax.BREAK},
+				&syntax.ReturnStmt{},
+			}},
+			want: []string{"Stmts[0]: break not in a loop", "Stmts[1]: return statement not within a function"},
+		},
+		{
+			name: "nodes not supported",
+			input: &syntax.ListExpr{List: []syntax.Expr{
+				nil,
+				&syntax.LambdaExpr{},
+				&syntax.Literal{Token: syntax.FLOAT, Value: 1.5},
+			}},
+			want: []string{
+				"nil expression",
+				"List[1]: type *syntax.LambdaExpr is not supported",
+				"List[2]: unsupported literal value type float64, expected string, int, int64, uint, uint64 or *big.Int",
+			},
+		},
+		{
+			name:    "parsed literals with the style",
+			input:   parseFile("x = 1.5\n"),
+			options: []Option{WithStyle(StyleBuildifier)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, err := range Validate(tt.input, tt.options...) {
+				var re *RenderError
+				if !errors.As(err, &re) {
+					t.Fatalf("expected *RenderError, got %T", err)
+				}
+				got = append(got, err.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want\n%s\ngot\n%s", strings.Join(tt.want, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestWithValidation(t *testing.T) {
+	input := &syntax.AssignStmt{
+		LHS: &syntax.Ident{Name: "x"},
+		Op:  syntax.EQ,
+		RHS: &syntax.ListExpr{List: []syntax.Expr{
+			&syntax.Literal{Token: syntax.STRING, Value: "a"},
+			&syntax.LambdaExpr{},
+		}},
+	}
+	var sb strings.Builder
+	err := WriteStmt(&sb, input, WithValidation(true))
+	var errs RenderErrors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("expected one error, got %v", err)
+	}
+	if sb.Len() > 0 {
+		t.Errorf("expected no output, got %q", sb.String())
+	}
+
+	// without the validation the output is partial
+	sb.Reset()
+	if err := WriteStmt(&sb, input); err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+	if sb.Len() == 0 {
+		t.Error("expected the partial output")
+	}
+}